@@ -0,0 +1,48 @@
+package main
+
+// entry.go: Pruned state history lookup subcommand
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/store"
+)
+
+// runEntry fetches the account an address held before the block at a pruned
+// height changed it from an archive node's checkpoint history, and prints it
+// once its proof checks out against the node's own checkpoint.
+func runEntry(args []string, checkpoints *store.CheckpointStore) error {
+	fs := flag.NewFlagSet("entry", flag.ContinueOnError)
+	archiveDir := fs.String("archive", "", "data directory of an archive node")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 || *archiveDir == "" {
+		return errUsage
+	}
+	address := fs.Arg(0)
+	height, err := strconv.Atoi(fs.Arg(1))
+	if err != nil {
+		return errUsage
+	}
+	archive, err := store.OpenCheckpoints(filepath.Join(*archiveDir, "checkpoints"), true)
+	if err != nil {
+		return err
+	}
+	entry, err := checkpoints.FetchEntry(archive, blockchain.AccountKey(address), height)
+	if err != nil {
+		return err
+	}
+	acct, err := entry.Account()
+	if err != nil {
+		return err
+	}
+	cp, err := checkpoints.Covering(height)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Before block %d, %s held balance %d with nonce %d (proven against checkpoint %d..%d)\n",
+		height, address, acct.Balance, acct.Nonce, cp.FromHeight, cp.ToHeight)
+	return nil
+}
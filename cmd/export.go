@@ -0,0 +1,115 @@
+package main
+
+// export.go: Chain export and import subcommands and subcommand dispatch
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/store"
+)
+
+// errUsage is returned for a subcommand invoked with bad arguments.
+var errUsage = errors.New("usage: [flags] export [-format binary|json] FILE | import FILE | audit [-archives DIR] [-states DIR] [-report FILE] [-quarantine DIR] | entry -archive DATADIR ADDRESS HEIGHT")
+
+// nodeStores holds the node's stores and settings subcommands read besides its chain.
+type nodeStores struct {
+	blocks      *store.BlockStore
+	snapshots   *store.SnapshotStore
+	checkpoints *store.CheckpointStore
+	stateConfig amf.RebalanceConfig
+	bounds      blockchain.EntropyBounds
+}
+
+// runSubcommand runs the subcommand named by args[0] against the node's chain.
+func runSubcommand(args []string, bc *blockchain.Blockchain, genesis *blockchain.Genesis, stores nodeStores) error {
+	switch args[0] {
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ContinueOnError)
+		format := fs.String("format", store.FormatBinary, "dump format: binary or json")
+		if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 1 {
+			return errUsage
+		}
+		return exportChain(bc, fs.Arg(0), *format)
+	case "import":
+		if len(args) != 2 {
+			return errUsage
+		}
+		return importChain(bc, genesis, args[1])
+	case "audit":
+		return runAudit(args[1:], stores)
+	case "entry":
+		return runEntry(args[1:], stores.checkpoints)
+	}
+	return fmt.Errorf("unknown command %q; %w", args[0], errUsage)
+}
+
+// exportChain dumps the canonical chain to path, replacing it only once the
+// whole dump is written.
+func exportChain(bc *blockchain.Blockchain, path, format string) error {
+	var blocks []*blockchain.Block
+	for h := 0; ; h++ {
+		b, ok := bc.BlockAt(h)
+		if !ok {
+			break
+		}
+		blocks = append(blocks, b)
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = store.WriteDump(f, blocks, format)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	fmt.Printf("Exported %d blocks to %s\n", len(blocks), path)
+	return nil
+}
+
+// importChain re-validates every block of the dump at path from the genesis
+// on, executing it against the state, and only then adds the blocks the chain
+// does not have yet. A dump that fails any check is rejected as a whole; an
+// interrupted import resumes after the blocks it already added.
+func importChain(bc *blockchain.Blockchain, genesis *blockchain.Genesis, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	blocks, err := store.ReadDump(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if _, err := genesis.Replay(blocks); err != nil {
+		return fmt.Errorf("rejecting %s: %w", path, err)
+	}
+	imported := 0
+	for _, b := range blocks {
+		if _, ok := bc.GetBlock(b.Hash); ok {
+			continue
+		}
+		if err := bc.AddBlock(b); err != nil {
+			return fmt.Errorf("import stopped after %d blocks: %w", imported, err)
+		}
+		imported++
+	}
+	head := bc.Head()
+	fmt.Printf("Imported %d of %d blocks from %s; head at block %d (%s)\n", imported, len(blocks), path, head.Index, head.Hash)
+	return nil
+}
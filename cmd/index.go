@@ -0,0 +1,103 @@
+package main
+
+// index.go: Transaction index setup and lookup commands
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/indexer"
+	"github.com/bilal2134/Blockchain_A3/internal/store"
+)
+
+// addressPageSize is the number of transactions listed per page.
+const addressPageSize = 10
+
+// openIndex opens the transaction index in dir and catches it up with the
+// chain ending in head from the block store, rebuilding it if rebuild is set.
+func openIndex(dir string, blocks *store.BlockStore, head *blockchain.Block, rebuild bool) (*indexer.Indexer, error) {
+	idx, err := indexer.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	headHash := ""
+	if head != nil {
+		headHash = head.Hash
+	}
+	if rebuild {
+		err = idx.Rebuild(blocks, headHash)
+	} else {
+		err = idx.Sync(blocks, headHash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if height, _ := idx.Head(); height >= 0 {
+		fmt.Printf("Transaction index at block %d\n", height)
+	}
+	return idx, nil
+}
+
+// indexListener keeps the transaction index in step with the canonical chain.
+// It must run after the store listener: if the index cannot apply an event it
+// is caught up from the block store instead. Failures are reported but do not
+// reject the block.
+func indexListener(idx *indexer.Indexer, blocks *store.BlockStore) blockchain.ChainListener {
+	return func(ev blockchain.ChainEvent) error {
+		err := idx.OnChainEvent(ev)
+		if err != nil && len(ev.Connected) > 0 {
+			err = idx.Sync(blocks, ev.Connected[len(ev.Connected)-1].Hash)
+		}
+		if err != nil {
+			fmt.Println("Transaction index error:", err)
+		}
+		return nil
+	}
+}
+
+// lookupTransaction finds a canonical transaction by hash through the index.
+func lookupTransaction(reader *bufio.Reader, idx *indexer.Indexer, bc *blockchain.Blockchain) {
+	if idx == nil {
+		fmt.Println("Transaction index disabled; start with -index.")
+		return
+	}
+	loc, err := idx.Tx(prompt(reader, "Transaction hash: "))
+	if err != nil {
+		fmt.Println("Lookup error:", err)
+		return
+	}
+	fmt.Printf("Block %d (%s), position %d\n", loc.Height, loc.BlockHash, loc.Index)
+	block, ok := bc.BlockAt(loc.Height)
+	if !ok || block.Hash != loc.BlockHash || loc.Index >= len(block.Transactions) {
+		return
+	}
+	if tx, err := blockchain.DecodeTransaction(block.Transactions[loc.Index]); err == nil {
+		fmt.Printf("%s -> %s amount %d fee %d nonce %d\n", tx.From, tx.To, tx.Amount, tx.Fee, tx.Nonce)
+	}
+}
+
+// listAddressTransactions lists a page of an address's transactions, newest first.
+func listAddressTransactions(reader *bufio.Reader, idx *indexer.Indexer) {
+	if idx == nil {
+		fmt.Println("Transaction index disabled; start with -index.")
+		return
+	}
+	address := prompt(reader, "Address: ")
+	page := 1
+	if s := prompt(reader, "Page [1]: "); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			fmt.Println("Invalid page.")
+			return
+		}
+		page = n
+	}
+	locs, total := idx.AddressTxs(address, (page-1)*addressPageSize, addressPageSize)
+	for _, loc := range locs {
+		fmt.Printf("Block %d position %d: %s\n", loc.Height, loc.Index, loc.TxHash)
+	}
+	pages := (total + addressPageSize - 1) / addressPageSize
+	fmt.Printf("Page %d of %d, %d transactions\n", page, max(pages, 1), total)
+}
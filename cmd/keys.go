@@ -0,0 +1,182 @@
+package main
+
+// keys.go: Keystore-backed node identity and wallet account commands
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bilal2134/Blockchain_A3/internal/bft"
+	"github.com/bilal2134/Blockchain_A3/internal/keystore"
+	"github.com/bilal2134/Blockchain_A3/internal/wallet"
+)
+
+// Identity is the validator identity loaded from the keystore at startup.
+type Identity struct {
+	Address   string
+	PublicKey string
+	VRF       *bft.VRF
+}
+
+// prompt prints label and reads one trimmed line from reader.
+func prompt(reader *bufio.Reader, label string) string {
+	fmt.Print(label)
+	line, _ := reader.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// loadIdentity unlocks the wallet accounts and, if configured, the validator
+// signing and VRF keys. A nil identity is returned when no validator key is set.
+func loadIdentity(ks *keystore.KeyStore, w *wallet.Wallet, passphrase, identityAddr, vrfAddr string) (*Identity, error) {
+	loaded, err := w.Load(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Loaded %d wallet account(s).\n", len(loaded))
+	if identityAddr == "" {
+		return nil, nil
+	}
+	if err := ks.Unlock(identityAddr, passphrase); err != nil {
+		return nil, fmt.Errorf("unlock identity %s: %w", identityAddr, err)
+	}
+	key, err := ks.Key(identityAddr)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := key.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	id := &Identity{Address: identityAddr, PublicKey: hex.EncodeToString(pub)}
+	if vrfAddr != "" {
+		if err := ks.Unlock(vrfAddr, passphrase); err != nil {
+			return nil, fmt.Errorf("unlock VRF key %s: %w", vrfAddr, err)
+		}
+		vk, err := ks.Key(vrfAddr)
+		if err != nil {
+			return nil, err
+		}
+		priv, err := vk.ECDSA()
+		if err != nil {
+			return nil, err
+		}
+		id.VRF = bft.NewVRFFromKey(priv)
+	}
+	return id, nil
+}
+
+// newAccount creates a wallet account or VRF key interactively.
+func newAccount(reader *bufio.Reader, ks *keystore.KeyStore, w *wallet.Wallet) {
+	typ := prompt(reader, "Key type (ed25519/p256) [ed25519]: ")
+	pass := prompt(reader, "Passphrase: ")
+	if typ == "" || typ == string(keystore.KeyEd25519) {
+		addr, err := w.NewAccount(pass)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println("New account:", addr)
+		return
+	}
+	addr, err := ks.NewKey(keystore.KeyType(typ), pass)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("New key:", addr)
+}
+
+// listKeys prints every key in the keystore.
+func listKeys(ks *keystore.KeyStore) {
+	infos, err := ks.List()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	for _, info := range infos {
+		state := "locked"
+		if info.Unlocked {
+			state = "unlocked"
+		}
+		fmt.Printf("%s %-7s %s (%s)\n", info.Address, info.Type, info.PublicKey, state)
+	}
+}
+
+// exportKey writes a re-encrypted key file for an address.
+func exportKey(reader *bufio.Reader, ks *keystore.KeyStore) {
+	addr := prompt(reader, "Address: ")
+	pass := prompt(reader, "Current passphrase: ")
+	newPass := prompt(reader, "Export passphrase: ")
+	path := prompt(reader, "Output file: ")
+	data, err := ks.Export(addr, pass, newPass)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Key exported to", path)
+}
+
+// importKey imports a key file exported by another keystore.
+func importKey(reader *bufio.Reader, ks *keystore.KeyStore) {
+	path := prompt(reader, "Key file: ")
+	pass := prompt(reader, "Key file passphrase: ")
+	newPass := prompt(reader, "New passphrase: ")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	addr, err := ks.ImportKeyFile(data, pass, newPass)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Imported key:", addr)
+}
+
+// newMnemonic creates a backup phrase and derives its first account.
+func newMnemonic(reader *bufio.Reader, w *wallet.Wallet) {
+	phrase, err := wallet.NewMnemonic(128)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Write down this backup phrase:")
+	fmt.Println(phrase)
+	pass := prompt(reader, "Keystore passphrase: ")
+	accounts, err := w.Restore(phrase, "", pass, 1)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Derived account:", accounts[0])
+}
+
+// restoreMnemonic re-derives wallet accounts from a backup phrase.
+func restoreMnemonic(reader *bufio.Reader, w *wallet.Wallet) {
+	phrase := prompt(reader, "Backup phrase: ")
+	if !wallet.ValidateMnemonic(phrase) {
+		fmt.Println("Invalid backup phrase.")
+		return
+	}
+	count, err := strconv.Atoi(prompt(reader, "Number of accounts [1]: "))
+	if err != nil || count < 1 {
+		count = 1
+	}
+	pass := prompt(reader, "Keystore passphrase: ")
+	accounts, err := w.Restore(phrase, "", pass, count)
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+	for i, addr := range accounts {
+		fmt.Printf("%s %s\n", wallet.AccountPath(uint32(i)), addr)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+// light.go: Light client commands
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/lightclient"
+)
+
+// lightVerify syncs a light client for the chain of genesis, whose first block
+// has hash genesisHash, against the local node, holding certificates to the
+// genesis validator set and quorum, and verifies a transaction's inclusion and
+// receipt.
+func lightVerify(reader *bufio.Reader, node *lightclient.LocalNode, genesis *blockchain.Genesis, genesisHash string) {
+	client := lightclient.New(node, lightclient.Config{
+		ChainID:           genesis.ChainID,
+		Genesis:           genesisHash,
+		Validators:        genesis.Validators,
+		QuorumNumerator:   genesis.Consensus.QuorumNumerator,
+		QuorumDenominator: genesis.Consensus.QuorumDenominator,
+	})
+	head, err := client.Sync()
+	if err != nil {
+		fmt.Println("Light client sync error:", err)
+		return
+	}
+	fmt.Printf("Light client synced %d headers, finalized through block %d\n", head+1, client.Finalized())
+	txHash := prompt(reader, "Transaction hash: ")
+	tx, header, err := client.VerifyTransaction(txHash)
+	if err != nil {
+		fmt.Println("Verification error:", err)
+		return
+	}
+	fmt.Printf("Transaction %s verified in block %d: %s -> %s amount %d\n", txHash, header.Index, tx.From, tx.To, tx.Amount)
+	receipt, _, err := client.VerifyReceipt(txHash)
+	if err != nil {
+		fmt.Println("Receipt verification error:", err)
+		return
+	}
+	status := "succeeded"
+	if receipt.Status != blockchain.ReceiptSuccess {
+		status = "failed"
+	}
+	fmt.Printf("Receipt verified: %s, gas %d, fee %d, %d logs\n", status, receipt.GasUsed, receipt.Fee, len(receipt.Logs))
+}
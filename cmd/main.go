@@ -3,9 +3,12 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,23 +16,210 @@ import (
 	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
 	"github.com/bilal2134/Blockchain_A3/internal/cap"
 	"github.com/bilal2134/Blockchain_A3/internal/consensus"
+	"github.com/bilal2134/Blockchain_A3/internal/indexer"
+	"github.com/bilal2134/Blockchain_A3/internal/keystore"
+	"github.com/bilal2134/Blockchain_A3/internal/lightclient"
+	"github.com/bilal2134/Blockchain_A3/internal/mempool"
+	"github.com/bilal2134/Blockchain_A3/internal/store"
+	"github.com/bilal2134/Blockchain_A3/internal/wallet"
 )
 
 // Entry point for the blockchain node
 func main() {
-	// Initialize the blockchain
-	bc := NewBlockchain()
+	keystoreDir := flag.String("keystore", "keystore", "directory holding encrypted keys")
+	identityAddr := flag.String("identity", "", "address of the validator ed25519 key to load from the keystore")
+	vrfAddr := flag.String("vrf", "", "address of the validator P-256 VRF key to load from the keystore")
+	genesisPath := flag.String("genesis", "", "genesis spec JSON file (default: a local development chain)")
+	dataDir := flag.String("datadir", "chaindata", "directory holding the block store and state snapshots")
+	noSync := flag.Bool("nosync", false, "do not fsync the block store after every block")
+	snapshotEvery := flag.Int("snapshot-every", 100, "blocks between full state snapshots; state diffs cover the blocks between")
+	pruneKeep := flag.Int("prune", 128, "blocks of state history to keep before pruning it into a checkpoint (0 keeps all)")
+	archive := flag.Bool("archive", false, "keep all state history: pruned journal entries for proofs and the state of every height")
+	historyWindow := flag.Int("history", 1024, "recent heights whose state stays queryable when not in archive mode")
+	minEntropy := flag.Float64("min-entropy", blockchain.DefaultEntropyBounds.MinScore, "lowest entropy score of a block validators accept without flagging it (overrides the genesis spec)")
+	rejectLowEntropy := flag.Bool("reject-low-entropy", false, "have validators vote against blocks below the entropy bounds instead of flagging them (overrides the genesis spec)")
+	indexTxs := flag.Bool("index", false, "index transactions by hash and address for lookups")
+	execWorkers := flag.Int("exec-workers", 0, "execute block transactions optimistically on this many parallel workers (0 executes them serially)")
+	reindex := flag.Bool("reindex", false, "rebuild the transaction index from the block store on startup")
+	flag.Parse()
+	// The genesis spec fixes the first block and the chain parameters
+	genesis := blockchain.DefaultGenesis()
+	if *genesisPath != "" {
+		g, err := blockchain.LoadGenesis(*genesisPath)
+		if err != nil {
+			fmt.Println("Genesis error:", err)
+			return
+		}
+		genesis = g
+	}
+	if err := genesis.UseHashFunction(); err != nil {
+		fmt.Println("Genesis error:", err)
+		return
+	}
+	genesisBlock, err := genesis.Block()
+	if err != nil {
+		fmt.Println("Genesis error:", err)
+		return
+	}
+	fmt.Printf("Chain %d, genesis %s\n", genesis.ChainID, genesisBlock.Hash)
+	entropyBounds := genesis.Consensus.EntropyBounds()
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min-entropy":
+			entropyBounds.MinScore = *minEntropy
+		case "reject-low-entropy":
+			entropyBounds.Reject = *rejectLowEntropy
+		}
+	})
+
 	// Initialize authentication and reputation
 	authMgr := consensus.NewAuthManager()
 	repSys := bft.NewReputationSystem()
 	// Initialize CAP orchestrator components
 	telemetry := cap.NetworkTelemetry{LatencyMs: 0, PacketLoss: 0, Throughput: 0}
 	predictor := &cap.SimplePartitionPredictor{Telemetry: telemetry}
-	orchestrator := cap.NewOrchestrator(int(cap.EventualConsistency), predictor)
-	ac := cap.NewAdaptiveConsistency(cap.EventualConsistency, 2*time.Second, cap.RetryPolicy{MaxRetries: 3, Backoff: 1 * time.Second})
+	policy := genesis.Consistency
+	level := cap.EventualConsistency
+	if policy.Level == blockchain.ConsistencyStrong {
+		level = cap.StrongConsistency
+	}
+	orchestrator := cap.NewOrchestrator(int(level), predictor)
+	ac := cap.NewAdaptiveConsistency(level, time.Duration(policy.TimeoutMs)*time.Millisecond,
+		cap.RetryPolicy{MaxRetries: policy.Retries, Backoff: time.Duration(policy.BackoffMs) * time.Millisecond})
+
+	// Initialize account state with the genesis allocations and the pending transaction pool
+	state, err := genesis.State()
+	if err != nil {
+		fmt.Println("Genesis error:", err)
+		return
+	}
+	if *execWorkers > 0 {
+		state.Parallel = &blockchain.ParallelExecutor{Workers: *execWorkers}
+	}
+	pool := mempool.New(mempool.DefaultConfig, state)
+	builder := blockchain.NewBuilder(blockchain.DefaultBuilderConfig, pool)
+	// Initialize the block tree; state follows the canonical chain, then the
+	// mempool, then the on-disk block store, state diffs, snapshots and prune checkpoints
+	bc := blockchain.NewBlockchain(blockchain.LongestChain{})
+	bc.SetGenesis(genesisBlock.Hash)
+	bc.SetTimestampRules(genesis.Consensus.TimestampRules())
+	builder.SetChain(bc)
+	bc.Subscribe(state.OnChainEvent)
+	bc.Subscribe(pool.OnChainEvent)
+	// Reload the chain persisted by previous runs
+	storeOpts := store.DefaultOptions
+	if *noSync {
+		storeOpts.Sync = store.SyncNone
+	}
+	blocks, err := store.Open(*dataDir, storeOpts)
+	if err != nil {
+		fmt.Println("Block store error:", err)
+		return
+	}
+	defer blocks.Close()
+	if err := reloadChain(bc, blocks); err != nil {
+		if errors.Is(err, blockchain.ErrGenesisMismatch) {
+			fmt.Printf("Refusing to start: %s holds a chain with another genesis: %v\n", *dataDir, err)
+			return
+		}
+		fmt.Println("Chain reload stopped:", err)
+	}
+	if head := bc.Head(); head != nil {
+		fmt.Printf("Resumed chain at block %d (%s)\n", head.Index, head.Hash)
+	}
+	snapshots, err := store.OpenSnapshots(filepath.Join(*dataDir, "snapshots"))
+	if err != nil {
+		fmt.Println("Snapshot store error:", err)
+		return
+	}
+	diffs, err := store.OpenDiffs(filepath.Join(*dataDir, "diffs"))
+	if err != nil {
+		fmt.Println("Diff store error:", err)
+		return
+	}
+	checkpoints, err := store.OpenCheckpoints(filepath.Join(*dataDir, "checkpoints"), *archive)
+	if err != nil {
+		fmt.Println("Checkpoint store error:", err)
+		return
+	}
+	history := &store.History{Chain: bc, Diffs: diffs, Snapshots: snapshots, Config: state.Config, Window: *historyWindow}
+	if *archive {
+		history.Window = 0
+	}
+	bc.Subscribe(storeListener(blocks))
+	bc.Subscribe(diffListener(diffs, state))
+	bc.Subscribe(snapshotListener(snapshots, state, *snapshotEvery))
+	bc.Subscribe(historyListener(history))
+	if *pruneKeep > 0 {
+		// The reload replayed history already pruned into stored checkpoints;
+		// drop it again so the next checkpoint starts after the last one
+		if last, ok := checkpoints.Last(); ok {
+			if _, _, err := state.Prune(last.ToHeight); err != nil && !errors.Is(err, blockchain.ErrNothingToPrune) {
+				fmt.Println("State prune error:", err)
+			}
+		}
+		bc.Subscribe(pruneListener(checkpoints, state, *pruneKeep))
+	}
+	// Optional transaction index, caught up with the reloaded chain
+	var txIndex *indexer.Indexer
+	if *indexTxs || *reindex {
+		if txIndex, err = openIndex(filepath.Join(*dataDir, "index"), blocks, bc.Head(), *reindex); err != nil {
+			fmt.Println("Transaction index error:", err)
+			return
+		}
+		bc.Subscribe(indexListener(txIndex, blocks))
+	}
+	// A new chain starts from the genesis block, seen by every listener
+	if bc.Head() == nil {
+		if err := bc.AddBlock(genesisBlock); err != nil {
+			fmt.Println("Genesis block error:", err)
+			return
+		}
+	}
+	// Export, import, audit and entry run against the chain and stores and exit
+	if flag.NArg() > 0 {
+		stores := nodeStores{blocks: blocks, snapshots: snapshots, checkpoints: checkpoints, stateConfig: state.Config, bounds: entropyBounds}
+		if err := runSubcommand(flag.Args(), bc, genesis, stores); err != nil {
+			fmt.Println("Error:", err)
+		}
+		return
+	}
+	// Certificate of the last block finalized by hybrid consensus
+	var lastCert *blockchain.Certificate
+	// Serves headers, certificates and proofs to in-process light clients
+	lightNode := lightclient.NewLocalNode(bc, history)
+	lightNode.ChainID = genesis.ChainID
+	lightNode.Checkpoints = checkpoints
 
-	// Interactive CLI loop
 	reader := bufio.NewReader(os.Stdin)
+	// Load wallet accounts and validator identity from the keystore
+	ks, err := keystore.Open(*keystoreDir)
+	if err != nil {
+		fmt.Println("Keystore error:", err)
+		return
+	}
+	w := wallet.New(ks)
+	defer ks.LockAll()
+	var identity *Identity
+	if infos, _ := ks.List(); len(infos) > 0 || *identityAddr != "" {
+		pass := prompt(reader, "Keystore passphrase: ")
+		identity, err = loadIdentity(ks, w, pass, *identityAddr, *vrfAddr)
+		if err != nil {
+			fmt.Println("Identity error:", err)
+			return
+		}
+	}
+	for _, v := range genesis.Validators {
+		authMgr.AddNode(v.Address, v.PublicKey)
+		repSys.UpdateReputation(v.Address, 0)
+	}
+	if identity != nil {
+		authMgr.AddNode(identity.Address, identity.PublicKey)
+		repSys.UpdateReputation(identity.Address, 0)
+		fmt.Println("Validator identity:", identity.Address)
+	}
+
+	// Interactive CLI loop
 	for {
 		fmt.Println("\nOptions:")
 		fmt.Println("1) Show blockchain")
@@ -42,7 +232,20 @@ func main() {
 		fmt.Println("8) Run CAP orchestration")
 		fmt.Println("9) Show consistency level")
 		fmt.Println("10) Run hybrid consensus")
-		fmt.Println("11) Exit")
+		fmt.Println("11) New account")
+		fmt.Println("12) List keys")
+		fmt.Println("13) Export key")
+		fmt.Println("14) Import key")
+		fmt.Println("15) New backup phrase")
+		fmt.Println("16) Restore from backup phrase")
+		fmt.Println("17) Submit transaction")
+		fmt.Println("18) Rebuild state at height")
+		fmt.Println("19) Query account at height")
+		fmt.Println("20) Light client: verify transaction")
+		fmt.Println("21) Search logs")
+		fmt.Println("22) Look up transaction")
+		fmt.Println("23) List address transactions")
+		fmt.Println("24) Exit")
 		fmt.Print("> ")
 
 		input, _ := reader.ReadString('\n')
@@ -52,34 +255,22 @@ func main() {
 			for _, blk := range bc.Blocks {
 				fmt.Printf("%+v\n", blk)
 			}
-		case "2":
-			fmt.Print("Enter transaction data: ")
-			data, _ := reader.ReadString('\n')
-			data = strings.TrimSpace(data)
-			index := len(bc.Blocks)
-			prev := ""
-			if index > 0 {
-				prev = bc.Blocks[index-1].Hash
-			}
-			block := blockchain.NewBlock(index, prev, []string{data}, nil, nil, 0)
-			bc.AddBlock(block)
-			fmt.Println("Block added:", block)
-			// Archive the new block
-			if err := blockchain.ArchiveBlock(block); err != nil {
-				fmt.Println("ArchiveBlock error:", err)
-			} else {
-				fmt.Println("Block archived to disk.")
+			if err := bc.Validate(); err != nil {
+				fmt.Println("Chain validation failed:", err)
 			}
-			// Archive current state
-			stateMap := make(map[string]interface{})
-			for i, blk := range bc.Blocks {
-				stateMap[strconv.Itoa(i)] = blk.Hash
+		case "2":
+			// Build a block from the pending transactions in the mempool
+			block, err := builder.Build(bc.Head(), state)
+			if err != nil {
+				fmt.Println("Build error:", err)
+				break
 			}
-			if err := blockchain.ArchiveState(stateMap); err != nil {
-				fmt.Println("ArchiveState error:", err)
-			} else {
-				fmt.Println("State archived to disk.")
+			if err := bc.AddBlock(block); err != nil {
+				fmt.Println("Commit error:", err)
+				break
 			}
+			lastCert = nil
+			fmt.Println("Block added:", block)
 		case "3":
 			fmt.Print("Node ID: ")
 			id, _ := reader.ReadString('\n')
@@ -134,58 +325,91 @@ func main() {
 		case "9":
 			fmt.Println("Current consistency level:", orchestrator.CurrentLevel())
 		case "10":
-			// Hybrid consensus flow
-			fmt.Print("Enter transaction data: ")
-			data, _ := reader.ReadString('\n')
-			data = strings.TrimSpace(data)
-			validators := []string{}
-			for id := range authMgr.GetTrustScores() {
-				validators = append(validators, id)
-			}
+			// Hybrid consensus flow over the genesis validator set, the
+			// set light clients check certificates against
+			validators := genesis.ValidatorIDs()
 			if len(validators) == 0 {
-				fmt.Println("No validators registered.")
+				fmt.Println("The genesis spec has no validators.")
+				break
+			}
+			if identity == nil || !slices.Contains(validators, strings.ToLower(identity.Address)) {
+				fmt.Println("Consensus needs a validator identity from the genesis set (-identity).")
 				break
 			}
 			hc := consensus.NewHybridConsensus(validators)
+			if identity != nil && identity.VRF != nil {
+				hc.SetVRF(identity.VRF)
+			}
+			hc.SetSigner(w)
+			hc.SetMempool(pool, blockchain.DefaultBuilderConfig)
+			hc.SetEntropyBounds(entropyBounds)
+			hc.SetQuorum(genesis.Consensus.QuorumNumerator, genesis.Consensus.QuorumDenominator)
+			hc.SetChain(bc)
 			hc.StartRound()
-			// propose block
-			index := len(bc.Blocks)
-			prev := ""
-			if index > 0 {
-				prev = bc.Blocks[index-1].Hash
-			}
-			block := blockchain.NewBlock(index, prev, []string{data}, nil, nil, 0)
-			hc.ProposeBlock(block.Hash)
-			// all validators vote yes
-			for _, v := range validators {
-				hc.Vote(v, "yes")
+			// leader proposes a block from the mempool
+			block, err := hc.ProposeFromPool(bc.Head(), lastCert, state)
+			if err != nil {
+				fmt.Println("Proposal error:", err)
+				break
 			}
-			// finalize
-			hc.FinalizeRound()
-			// simple majority check
-			if len(validators) > 0 {
-				bc.AddBlock(block)
-				fmt.Println("Consensus reached, block added:", block)
-				// Archive the committed block
-				if err := blockchain.ArchiveBlock(block); err != nil {
-					fmt.Println("ArchiveBlock error:", err)
-				} else {
-					fmt.Println("Block archived to disk.")
+			// validators check the block before voting
+			var result *blockchain.ValidationResult
+			for _, v := range validators {
+				res, err := hc.VoteOnBlock(v, bc.Head(), block)
+				if err != nil {
+					fmt.Printf("Validator %s rejected block: %v\n", v, err)
+				}
+				if res != nil {
+					result = res
 				}
-				// Archive state
-				stateMap := make(map[string]interface{})
-				for i, blk := range bc.Blocks {
-					stateMap[strconv.Itoa(i)] = blk.Hash
+			}
+			if result != nil {
+				m := result.Metrics
+				fmt.Printf("Block entropy over %d txs: score %.3f, content %.3f, senders %.3f (diversity %.2f), payload %.2f bits/byte over %d bytes\n",
+					m.Txs, m.Score, m.ContentEntropy, m.SenderEntropy, m.SenderDiversity, m.PayloadEntropy, m.PayloadBytes)
+				if result.Flagged != nil {
+					fmt.Println("Warning: low-entropy block:", result.Flagged)
 				}
-				if err := blockchain.ArchiveState(stateMap); err != nil {
-					fmt.Println("ArchiveState error:", err)
-				} else {
-					fmt.Println("State archived to disk.")
+			}
+			// finalize
+			if hc.FinalizeRound() {
+				if err := bc.AddBlock(block); err != nil {
+					fmt.Println("Commit error:", err)
+					break
 				}
+				lastCert = hc.Certificate()
+				lightNode.AddCertificate(lastCert)
+				fmt.Println("Consensus reached, block added:", block)
 			} else {
 				fmt.Println("Consensus not reached.")
 			}
 		case "11":
+			newAccount(reader, ks, w)
+		case "12":
+			listKeys(ks)
+		case "13":
+			exportKey(reader, ks)
+		case "14":
+			importKey(reader, ks)
+		case "15":
+			newMnemonic(reader, w)
+		case "16":
+			restoreMnemonic(reader, w)
+		case "17":
+			submitTransaction(reader, w, pool)
+		case "18":
+			rebuildState(reader, bc, diffs, snapshots, state.Config)
+		case "19":
+			queryAccount(reader, history)
+		case "20":
+			lightVerify(reader, lightNode, genesis, genesisBlock.Hash)
+		case "21":
+			searchLogs(reader, bc)
+		case "22":
+			lookupTransaction(reader, txIndex, bc)
+		case "23":
+			listAddressTransactions(reader, txIndex)
+		case "24":
 			fmt.Println("Exiting.")
 			return
 		default:
@@ -195,8 +419,109 @@ func main() {
 	}
 }
 
-func NewBlockchain() *blockchain.Blockchain {
-	return &blockchain.Blockchain{
-		Blocks: []*blockchain.Block{},
+// reloadChain adds every block in the store to the block tree, stopping at the
+// first one that no longer validates or executes.
+func reloadChain(bc *blockchain.Blockchain, blocks *store.BlockStore) error {
+	return blocks.Iterate(func(b *blockchain.Block) error {
+		if err := bc.AddBlock(b); err != nil && !errors.Is(err, blockchain.ErrKnownBlock) {
+			return err
+		}
+		return nil
+	})
+}
+
+// storeListener persists newly connected blocks to the block store. Write
+// failures are reported but do not reject the block.
+func storeListener(blocks *store.BlockStore) blockchain.ChainListener {
+	return func(ev blockchain.ChainEvent) error {
+		for _, block := range ev.Connected {
+			if err := blocks.Append(block); err != nil {
+				fmt.Println("Block store error:", err)
+			}
+		}
+		return nil
+	}
+}
+
+// diffListener stores the state diff of every newly connected block. It must
+// run before the journal is pruned. Failures are reported but do not reject
+// the block.
+func diffListener(diffs *store.DiffStore, state *blockchain.StateDB) blockchain.ChainListener {
+	return func(ev blockchain.ChainEvent) error {
+		for _, block := range ev.Connected {
+			diff, err := state.DiffAt(block)
+			if err == nil {
+				err = diffs.Save(diff)
+			}
+			if err != nil {
+				fmt.Println("State diff error:", err)
+			}
+		}
+		return nil
+	}
+}
+
+// snapshotListener snapshots the state at the canonical head whenever a
+// connected block is at a multiple of every blocks, and applies the default
+// retention policy. The state is only at hand for the head, so an event
+// connecting several blocks past a multiple snapshots the head instead.
+// Failures are reported but do not reject the block.
+func snapshotListener(snapshots *store.SnapshotStore, state *blockchain.StateDB, every int) blockchain.ChainListener {
+	return func(ev blockchain.ChainEvent) error {
+		crossed := false
+		for _, b := range ev.Connected {
+			if every <= 1 || b.Index%every == 0 {
+				crossed = true
+			}
+		}
+		if !crossed {
+			return nil
+		}
+		head := ev.Connected[len(ev.Connected)-1]
+		if _, err := snapshots.Save(head, state); err != nil {
+			fmt.Println("Snapshot error:", err)
+			return nil
+		}
+		if _, err := snapshots.Prune(store.DefaultRetention); err != nil {
+			fmt.Println("Snapshot prune error:", err)
+		}
+		return nil
+	}
+}
+
+// historyListener drops the state diffs that fall out of the history window.
+// Failures are reported but do not reject the block.
+func historyListener(history *store.History) blockchain.ChainListener {
+	return func(ev blockchain.ChainEvent) error {
+		if len(ev.Connected) == 0 {
+			return nil
+		}
+		if err := history.Prune(ev.Connected[len(ev.Connected)-1].Index); err != nil {
+			fmt.Println("History prune error:", err)
+		}
+		return nil
+	}
+}
+
+// pruneListener prunes the state history of blocks more than keep below the
+// head into a checkpoint. Failures are reported but do not reject the block.
+func pruneListener(checkpoints *store.CheckpointStore, state *blockchain.StateDB, keep int) blockchain.ChainListener {
+	return func(ev blockchain.ChainEvent) error {
+		if len(ev.Connected) == 0 {
+			return nil
+		}
+		head := ev.Connected[len(ev.Connected)-1]
+		cp, entries, err := state.Prune(head.Index - keep)
+		if errors.Is(err, blockchain.ErrNothingToPrune) {
+			return nil
+		}
+		if err != nil {
+			fmt.Println("State prune error:", err)
+			return nil
+		}
+		if err := checkpoints.Add(cp, entries); err != nil {
+			fmt.Println("Checkpoint store error:", err)
+		}
+		return nil
 	}
 }
@@ -0,0 +1,69 @@
+package main
+
+// state.go: Historical state commands
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/store"
+)
+
+// rebuildState rebuilds the state at a height from the nearest canonical
+// snapshot and the stored diffs, and prints its accounts and root.
+func rebuildState(reader *bufio.Reader, bc *blockchain.Blockchain, diffs *store.DiffStore, snapshots *store.SnapshotStore, cfg amf.RebalanceConfig) {
+	height, err := strconv.Atoi(prompt(reader, "Height: "))
+	if err != nil || height < 0 {
+		fmt.Println("Invalid height.")
+		return
+	}
+	state, err := diffs.Rebuild(snapshots, bc.BlockAt, height, cfg)
+	if err != nil {
+		fmt.Println("Rebuild error:", err)
+		return
+	}
+	accounts, err := state.Accounts()
+	if err != nil {
+		fmt.Println("State error:", err)
+		return
+	}
+	addrs := make([]string, 0, len(accounts))
+	for addr := range accounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		fmt.Printf("%s balance %d nonce %d\n", addr, accounts[addr].Balance, accounts[addr].Nonce)
+	}
+	fmt.Printf("State root at block %d: %x\n", height, state.Root())
+}
+
+// queryAccount prints an account as it was at a height, with its proof checked
+// against that block's state root.
+func queryAccount(reader *bufio.Reader, history *store.History) {
+	addr := prompt(reader, "Address: ")
+	height, err := strconv.Atoi(prompt(reader, "Height: "))
+	if err != nil || height < 0 {
+		fmt.Println("Invalid height.")
+		return
+	}
+	proof, block, err := history.AccountAt(addr, height)
+	if err != nil {
+		fmt.Println("Query error:", err)
+		return
+	}
+	if err := proof.Verify(block.StateRoot); err != nil {
+		fmt.Println("Proof error:", err)
+		return
+	}
+	if !proof.Exists {
+		fmt.Printf("%s did not exist at block %d (proof verified against %x)\n", addr, height, block.StateRoot)
+		return
+	}
+	fmt.Printf("%s at block %d: balance %d nonce %d (proof verified against %x)\n",
+		addr, height, proof.Account.Balance, proof.Account.Nonce, block.StateRoot)
+}
@@ -0,0 +1,77 @@
+package main
+
+// txs.go: Transaction submission and log search commands
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+	"github.com/bilal2134/Blockchain_A3/internal/mempool"
+	"github.com/bilal2134/Blockchain_A3/internal/wallet"
+)
+
+// submitTransaction signs a transfer from a wallet account and adds it to the mempool.
+func submitTransaction(reader *bufio.Reader, w *wallet.Wallet, pool *mempool.Pool) {
+	accounts := w.Accounts()
+	if len(accounts) == 0 {
+		fmt.Println("No wallet accounts loaded.")
+		return
+	}
+	for i, addr := range accounts {
+		fmt.Printf("%d) %s\n", i+1, addr)
+	}
+	choice, err := strconv.Atoi(prompt(reader, "From account: "))
+	if err != nil || choice < 1 || choice > len(accounts) {
+		fmt.Println("Invalid account.")
+		return
+	}
+	from := accounts[choice-1]
+	to := prompt(reader, "To address: ")
+	if !crypto.IsHexAddress(to) {
+		fmt.Println("Invalid address.")
+		return
+	}
+	amount, err := strconv.ParseUint(prompt(reader, "Amount: "), 10, 64)
+	if err != nil {
+		fmt.Println("Invalid amount:", err)
+		return
+	}
+	fee, err := strconv.ParseUint(prompt(reader, "Fee: "), 10, 64)
+	if err != nil {
+		fmt.Println("Invalid fee:", err)
+		return
+	}
+	tx := blockchain.NewTransaction(from, to, amount, fee, pool.NextNonce(from), nil)
+	if err := tx.Sign(w); err != nil {
+		fmt.Println("Signing error:", err)
+		return
+	}
+	if err := pool.Add(tx); err != nil {
+		fmt.Println("Rejected:", err)
+		return
+	}
+	fmt.Println("Transaction submitted:", tx.Hash())
+}
+
+// searchLogs lists the logs of canonical blocks that have an address or topic,
+// such as an account address or blockchain.TopicTransfer.
+func searchLogs(reader *bufio.Reader, bc *blockchain.Blockchain) {
+	item := prompt(reader, "Address or topic: ")
+	if item == "" {
+		fmt.Println("Nothing to search for.")
+		return
+	}
+	head := bc.Head()
+	if head == nil {
+		fmt.Println("Chain is empty.")
+		return
+	}
+	matches := bc.FindLogs(item, 0, head.Index)
+	for _, m := range matches {
+		fmt.Printf("Block %d tx %s: %s %v %x\n", m.Height, m.TxHash, m.Log.Address, m.Log.Topics, m.Log.Data)
+	}
+	fmt.Printf("%d logs found\n", len(matches))
+}
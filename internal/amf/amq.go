@@ -2,6 +2,13 @@ package amf
 
 // Amq.go: Approximate Membership Query filter logic
 
+import "encoding/binary"
+
+var (
+	_ AMQFilter = (*SimpleBloomFilter)(nil)
+	_ AMQFilter = (*Bloom)(nil)
+)
+
 // SimpleBloomFilter is a basic Bloom filter implementation for AMQ.
 type SimpleBloomFilter struct {
 	bits  []bool
@@ -46,3 +53,60 @@ func (bf *SimpleBloomFilter) Contains(item string) bool {
 	}
 	return true
 }
+
+// BloomSize is the size in bytes of a Bloom.
+const BloomSize = 256
+
+// bloomHashes is the number of bits a Bloom sets for each item.
+const bloomHashes = 3
+
+// Bloom is a fixed-size 2048-bit Bloom filter. Bit positions are taken from
+// the chain hash of the item, so every node builds the same filter and it can
+// be committed to in block headers.
+type Bloom [BloomSize]byte
+
+// BytesToBloom returns the filter stored in b; an empty b is the empty filter.
+func BytesToBloom(b []byte) *Bloom {
+	var bloom Bloom
+	copy(bloom[:], b)
+	return &bloom
+}
+
+// bloomBits returns the bit positions of item.
+func bloomBits(item string) [bloomHashes]uint {
+	h := Hash([]byte(item))
+	var bits [bloomHashes]uint
+	for i := range bits {
+		bits[i] = uint(binary.BigEndian.Uint16(h[2*i:])) % (BloomSize * 8)
+	}
+	return bits
+}
+
+// Add inserts an item into the filter.
+func (b *Bloom) Add(item string) {
+	for _, bit := range bloomBits(item) {
+		b[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Contains reports whether item is possibly in the filter.
+func (b *Bloom) Contains(item string) bool {
+	for _, bit := range bloomBits(item) {
+		if b[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Or adds every item of other to b.
+func (b *Bloom) Or(other *Bloom) {
+	for i := range b {
+		b[i] |= other[i]
+	}
+}
+
+// Empty reports whether no item was added to the filter.
+func (b *Bloom) Empty() bool {
+	return *b == Bloom{}
+}
@@ -1,7 +1,6 @@
 package amf
 
 import (
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"sort"
@@ -31,6 +30,8 @@ type Forest struct {
 	Shards map[int]*ShardWithMeta // ShardID -> ShardWithMeta
 	Roots  []*Node
 	mutex  sync.RWMutex
+	// journal records undo entries for Put and Delete when attached
+	journal *Journal
 	// ...other fields as needed...
 }
 
@@ -49,9 +50,8 @@ func (f *Forest) AddRoot(root *Node) {
 
 // MergeNodes hashes two child nodes into a new parent node for Merkle integrity.
 func MergeNodes(left, right *Node) *Node {
-	combined := append(left.Hash, right.Hash...)
-	h := sha256.Sum256(combined)
-	return &Node{Hash: h[:], Left: left, Right: right}
+	combined := append(append([]byte{}, left.Hash...), right.Hash...)
+	return &Node{Hash: Hash(combined), Left: left, Right: right}
 }
 
 // CreateShard creates and adds a new shard to the forest.
@@ -139,13 +139,11 @@ func BuildMerkleRoot(shard *Shard) *Node {
 	for _, k := range keys {
 		v := shard.Data[k]
 		b, _ := json.Marshal(v)
-		leafHash := sha256.Sum256(append([]byte(k+":"), b...))
-		nodes = append(nodes, &Node{Hash: leafHash[:]})
+		nodes = append(nodes, &Node{Hash: Hash(append([]byte(k+":"), b...))})
 	}
 	if len(nodes) == 0 {
 		// Empty shard: return zero-hash node
-		empty := sha256.Sum256(nil)
-		return &Node{Hash: empty[:]}
+		return &Node{Hash: Hash(nil)}
 	}
 	// Build tree by merging pairs until one root remains
 	for len(nodes) > 1 {
@@ -207,3 +205,109 @@ func (f *Forest) AddDataToShard(id int, key string, value interface{}, cfg Rebal
 	RebalanceForest(f, cfg)
 	return nil
 }
+
+// Locate returns the ID of the shard holding key.
+func (f *Forest) Locate(key string) (int, bool) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	for id, shard := range f.Shards {
+		if _, ok := shard.Shard.Data[key]; ok {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// Get returns the value stored for key in whichever shard holds it.
+func (f *Forest) Get(key string) (interface{}, bool) {
+	id, ok := f.Locate(key)
+	if !ok {
+		return nil, false
+	}
+	shard, ok := f.GetShard(id)
+	if !ok {
+		return nil, false
+	}
+	shard.Mutex.RLock()
+	defer shard.Mutex.RUnlock()
+	return shard.Shard.GetData(key)
+}
+
+// RootShardID is the shard created for the first key of an empty forest.
+// Splitting shard n yields shards 2n and 2n+1, so numbering starts at 1.
+const RootShardID = 1
+
+// Put stores key in the shard that already holds it, or in the lowest-numbered
+// shard for new keys, creating the root shard in an empty forest.
+func (f *Forest) Put(key string, value interface{}, cfg RebalanceConfig) error {
+	f.recordChange(key)
+	id, ok := f.Locate(key)
+	if !ok {
+		ids := f.DiscoverShardIDs()
+		if len(ids) == 0 {
+			f.CreateShard(RootShardID)
+			id = RootShardID
+		} else {
+			sort.Ints(ids)
+			id = ids[0]
+		}
+	}
+	return f.AddDataToShard(id, key, value, cfg)
+}
+
+// Delete removes key from whichever shard holds it and reports whether it existed.
+func (f *Forest) Delete(key string) bool {
+	id, ok := f.Locate(key)
+	if !ok {
+		return false
+	}
+	f.recordChange(key)
+	shard, ok := f.GetShard(id)
+	if !ok {
+		return false
+	}
+	shard.Mutex.Lock()
+	defer shard.Mutex.Unlock()
+	shard.Shard.RemoveData(key)
+	shard.Root = BuildMerkleRoot(shard.Shard)
+	return true
+}
+
+// recordChange journals the current value of key before it is overwritten or deleted.
+func (f *Forest) recordChange(key string) {
+	f.mutex.RLock()
+	j := f.journal
+	f.mutex.RUnlock()
+	if j == nil {
+		return
+	}
+	prev, existed := f.Get(key)
+	j.record(key, prev, existed)
+}
+
+// StateRoot returns a Merkle root over every key/value pair in the forest.
+// Unlike the per-shard roots it does not depend on the current shard layout,
+// so nodes that split or merged shards differently still agree on it.
+func (f *Forest) StateRoot() []byte {
+	return BuildMerkleRoot(&Shard{Data: f.ReconstructState()}).Hash
+}
+
+// Copy returns an independent copy of the forest for speculative execution.
+// The copy has no journal attached.
+// Stored values are copied shallowly; Merkle nodes are shared since they are never mutated.
+func (f *Forest) Copy() *Forest {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	cp := NewForest()
+	for id, shard := range f.Shards {
+		shard.Mutex.RLock()
+		data := NewShard(shard.Shard.ID)
+		for k, v := range shard.Shard.Data {
+			data.Data[k] = v
+		}
+		cp.Shards[id] = &ShardWithMeta{Shard: data, Root: shard.Root, Load: shard.Load, ID: shard.ID}
+		shard.Mutex.RUnlock()
+	}
+	cp.Roots = append(cp.Roots, f.Roots...)
+	return cp
+}
@@ -0,0 +1,50 @@
+package amf
+
+// hash.go: Hash function used by the Merkle structures
+// The function is fixed for the life of the process: it is chosen once, before
+// the first hash, and every later root is computed with it.
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+)
+
+// ErrHashFuncInUse is returned when the hash function is changed after it was used.
+var ErrHashFuncInUse = errors.New("a different hash function is already in use")
+
+// hashFunc hashes Merkle leaves and inner nodes. It is nil until SetHashFunc
+// or the first hash fixes it, SHA-256 by default.
+var hashFunc atomic.Pointer[crypto.HashFunc]
+
+// currentHash returns the hash function, fixing the default if none was set.
+func currentHash() crypto.HashFunc {
+	if h := hashFunc.Load(); h != nil {
+		return *h
+	}
+	def := crypto.HashFunc(crypto.SHA256)
+	hashFunc.CompareAndSwap(nil, &def)
+	return *hashFunc.Load()
+}
+
+// SetHashFunc selects the hash function for Merkle trees, shard roots and the
+// state root. It must be called before any forest or tree is built, since roots
+// computed under different functions do not match; once a function is in use,
+// selecting another returns ErrHashFuncInUse.
+func SetHashFunc(h crypto.HashFunc) error {
+	if hashFunc.CompareAndSwap(nil, &h) {
+		return nil
+	}
+	probe := []byte("hash function probe")
+	if !bytes.Equal(currentHash()(probe), h(probe)) {
+		return ErrHashFuncInUse
+	}
+	return nil
+}
+
+// Hash hashes data with the configured hash function.
+func Hash(data []byte) []byte {
+	return currentHash()(data)
+}
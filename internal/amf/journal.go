@@ -0,0 +1,210 @@
+package amf
+
+// journal.go: Undo log of forest changes
+// Records the previous value of every key written through Put or Delete, grouped
+// by block height, so the forest can be rolled back after a failed block or a reorg.
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrRevertTooFar is returned when a revert would need entries that were already forgotten.
+	ErrRevertTooFar = errors.New("revert beyond journal history")
+	// ErrBadCheckpoint is returned for a checkpoint that was already reverted or discarded.
+	ErrBadCheckpoint = errors.New("unknown checkpoint")
+)
+
+// JournalEntry is the undo record of one change: the key's value before it.
+type JournalEntry struct {
+	Height  int // Block height the change was made at
+	Key     string
+	Prev    interface{}
+	Existed bool // False if the key was absent, so undoing deletes it
+}
+
+// checkpoint marks a position in the journal to roll back to.
+type checkpoint struct {
+	entries int
+	height  int
+}
+
+// Journal is an undo log attached to a forest. Changes made before the first
+// BeginBlock are recorded at height -1.
+type Journal struct {
+	mu          sync.Mutex
+	forest      *Forest
+	cfg         RebalanceConfig // Thresholds used when restoring values
+	entries     []JournalEntry
+	checkpoints []checkpoint
+	height      int
+	floor       int // Lowest height that can still be reverted to
+}
+
+// NewJournal attaches a new journal to f, replacing any existing one. Restored
+// values are written back under cfg.
+func NewJournal(f *Forest, cfg RebalanceConfig) *Journal {
+	j := &Journal{forest: f, cfg: cfg, height: -1, floor: -1}
+	f.mutex.Lock()
+	f.journal = j
+	f.mutex.Unlock()
+	return j
+}
+
+// Height returns the height changes are currently recorded at.
+func (j *Journal) Height() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.height
+}
+
+// Len returns the number of recorded changes.
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.entries)
+}
+
+// BeginBlock records subsequent changes under height.
+func (j *Journal) BeginBlock(height int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.height = height
+}
+
+// record stores the undo entry for a change of key. It is called by the forest
+// before the change is applied.
+func (j *Journal) record(key string, prev interface{}, existed bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, JournalEntry{Height: j.height, Key: key, Prev: prev, Existed: existed})
+}
+
+// Checkpoint marks the current position for speculative execution and returns
+// its id. Checkpoints nest: reverting to one also drops every later checkpoint.
+func (j *Journal) Checkpoint() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.checkpoints = append(j.checkpoints, checkpoint{entries: len(j.entries), height: j.height})
+	return len(j.checkpoints) - 1
+}
+
+// RevertToCheckpoint undoes every change made since checkpoint id was taken and
+// restores the height it was taken at.
+func (j *Journal) RevertToCheckpoint(id int) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if id < 0 || id >= len(j.checkpoints) {
+		return fmt.Errorf("%w: %d", ErrBadCheckpoint, id)
+	}
+	cp := j.checkpoints[id]
+	j.checkpoints = j.checkpoints[:id]
+	if err := j.undo(cp.entries); err != nil {
+		return err
+	}
+	j.height = cp.height
+	return nil
+}
+
+// DiscardCheckpoint keeps the changes made since checkpoint id and drops it,
+// along with every later checkpoint.
+func (j *Journal) DiscardCheckpoint(id int) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if id < 0 || id >= len(j.checkpoints) {
+		return fmt.Errorf("%w: %d", ErrBadCheckpoint, id)
+	}
+	j.checkpoints = j.checkpoints[:id]
+	return nil
+}
+
+// Revert undoes every change recorded above toHeight, newest first, leaving the
+// forest as it was when block toHeight was complete.
+func (j *Journal) Revert(toHeight int) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if toHeight < j.floor {
+		return fmt.Errorf("%w: height %d, oldest %d", ErrRevertTooFar, toHeight, j.floor)
+	}
+	n := len(j.entries)
+	for n > 0 && j.entries[n-1].Height > toHeight {
+		n--
+	}
+	if err := j.undo(n); err != nil {
+		return err
+	}
+	for len(j.checkpoints) > 0 && j.checkpoints[len(j.checkpoints)-1].entries > n {
+		j.checkpoints = j.checkpoints[:len(j.checkpoints)-1]
+	}
+	if j.height > toHeight {
+		j.height = toHeight
+	}
+	return nil
+}
+
+// Forget drops the entries recorded at or below height, e.g. once those blocks
+// are final, and returns them oldest first. The forest can no longer be
+// reverted below height afterwards.
+func (j *Journal) Forget(height int) []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	n := 0
+	for n < len(j.entries) && j.entries[n].Height <= height {
+		n++
+	}
+	forgotten := j.entries[:n:n]
+	j.entries = append([]JournalEntry(nil), j.entries[n:]...)
+	for i := range j.checkpoints {
+		j.checkpoints[i].entries = max(j.checkpoints[i].entries-n, 0)
+	}
+	if height > j.floor {
+		j.floor = height
+	}
+	return forgotten
+}
+
+// Since returns a copy of the entries recorded at or above height, oldest first.
+func (j *Journal) Since(height int) []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	n := len(j.entries)
+	for n > 0 && j.entries[n-1].Height >= height {
+		n--
+	}
+	return append([]JournalEntry(nil), j.entries[n:]...)
+}
+
+// Floor returns the lowest height the journal can still revert to.
+func (j *Journal) Floor() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.floor
+}
+
+// undo rolls the forest back to the journal position n; j.mu must be held.
+// The journal is detached while restoring so the undo itself is not recorded.
+func (j *Journal) undo(n int) error {
+	f := j.forest
+	f.mutex.Lock()
+	f.journal = nil
+	f.mutex.Unlock()
+	defer func() {
+		f.mutex.Lock()
+		f.journal = j
+		f.mutex.Unlock()
+	}()
+	for len(j.entries) > n {
+		e := j.entries[len(j.entries)-1]
+		if e.Existed {
+			if err := f.Put(e.Key, e.Prev, j.cfg); err != nil {
+				return err
+			}
+		} else {
+			f.Delete(e.Key)
+		}
+		j.entries = j.entries[:len(j.entries)-1]
+	}
+	return nil
+}
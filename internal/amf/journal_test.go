@@ -0,0 +1,156 @@
+package amf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// journalConfig splits and merges shards often, so reverts cross rebalances.
+var journalConfig = RebalanceConfig{SplitThreshold: 8, MergeThreshold: 2}
+
+// applyBlock records block height's changes: it writes a few new keys,
+// overwrites older ones and deletes some.
+func applyBlock(t *testing.T, f *Forest, j *Journal, height int) {
+	t.Helper()
+	j.BeginBlock(height)
+	for i := 0; i < 6; i++ {
+		if err := f.Put(fmt.Sprintf("key-%d-%d", height, i), height*100+i, journalConfig); err != nil {
+			t.Fatalf("block %d: %v", height, err)
+		}
+	}
+	for prev := 0; prev < height; prev++ {
+		key := fmt.Sprintf("key-%d-%d", prev, height%6)
+		if (height+prev)%3 == 0 {
+			f.Delete(key)
+		} else if err := f.Put(key, fmt.Sprintf("updated at %d", height), journalConfig); err != nil {
+			t.Fatalf("block %d: %v", height, err)
+		}
+	}
+}
+
+// journaledForest returns a forest with a journal and a few keys recorded
+// before the first block.
+func journaledForest(t *testing.T) (*Forest, *Journal) {
+	t.Helper()
+	f := NewForest()
+	j := NewJournal(f, journalConfig)
+	for i := 0; i < 4; i++ {
+		if err := f.Put(fmt.Sprintf("initial-%d", i), i, journalConfig); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f, j
+}
+
+// checkState fails unless f has the given root and entries.
+func checkState(t *testing.T, f *Forest, root []byte, state map[string]interface{}, what string) {
+	t.Helper()
+	if got := f.StateRoot(); !bytes.Equal(got, root) {
+		t.Fatalf("%s: root %x, want %x", what, got, root)
+	}
+	if got := f.ReconstructState(); !reflect.DeepEqual(got, state) {
+		t.Fatalf("%s: entries differ", what)
+	}
+}
+
+func TestJournalRevertAndReapply(t *testing.T) {
+	const blocks = 12
+	f, j := journaledForest(t)
+	roots := map[int][]byte{-1: f.StateRoot()}
+	states := map[int]map[string]interface{}{-1: f.ReconstructState()}
+	for h := 0; h < blocks; h++ {
+		applyBlock(t, f, j, h)
+		roots[h], states[h] = f.StateRoot(), f.ReconstructState()
+	}
+	for _, to := range []int{blocks - 1, blocks - 2, 7, 3, 0, -1} {
+		if err := j.Revert(to); err != nil {
+			t.Fatalf("Revert(%d): %v", to, err)
+		}
+		checkState(t, f, roots[to], states[to], fmt.Sprintf("after Revert(%d)", to))
+		for h := to + 1; h < blocks; h++ {
+			applyBlock(t, f, j, h)
+			checkState(t, f, roots[h], states[h], fmt.Sprintf("reapplying block %d after Revert(%d)", h, to))
+		}
+	}
+}
+
+func TestJournalNestedCheckpoints(t *testing.T) {
+	f, j := journaledForest(t)
+	applyBlock(t, f, j, 0)
+	root0, state0 := f.StateRoot(), f.ReconstructState()
+
+	outer := j.Checkpoint()
+	applyBlock(t, f, j, 1)
+	root1, state1 := f.StateRoot(), f.ReconstructState()
+	inner := j.Checkpoint()
+	applyBlock(t, f, j, 2)
+	root2, state2 := f.StateRoot(), f.ReconstructState()
+
+	// Reverting the inner checkpoint keeps the outer one usable
+	if err := j.RevertToCheckpoint(inner); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, f, root1, state1, "after reverting the inner checkpoint")
+	if j.Height() != 1 {
+		t.Fatalf("height %d after reverting the inner checkpoint, want 1", j.Height())
+	}
+	applyBlock(t, f, j, 2)
+	checkState(t, f, root2, state2, "reapplying block 2")
+
+	// Reverting the outer checkpoint drops the inner one with it
+	inner = j.Checkpoint()
+	applyBlock(t, f, j, 3)
+	if err := j.RevertToCheckpoint(outer); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, f, root0, state0, "after reverting the outer checkpoint")
+	if err := j.RevertToCheckpoint(inner); !errors.Is(err, ErrBadCheckpoint) {
+		t.Fatalf("inner checkpoint usable after reverting the outer one: %v", err)
+	}
+	applyBlock(t, f, j, 1)
+	applyBlock(t, f, j, 2)
+	checkState(t, f, root2, state2, "reapplying blocks 1 and 2")
+
+	// A discarded inner checkpoint leaves its changes to the outer one
+	outer = j.Checkpoint()
+	inner = j.Checkpoint()
+	applyBlock(t, f, j, 3)
+	root3, state3 := f.StateRoot(), f.ReconstructState()
+	if err := j.DiscardCheckpoint(inner); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.RevertToCheckpoint(outer); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, f, root2, state2, "after reverting past a discarded checkpoint")
+	applyBlock(t, f, j, 3)
+	checkState(t, f, root3, state3, "reapplying block 3")
+
+	// Reverting by height drops the checkpoints taken above it
+	outer = j.Checkpoint()
+	applyBlock(t, f, j, 4)
+	if err := j.Revert(2); err != nil {
+		t.Fatal(err)
+	}
+	checkState(t, f, root2, state2, "after Revert(2) over a checkpoint")
+	if err := j.RevertToCheckpoint(outer); !errors.Is(err, ErrBadCheckpoint) {
+		t.Fatalf("checkpoint above the reverted height still usable: %v", err)
+	}
+}
+
+func TestJournalForget(t *testing.T) {
+	f, j := journaledForest(t)
+	for h := 0; h < 5; h++ {
+		applyBlock(t, f, j, h)
+	}
+	j.Forget(2)
+	if err := j.Revert(1); !errors.Is(err, ErrRevertTooFar) {
+		t.Fatalf("Revert below the forgotten height: %v", err)
+	}
+	if j.Floor() != 2 {
+		t.Fatalf("floor %d, want 2", j.Floor())
+	}
+}
@@ -0,0 +1,214 @@
+package amf
+
+// mmr.go: Merkle Mountain Range accumulator
+// An append-only list of leaves kept as a row of perfect Merkle trees
+// ("mountains") of decreasing height. Nodes are stored in post-order, so the
+// range over the first n leaves is a prefix of the range over more, and a proof
+// can be made against any earlier size.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// MMR stores every node of a Merkle Mountain Range, enough to prove any leaf.
+type MMR struct {
+	nodes  [][]byte
+	leaves uint64
+}
+
+// MMRPeaks is the compact form of an MMR: its size and mountain peaks, enough
+// to append leaves and compute the root but not to prove them.
+type MMRPeaks struct {
+	Size  uint64
+	Peaks [][]byte // Highest mountain first
+}
+
+// MMRProof proves that a leaf is in an MMR of a given size.
+type MMRProof struct {
+	LeafIndex uint64
+	Size      uint64   // Number of leaves of the MMR the proof is against
+	Siblings  [][]byte // Path from the leaf to its mountain's peak, leaf first
+	Peaks     [][]byte // Every peak of the MMR, highest mountain first
+}
+
+// mmrNodeCount returns the number of nodes of an MMR with n leaves.
+func mmrNodeCount(n uint64) uint64 {
+	return 2*n - uint64(bits.OnesCount64(n))
+}
+
+// mmrLeaf returns the node hash of leaf data.
+func mmrLeaf(data []byte) []byte {
+	return Hash(data)
+}
+
+// mmrParent returns the hash of a node with the given children.
+func mmrParent(left, right []byte) []byte {
+	return Hash(append(append([]byte{}, left...), right...))
+}
+
+// bagPeaks folds the peaks right to left and commits to the leaf count, so
+// MMRs of different sizes never share a root. The empty MMR has a root too.
+func bagPeaks(size uint64, peaks [][]byte) []byte {
+	var bagged []byte
+	for i := len(peaks) - 1; i >= 0; i-- {
+		if bagged == nil {
+			bagged = peaks[i]
+		} else {
+			bagged = mmrParent(peaks[i], bagged)
+		}
+	}
+	return Hash(append(binary.BigEndian.AppendUint64(nil, size), bagged...))
+}
+
+// NewMMR returns an empty MMR.
+func NewMMR() *MMR {
+	return &MMR{}
+}
+
+// Len returns the number of leaves.
+func (m *MMR) Len() uint64 {
+	return m.leaves
+}
+
+// Append adds a leaf and returns its index.
+func (m *MMR) Append(data []byte) uint64 {
+	m.nodes = append(m.nodes, mmrLeaf(data))
+	// Each trailing one bit of the old leaf count is a mountain to merge with
+	for h := 0; h < bits.TrailingZeros64(^m.leaves); h++ {
+		n := len(m.nodes)
+		right := m.nodes[n-1]
+		left := m.nodes[n-1-(1<<(h+1)-1)]
+		m.nodes = append(m.nodes, mmrParent(left, right))
+	}
+	m.leaves++
+	return m.leaves - 1
+}
+
+// Truncate drops every leaf from index size on, e.g. after a reorganization.
+func (m *MMR) Truncate(size uint64) {
+	if size >= m.leaves {
+		return
+	}
+	m.nodes = m.nodes[:mmrNodeCount(size)]
+	m.leaves = size
+}
+
+// Peaks returns the compact form of the MMR over its first size leaves.
+func (m *MMR) Peaks(size uint64) (MMRPeaks, error) {
+	if size > m.leaves {
+		return MMRPeaks{}, fmt.Errorf("mmr size %d beyond %d leaves", size, m.leaves)
+	}
+	p := MMRPeaks{Size: size}
+	var offset uint64
+	for h := 63; h >= 0; h-- {
+		if size&(1<<h) == 0 {
+			continue
+		}
+		offset += 1<<(h+1) - 1
+		p.Peaks = append(p.Peaks, m.nodes[offset-1])
+	}
+	return p, nil
+}
+
+// Root returns the root of the MMR over all leaves.
+func (m *MMR) Root() []byte {
+	p, _ := m.Peaks(m.leaves)
+	return p.Root()
+}
+
+// Prove returns the proof of leaf index against the MMR over its first size leaves.
+func (m *MMR) Prove(index, size uint64) (*MMRProof, error) {
+	if index >= size {
+		return nil, fmt.Errorf("mmr leaf %d outside size %d", index, size)
+	}
+	peaks, err := m.Peaks(size)
+	if err != nil {
+		return nil, err
+	}
+	proof := &MMRProof{LeafIndex: index, Size: size, Peaks: peaks.Peaks}
+	// Find the mountain holding the leaf, then walk down from its peak
+	var offset, first uint64
+	for h := 63; h >= 0; h-- {
+		if size&(1<<h) == 0 {
+			continue
+		}
+		if index < first+1<<h {
+			var path [][]byte
+			rel := index - first
+			for ; h > 0; h-- {
+				half := uint64(1)<<h - 1 // Nodes in each child mountain
+				if rel < 1<<(h-1) {
+					path = append(path, m.nodes[offset+2*half-1])
+				} else {
+					path = append(path, m.nodes[offset+half-1])
+					offset += half
+					rel -= 1 << (h - 1)
+				}
+			}
+			for i := len(path) - 1; i >= 0; i-- {
+				proof.Siblings = append(proof.Siblings, path[i])
+			}
+			return proof, nil
+		}
+		offset += 1<<(h+1) - 1
+		first += 1 << h
+	}
+	return nil, fmt.Errorf("mmr leaf %d not found", index)
+}
+
+// Append returns the peaks after adding a leaf; p itself is not modified.
+func (p MMRPeaks) Append(data []byte) MMRPeaks {
+	peaks := append([][]byte(nil), p.Peaks...)
+	peaks = append(peaks, mmrLeaf(data))
+	for h := 0; h < bits.TrailingZeros64(^p.Size); h++ {
+		n := len(peaks)
+		peaks = append(peaks[:n-2], mmrParent(peaks[n-2], peaks[n-1]))
+	}
+	return MMRPeaks{Size: p.Size + 1, Peaks: peaks}
+}
+
+// Root returns the root of the MMR.
+func (p MMRPeaks) Root() []byte {
+	return bagPeaks(p.Size, p.Peaks)
+}
+
+// VerifyMMRProof checks that data is leaf proof.LeafIndex of the MMR with the given root.
+func VerifyMMRProof(root, data []byte, proof *MMRProof) bool {
+	if proof.LeafIndex >= proof.Size || len(proof.Peaks) != bits.OnesCount64(proof.Size) {
+		return false
+	}
+	// Locate the leaf's mountain: its peak index and height
+	peak, first := 0, uint64(0)
+	height := -1
+	for h := 63; h >= 0; h-- {
+		if proof.Size&(1<<h) == 0 {
+			continue
+		}
+		if proof.LeafIndex < first+1<<h {
+			height = h
+			break
+		}
+		first += 1 << h
+		peak++
+	}
+	if height < 0 || len(proof.Siblings) != height {
+		return false
+	}
+	node := mmrLeaf(data)
+	rel := proof.LeafIndex - first
+	for _, sib := range proof.Siblings {
+		if rel&1 == 0 {
+			node = mmrParent(node, sib)
+		} else {
+			node = mmrParent(sib, node)
+		}
+		rel >>= 1
+	}
+	if !bytes.Equal(node, proof.Peaks[peak]) {
+		return false
+	}
+	return bytes.Equal(bagPeaks(proof.Size, proof.Peaks), root)
+}
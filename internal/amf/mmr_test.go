@@ -0,0 +1,138 @@
+package amf
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// leafData returns the data of the i-th test leaf.
+func leafData(i uint64) []byte {
+	return []byte(fmt.Sprintf("leaf-%d", i))
+}
+
+func TestMMRAppendMatchesPeaks(t *testing.T) {
+	m := NewMMR()
+	var peaks MMRPeaks
+	if !bytes.Equal(m.Root(), peaks.Root()) {
+		t.Fatal("empty MMR and empty peaks have different roots")
+	}
+	seen := map[string]bool{string(m.Root()): true}
+	for i := uint64(0); i < 100; i++ {
+		if got := m.Append(leafData(i)); got != i {
+			t.Fatalf("Append returned index %d, want %d", got, i)
+		}
+		peaks = peaks.Append(leafData(i))
+		if m.Len() != i+1 || peaks.Size != i+1 {
+			t.Fatalf("after %d appends: Len %d, peaks size %d", i+1, m.Len(), peaks.Size)
+		}
+		if !bytes.Equal(m.Root(), peaks.Root()) {
+			t.Fatalf("after %d appends: MMR and peaks roots differ", i+1)
+		}
+		if uint64(len(m.nodes)) != mmrNodeCount(m.Len()) {
+			t.Fatalf("after %d appends: %d nodes, want %d", i+1, len(m.nodes), mmrNodeCount(m.Len()))
+		}
+		if seen[string(m.Root())] {
+			t.Fatalf("after %d appends: root repeats an earlier one", i+1)
+		}
+		seen[string(m.Root())] = true
+	}
+}
+
+func TestMMRProofs(t *testing.T) {
+	m := NewMMR()
+	roots := [][]byte{m.Root()}
+	for i := uint64(0); i < 70; i++ {
+		m.Append(leafData(i))
+		roots = append(roots, m.Root())
+	}
+	for size := uint64(1); size <= m.Len(); size++ {
+		for i := uint64(0); i < size; i++ {
+			proof, err := m.Prove(i, size)
+			if err != nil {
+				t.Fatalf("Prove(%d, %d): %v", i, size, err)
+			}
+			if !VerifyMMRProof(roots[size], leafData(i), proof) {
+				t.Fatalf("proof of leaf %d against size %d rejected", i, size)
+			}
+			if VerifyMMRProof(roots[size], leafData(i+1), proof) {
+				t.Fatalf("proof of leaf %d against size %d accepted other data", i, size)
+			}
+			if size > 1 && VerifyMMRProof(roots[size-1], leafData(i), proof) {
+				t.Fatalf("proof of leaf %d against size %d accepted by the root of size %d", i, size, size-1)
+			}
+			if size > 1 {
+				moved := *proof
+				moved.LeafIndex = (i + 1) % size
+				if VerifyMMRProof(roots[size], leafData(i), &moved) {
+					t.Fatalf("proof of leaf %d against size %d accepted at index %d", i, size, moved.LeafIndex)
+				}
+			}
+		}
+	}
+	if _, err := m.Prove(5, 5); err == nil {
+		t.Fatal("Prove accepted a leaf outside the size")
+	}
+	if _, err := m.Prove(0, m.Len()+1); err == nil {
+		t.Fatal("Prove accepted a size beyond the leaves")
+	}
+}
+
+// TestMMRProofsAcrossPrunedHistory keeps only the peaks on the verifying side,
+// as a pruned light client does, and checks that the oldest leaves are still
+// proven against the root it computes.
+func TestMMRProofsAcrossPrunedHistory(t *testing.T) {
+	full := NewMMR()
+	var pruned MMRPeaks
+	for i := uint64(0); i < 300; i++ {
+		full.Append(leafData(i))
+		pruned = pruned.Append(leafData(i))
+	}
+	root := pruned.Root()
+	for _, i := range []uint64{0, 1, 2, 127, 128, 255, 256, 299} {
+		proof, err := full.Prove(i, pruned.Size)
+		if err != nil {
+			t.Fatalf("Prove(%d): %v", i, err)
+		}
+		if !VerifyMMRProof(root, leafData(i), proof) {
+			t.Fatalf("leaf %d rejected against the pruned root", i)
+		}
+	}
+	// Proofs against an earlier size still verify against that size's peaks
+	early, err := full.Peaks(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := full.Prove(3, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyMMRProof(early.Root(), leafData(3), proof) {
+		t.Fatal("leaf 3 rejected against the root of size 100")
+	}
+	if VerifyMMRProof(root, leafData(3), proof) {
+		t.Fatal("proof against size 100 accepted by the root of size 300")
+	}
+}
+
+func TestMMRTruncate(t *testing.T) {
+	m := NewMMR()
+	roots := [][]byte{m.Root()}
+	for i := uint64(0); i < 40; i++ {
+		m.Append(leafData(i))
+		roots = append(roots, m.Root())
+	}
+	m.Truncate(33)
+	if m.Len() != 33 || !bytes.Equal(m.Root(), roots[33]) {
+		t.Fatal("truncated MMR does not match the MMR of 33 leaves")
+	}
+	m.Append([]byte("other"))
+	if bytes.Equal(m.Root(), roots[34]) {
+		t.Fatal("different leaf after truncation gives the old root")
+	}
+	m.Truncate(33)
+	m.Append(leafData(33))
+	if !bytes.Equal(m.Root(), roots[34]) {
+		t.Fatal("re-appending the same leaf does not restore the root")
+	}
+}
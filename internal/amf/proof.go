@@ -4,9 +4,9 @@ package amf
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
 )
 
 // Node represents a node in the Adaptive Merkle Forest and Merkle trees.
@@ -20,7 +20,8 @@ func NewNode(hash []byte) *Node {
 // MerkleTree represents the Merkle tree
 // Use Node for tree nodes
 type MerkleTree struct {
-	Root *Node
+	Root   *Node
+	Leaves []*Node // Leaf nodes in input order
 }
 
 // NewMerkleTree creates a new Merkle tree from a list of data
@@ -31,9 +32,9 @@ func NewMerkleTree(data [][]byte) (*MerkleTree, error) {
 
 	var nodes []*Node
 	for _, datum := range data {
-		hash := sha256.Sum256(datum)
-		nodes = append(nodes, NewNode(hash[:]))
+		nodes = append(nodes, NewNode(Hash(datum)))
 	}
+	leaves := nodes
 
 	for len(nodes) > 1 {
 		var newLevel []*Node
@@ -43,25 +44,92 @@ func NewMerkleTree(data [][]byte) (*MerkleTree, error) {
 			} else {
 				left := nodes[i]
 				right := nodes[i+1]
-				combined := append(left.Hash, right.Hash...)
-				combinedHash := sha256.Sum256(combined)
+				combined := append(append([]byte{}, left.Hash...), right.Hash...)
 				newLevel = append(newLevel, &Node{
 					Left:  left,
 					Right: right,
-					Hash:  combinedHash[:],
+					Hash:  Hash(combined),
 				})
 			}
 		}
 		nodes = newLevel
 	}
 
-	return &MerkleTree{Root: nodes[0]}, nil
+	return &MerkleTree{Root: nodes[0], Leaves: leaves}, nil
+}
+
+// ProofStep is one sibling on the path from a leaf to the root.
+type ProofStep struct {
+	Hash []byte
+	Left bool // Sibling is the left operand of the hash
+}
+
+// MerkleProof is an inclusion proof for one leaf, ordered from the leaf up.
+// Levels where the node had no sibling and was carried up contribute no step.
+type MerkleProof []ProofStep
+
+// Prove returns the inclusion proof for the leaf at index.
+func (mt *MerkleTree) Prove(index int) (MerkleProof, error) {
+	if index < 0 || index >= len(mt.Leaves) {
+		return nil, fmt.Errorf("leaf index %d out of range [0,%d)", index, len(mt.Leaves))
+	}
+	var proof MerkleProof
+	level := mt.Leaves
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, ProofStep{Hash: level[sibling].Hash, Left: sibling < index})
+		}
+		var next []*Node
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+			} else {
+				next = append(next, &Node{Hash: Hash(append(append([]byte{}, level[i].Hash...), level[i+1].Hash...))})
+			}
+		}
+		level = next
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyProof checks that data is a leaf of the tree with the given root.
+func VerifyProof(root, data []byte, proof MerkleProof) bool {
+	h := Hash(data)
+	for _, step := range proof {
+		if step.Left {
+			h = Hash(append(append([]byte{}, step.Hash...), h...))
+		} else {
+			h = Hash(append(append([]byte{}, h...), step.Hash...))
+		}
+	}
+	return bytes.Equal(h, root)
+}
+
+// VerifyProofAt checks that data is the leaf at index of a tree with the given
+// number of leaves and root. Unlike VerifyProof it binds the leaf's position,
+// as the shape of the proof depends on it.
+func VerifyProofAt(root, data []byte, index, leaves int, proof MerkleProof) bool {
+	if index < 0 || index >= leaves {
+		return false
+	}
+	step := 0
+	for n := leaves; n > 1; n = (n + 1) / 2 {
+		if sibling := index ^ 1; sibling < n {
+			if step >= len(proof) || proof[step].Left != (sibling < index) {
+				return false
+			}
+			step++
+		}
+		index /= 2
+	}
+	return step == len(proof) && VerifyProof(root, data, proof)
 }
 
 // GenerateProof generates a Merkle proof for a given data item
 func (mt *MerkleTree) GenerateProof(data []byte) ([][]byte, error) {
-	hash := sha256.Sum256(data)
-	hashBytes := hash[:]
+	hashBytes := Hash(data)
 
 	var proof [][]byte
 	node := mt.Root
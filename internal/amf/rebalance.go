@@ -12,6 +12,9 @@ type RebalanceConfig struct {
 	MergeThreshold int // Combined load below which shards are merged
 }
 
+// DefaultRebalanceConfig is used when no chain-specific thresholds are configured.
+var DefaultRebalanceConfig = RebalanceConfig{SplitThreshold: 64, MergeThreshold: 16}
+
 // RebalanceForest checks all shards and splits/merges as needed.
 func RebalanceForest(f *Forest, cfg RebalanceConfig) {
 	// Split overloaded shards
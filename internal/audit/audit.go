@@ -0,0 +1,404 @@
+package audit
+
+// audit.go: Integrity audit of stored and archived blocks and state snapshots
+// Checks every block in the node's segment store and in a block archive on its
+// own and against its parent, restores every indexed state snapshot against the
+// stored block it was taken at, and ties every archived state snapshot to the
+// archived blocks whose state root it reproduces. Findings are collected in a
+// report that can be written as JSON; archive files with problems can be moved
+// aside into a quarantine directory.
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/store"
+)
+
+var (
+	// blockFile matches archived blocks, SSZ or JSON encoded.
+	blockFile = regexp.MustCompile(`^block_(\d+)\.(ssz|json)$`)
+	// snapshotFile matches state snapshots, JSON objects of state entries.
+	snapshotFile = regexp.MustCompile(`^state_(\d+)\.json$`)
+)
+
+// Config selects what is audited and how.
+type Config struct {
+	Store         *store.BlockStore        // Node's block store; nil skips it
+	Snapshots     *store.SnapshotStore     // Node's indexed state snapshots; nil skips them
+	StateConfig   amf.RebalanceConfig      // Configuration snapshots are restored with
+	BlocksDir     string                   // Block archive, e.g. "archives"
+	SnapshotsDir  string                   // State snapshots, e.g. "state_archives"
+	Bounds        blockchain.EntropyBounds // Bounds ValidateBlock holds the blocks to
+	QuarantineDir string                   // Where archive files with problems are moved; empty moves nothing
+}
+
+// FileReport holds the findings for one audited file.
+type FileReport struct {
+	File           string   `json:"file"`
+	Height         int      `json:"height"` // Block index, or the height a snapshot matches; -1 if unknown
+	Hash           string   `json:"hash,omitempty"`
+	StateRoot      string   `json:"stateRoot,omitempty"`
+	MatchedHeights []int    `json:"matchedHeights,omitempty"` // Heights of the blocks whose state root a snapshot has
+	Problems       []string `json:"problems,omitempty"`
+	Warnings       []string `json:"warnings,omitempty"`
+	Quarantined    string   `json:"quarantined,omitempty"` // Path the file was moved to
+}
+
+// problem records a finding that makes the file bad.
+func (f *FileReport) problem(format string, args ...any) {
+	f.Problems = append(f.Problems, fmt.Sprintf(format, args...))
+}
+
+// warn records a finding that does not make the file bad by itself.
+func (f *FileReport) warn(format string, args ...any) {
+	f.Warnings = append(f.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Report is the result of an audit.
+type Report struct {
+	Time            time.Time     `json:"time"`
+	StoredBlocks    []*FileReport `json:"storedBlocks"`    // Blocks in the segment store, in append order
+	StoredSnapshots []*FileReport `json:"storedSnapshots"` // Snapshots in the snapshot index
+	Blocks          []*FileReport `json:"blocks"`
+	Snapshots       []*FileReport `json:"snapshots"`
+	MissingHeights  []int         `json:"missingHeights"` // Heights up to the highest archived file with no decodable block
+	Problems        int           `json:"problems"`       // Number of blocks and files with problems
+}
+
+// OK reports whether the audit found no problems.
+func (r *Report) OK() bool {
+	return r.Problems == 0 && len(r.MissingHeights) == 0
+}
+
+// Write stores the report as indented JSON at path.
+func (r *Report) Write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// archivedBlock is a decoded block and its report.
+type archivedBlock struct {
+	block  *blockchain.Block
+	report *FileReport
+}
+
+// Run audits the stores and directories in cfg. Missing directories are
+// treated as empty.
+func Run(cfg Config) (*Report, error) {
+	r := &Report{
+		Time:            time.Now().UTC(),
+		StoredBlocks:    []*FileReport{},
+		StoredSnapshots: []*FileReport{},
+		Blocks:          []*FileReport{},
+		Snapshots:       []*FileReport{},
+		MissingHeights:  []int{},
+	}
+	stored := r.auditStore(cfg)
+	r.auditStoredSnapshots(cfg, stored)
+	// Stored blocks and snapshots are records in shared files or an index,
+	// so they are reported but never quarantined
+	for _, f := range append(append([]*FileReport(nil), r.StoredBlocks...), r.StoredSnapshots...) {
+		if len(f.Problems) > 0 {
+			r.Problems++
+		}
+	}
+	byHeight, maxHeight, err := r.auditBlocks(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for h := 0; h <= maxHeight; h++ {
+		if len(byHeight[h]) == 0 {
+			r.MissingHeights = append(r.MissingHeights, h)
+		}
+	}
+	if err := r.auditSnapshots(cfg, byHeight); err != nil {
+		return nil, err
+	}
+	for _, f := range append(append([]*FileReport(nil), r.Blocks...), r.Snapshots...) {
+		if len(f.Problems) == 0 {
+			continue
+		}
+		r.Problems++
+		if cfg.QuarantineDir != "" {
+			if err := quarantine(f, cfg.QuarantineDir); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// auditStore validates every block in the block store and its link to its
+// stored parent. It returns the blocks read, by hash.
+func (r *Report) auditStore(cfg Config) map[string]archivedBlock {
+	byHash := make(map[string]archivedBlock)
+	if cfg.Store == nil {
+		return byHash
+	}
+	dir := cfg.Store.Dir()
+	err := cfg.Store.Iterate(func(b *blockchain.Block) error {
+		f := &FileReport{File: dir, Height: b.Index, Hash: b.Hash, StateRoot: hex.EncodeToString(b.StateRoot)}
+		r.StoredBlocks = append(r.StoredBlocks, f)
+		res, err := b.ValidateBlock(cfg.Bounds)
+		if err != nil {
+			f.problem("validate: %v", err)
+		} else if res.Flagged != nil {
+			f.warn("validate: %v", res.Flagged)
+		}
+		// Blocks are stored after their parents, so a parent that is not
+		// among the blocks read so far is missing from the store
+		if b.Index == 0 {
+			err = blockchain.VerifyLink(nil, b)
+		} else if parent, ok := byHash[b.PrevHash]; ok {
+			err = blockchain.VerifyLink(parent.block, b)
+		} else {
+			err = fmt.Errorf("parent %s is not stored before the block", b.PrevHash)
+		}
+		if err != nil {
+			f.problem("link: %v", err)
+		}
+		byHash[b.Hash] = archivedBlock{block: b, report: f}
+		return nil
+	})
+	if err != nil {
+		f := &FileReport{File: dir, Height: -1}
+		f.problem("read: %v", err)
+		r.StoredBlocks = append(r.StoredBlocks, f)
+	}
+	return byHash
+}
+
+// auditStoredSnapshots restores every indexed snapshot, checking its content
+// hash and state root, and checks the root against the stored block the
+// snapshot was taken at.
+func (r *Report) auditStoredSnapshots(cfg Config, blocks map[string]archivedBlock) {
+	if cfg.Snapshots == nil {
+		return
+	}
+	for _, info := range cfg.Snapshots.List() {
+		f := &FileReport{File: cfg.Snapshots.Path(info), Height: info.Height, Hash: info.BlockHash, StateRoot: info.StateRoot}
+		r.StoredSnapshots = append(r.StoredSnapshots, f)
+		if _, err := cfg.Snapshots.Restore(info, cfg.StateConfig); err != nil {
+			f.problem("restore: %v", err)
+		}
+		if cfg.Store == nil {
+			continue
+		}
+		b, ok := blocks[info.BlockHash]
+		switch {
+		case !ok:
+			f.problem("block %s is not in the store", info.BlockHash)
+		case b.block.Index != info.Height:
+			f.problem("indexed at height %d, block %s has index %d", info.Height, info.BlockHash, b.block.Index)
+		case hex.EncodeToString(b.block.StateRoot) != info.StateRoot:
+			f.problem("state root %s, block %s committed to %x", info.StateRoot, info.BlockHash, b.block.StateRoot)
+		case len(b.report.Problems) > 0:
+			f.warn("block %s has problems", info.BlockHash)
+		}
+	}
+}
+
+// listFiles returns the names in dir that match pattern with their numeric
+// suffix, in ascending order of it.
+func listFiles(dir string, pattern *regexp.Regexp) ([]string, map[string]int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var names []string
+	nums := make(map[string]int)
+	for _, e := range entries {
+		m := pattern.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		names = append(names, e.Name())
+		nums[e.Name()] = n
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if nums[names[i]] != nums[names[j]] {
+			return nums[names[i]] < nums[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names, nums, nil
+}
+
+// auditBlocks validates every archived block and its link to the archived
+// parent. It returns the blocks that decoded, by height, and the highest height
+// of any archived file (-1 if there are none).
+func (r *Report) auditBlocks(cfg Config) (map[int][]archivedBlock, int, error) {
+	names, nums, err := listFiles(cfg.BlocksDir, blockFile)
+	if err != nil {
+		return nil, -1, err
+	}
+	byHeight := make(map[int][]archivedBlock)
+	maxHeight := -1
+	for _, name := range names {
+		height := nums[name]
+		maxHeight = max(maxHeight, height)
+		f := &FileReport{File: filepath.Join(cfg.BlocksDir, name), Height: height}
+		r.Blocks = append(r.Blocks, f)
+		b, err := readBlock(f.File)
+		if err != nil {
+			f.problem("decode: %v", err)
+			continue
+		}
+		f.Hash, f.StateRoot = b.Hash, hex.EncodeToString(b.StateRoot)
+		if b.Index != height {
+			f.problem("file name says height %d, block has index %d", height, b.Index)
+		}
+		res, err := b.ValidateBlock(cfg.Bounds)
+		if err != nil {
+			f.problem("validate: %v", err)
+		} else if res.Flagged != nil {
+			f.warn("validate: %v", res.Flagged)
+		}
+		for _, other := range byHeight[b.Index] {
+			if other.block.Hash != b.Hash {
+				f.warn("conflicts with %s (%s)", other.report.File, other.block.Hash)
+			}
+		}
+		byHeight[b.Index] = append(byHeight[b.Index], archivedBlock{block: b, report: f})
+	}
+	for h := 0; h <= maxHeight; h++ {
+		for _, child := range byHeight[h] {
+			checkLink(child, byHeight[h-1])
+		}
+	}
+	return byHeight, maxHeight, nil
+}
+
+// checkLink checks child against the archived block it names as parent.
+func checkLink(child archivedBlock, parents []archivedBlock) {
+	if child.block.Index == 0 {
+		if err := blockchain.VerifyLink(nil, child.block); err != nil {
+			child.report.problem("link: %v", err)
+		}
+		return
+	}
+	if len(parents) == 0 {
+		child.report.warn("parent at height %d is not archived", child.block.Index-1)
+		return
+	}
+	for _, p := range parents {
+		if p.block.Hash == child.block.PrevHash {
+			if err := blockchain.VerifyLink(p.block, child.block); err != nil {
+				child.report.problem("link: %v", err)
+			}
+			return
+		}
+	}
+	child.report.problem("link: parent %s is not among the archived blocks at height %d", child.block.PrevHash, child.block.Index-1)
+}
+
+// readBlock decodes an archived block by its file extension.
+func readBlock(path string) (*blockchain.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := new(blockchain.Block)
+	if filepath.Ext(path) == ".ssz" {
+		err = b.UnmarshalSSZ(data)
+	} else {
+		err = json.Unmarshal(data, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// auditSnapshots computes the state root of every snapshot and matches it to
+// the archived blocks that committed to it.
+func (r *Report) auditSnapshots(cfg Config, byHeight map[int][]archivedBlock) error {
+	names, _, err := listFiles(cfg.SnapshotsDir, snapshotFile)
+	if err != nil {
+		return err
+	}
+	heights := make([]int, 0, len(byHeight))
+	for h := range byHeight {
+		heights = append(heights, h)
+	}
+	sort.Ints(heights)
+	for _, name := range names {
+		f := &FileReport{File: filepath.Join(cfg.SnapshotsDir, name), Height: -1}
+		r.Snapshots = append(r.Snapshots, f)
+		root, err := snapshotRoot(f.File)
+		if err != nil {
+			f.problem("decode: %v", err)
+			continue
+		}
+		f.StateRoot = hex.EncodeToString(root)
+		for _, h := range heights {
+			for _, b := range byHeight[h] {
+				if len(b.report.Problems) == 0 && hex.EncodeToString(b.block.StateRoot) == f.StateRoot {
+					f.MatchedHeights = append(f.MatchedHeights, h)
+					break
+				}
+			}
+		}
+		if len(f.MatchedHeights) == 0 {
+			f.problem("state root %s matches no valid archived block", f.StateRoot)
+			continue
+		}
+		// Blocks without transactions keep their parent's state root, so a
+		// snapshot may match several; it is tied to the lowest
+		f.Height = f.MatchedHeights[0]
+	}
+	return nil
+}
+
+// snapshotRoot returns the state root over the entries of a snapshot file,
+// computed as the state's own root is: each value JSON-encoded under its key.
+func snapshotRoot(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	state := make(map[string]interface{}, len(entries))
+	for k, v := range entries {
+		state[k] = v
+	}
+	return amf.BuildMerkleRoot(&amf.Shard{Data: state}).Hash, nil
+}
+
+// quarantine moves the file of f into a subdirectory of dir named after the
+// directory it came from.
+func quarantine(f *FileReport, dir string) error {
+	target := filepath.Join(dir, filepath.Base(filepath.Dir(f.File)))
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return err
+	}
+	dest := filepath.Join(target, filepath.Base(f.File))
+	if err := os.Rename(f.File, dest); err != nil {
+		return err
+	}
+	f.Quarantined = dest
+	return nil
+}
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/store"
+)
+
+// child returns an empty block on top of parent committing to state's root.
+func child(parent *blockchain.Block, state *blockchain.StateDB) *blockchain.Block {
+	b := blockchain.NewBlock(parent.Index+1, parent.Hash, nil)
+	b.MMRRoot = parent.NextMMRRoot()
+	b.Timestamp = parent.Timestamp.Add(time.Second)
+	b.StateRoot = state.Root()
+	b.Seal()
+	return b
+}
+
+// funded returns a state holding one account with balance.
+func funded(t *testing.T, balance uint64) *blockchain.StateDB {
+	t.Helper()
+	state := blockchain.NewStateDB(amf.NewForest(), amf.DefaultRebalanceConfig)
+	if err := state.SetAccount("0000000000000000000000000000000000000001", blockchain.Account{Balance: balance}); err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+// hasProblem reports whether f has a problem containing substr.
+func hasProblem(f *FileReport, substr string) bool {
+	for _, p := range f.Problems {
+		if strings.Contains(p, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAuditStores(t *testing.T) {
+	dir := t.TempDir()
+	blocks, err := store.Open(filepath.Join(dir, "blocks"), store.Options{Sync: store.SyncNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blocks.Close()
+	snapshots, err := store.OpenSnapshots(filepath.Join(dir, "snapshots"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := blockchain.NewBlock(0, "", nil)
+	genesis.Timestamp = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	genesis.Seal()
+	good, other, corrupt := funded(t, 10), funded(t, 20), funded(t, 30)
+	b1 := child(genesis, good)
+	b2 := child(b1, other)
+	b3 := child(b2, corrupt)
+	// A block whose parent was never stored
+	orphan := child(child(b3, corrupt), corrupt)
+	for _, b := range []*blockchain.Block{genesis, b1, b2, b3, orphan} {
+		if err := blocks.Append(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := snapshots.Save(b1, good); err != nil {
+		t.Fatal(err)
+	}
+	// Taken at b2 but of another state
+	if _, err := snapshots.Save(b2, good); err != nil {
+		t.Fatal(err)
+	}
+	// The file no longer matches its content hash
+	info, err := snapshots.Save(b3, corrupt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(snapshots.Path(info), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Run(Config{Store: blocks, Snapshots: snapshots, StateConfig: amf.DefaultRebalanceConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.StoredBlocks) != 5 || len(report.StoredSnapshots) != 3 {
+		t.Fatalf("audited %d stored blocks and %d snapshots, want 5 and 3", len(report.StoredBlocks), len(report.StoredSnapshots))
+	}
+	for i, f := range report.StoredBlocks[:4] {
+		if len(f.Problems) != 0 {
+			t.Errorf("stored block %d: %v", i, f.Problems)
+		}
+	}
+	if !hasProblem(report.StoredBlocks[4], "parent") {
+		t.Errorf("orphan block: %v", report.StoredBlocks[4].Problems)
+	}
+	if f := report.StoredSnapshots[0]; len(f.Problems) != 0 {
+		t.Errorf("snapshot at block 1: %v", f.Problems)
+	}
+	if f := report.StoredSnapshots[1]; !hasProblem(f, "state root") {
+		t.Errorf("snapshot of another state at block 2: %v", f.Problems)
+	}
+	if f := report.StoredSnapshots[2]; !hasProblem(f, "restore") {
+		t.Errorf("overwritten snapshot at block 3: %v", f.Problems)
+	}
+	if report.Problems != 3 || report.OK() {
+		t.Fatalf("report counts %d problems, want 3", report.Problems)
+	}
+}
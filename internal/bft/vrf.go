@@ -29,6 +29,15 @@ func NewVRF() (*VRF, error) {
 	}, nil
 }
 
+// NewVRFFromKey creates a VRF instance bound to an existing P-256 private key,
+// such as a validator key loaded from the keystore.
+func NewVRFFromKey(privateKey *ecdsa.PrivateKey) *VRF {
+	return &VRF{
+		privateKey: privateKey,
+		publicKey:  &privateKey.PublicKey,
+	}
+}
+
 // Evaluate computes the VRF output and proof for a given input
 func (vrf *VRF) Evaluate(input []byte) ([]byte, []byte, error) {
 	hash := sha256.Sum256(input)
@@ -0,0 +1,62 @@
+package blockchain
+
+// ancestry.go: Ancestry proofs over the Merkle Mountain Range of block hashes
+// Every header commits to the MMR over the hashes of all earlier blocks, so a
+// block's membership in the chain below a head is proven by one MMR proof
+// against the head's MMRRoot instead of a walk over every PrevHash link. Only
+// the head header is needed to check it, however much history was pruned.
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+// ErrBadAncestryProof is returned when an ancestry proof does not match a header.
+var ErrBadAncestryProof = errors.New("ancestry proof does not match header")
+
+// AncestryProof proves that the block with BlockHash is at Height in the chain
+// below the header at height Proof.Size.
+type AncestryProof struct {
+	Height    int
+	BlockHash string
+	Proof     *amf.MMRProof
+}
+
+// NextMMRRoot returns the MMR root a child of b commits to. b must have been
+// added to a block tree.
+func (b *Block) NextMMRRoot() []byte {
+	return b.ancestry.Root()
+}
+
+// ProveAncestry proves that the canonical block at height is an ancestor of the
+// canonical block at height head.
+func (bc *Blockchain) ProveAncestry(height, head int) (*AncestryProof, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if head >= len(bc.Blocks) || height < 0 || height >= head {
+		return nil, fmt.Errorf("no ancestry proof for block %d below %d with %d canonical blocks", height, head, len(bc.Blocks))
+	}
+	proof, err := bc.mmr.Prove(uint64(height), uint64(head))
+	if err != nil {
+		return nil, err
+	}
+	return &AncestryProof{Height: height, BlockHash: bc.Blocks[height].Hash, Proof: proof}, nil
+}
+
+// Verify checks the proof against the header of the descendant it was made for.
+func (p *AncestryProof) Verify(head *BlockHeader) error {
+	if p.Proof == nil || p.Proof.Size != uint64(head.Index) || p.Proof.LeafIndex != uint64(p.Height) {
+		return fmt.Errorf("%w: proof is not for block %d below %d", ErrBadAncestryProof, p.Height, head.Index)
+	}
+	leaf, err := hex.DecodeString(p.BlockHash)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadAncestryProof, err)
+	}
+	if !amf.VerifyMMRProof(head.MMRRoot, leaf, p.Proof) {
+		return ErrBadAncestryProof
+	}
+	return nil
+}
@@ -4,112 +4,84 @@ package blockchain
 // Includes cryptographic accumulators, multi-level Merkle trees, and entropy-based validation.
 
 import (
-	"bytes"
-	"crypto/sha256"
-	"fmt"
+	"encoding/hex"
 	"time"
 
 	"github.com/bilal2134/Blockchain_A3/internal/amf"
 )
 
 // Block defines the core blockchain block with compact state and validation fields.
+// The embedded header is what the block hash commits to.
 type Block struct {
-	Index        int
-	Timestamp    time.Time
-	PrevHash     string
+	BlockHeader
 	Transactions []string
-	Accumulator  []byte   // Compact state representation
-	MultiMerkle  [][]byte // Multi-level Merkle roots
-	Entropy      float64  // Entropy-based validation metric
+	MultiMerkle  [][]byte   // Multi-level Merkle roots: transactions, then header
+	Entropy      float64    // Entropy score of the transactions, see ComputeEntropy
+	Receipts     []*Receipt // Execution receipts, one per transaction
 	Hash         string
-}
-
-type Blockchain struct {
-	Blocks []*Block
+	// ancestry is the MMR over the hashes of the block and every ancestor,
+	// set when the block joins a block tree.
+	ancestry amf.MMRPeaks
 }
 
 // NewBlock creates and initializes a new block with cryptographic accumulator, Merkle root, and entropy.
-func NewBlock(index int, prevHash string, txs []string, _ []byte, _ [][]byte, _ float64) *Block {
+// State root, gas, receipts, consensus fields and, for blocks after the first,
+// the MMR root are filled in afterwards, followed by a call to Seal.
+func NewBlock(index int, prevHash string, txs []string) *Block {
 	b := &Block{
-		Index:        index,
-		Timestamp:    time.Now(),
-		PrevHash:     prevHash,
+		BlockHeader: BlockHeader{
+			Index:     index,
+			Timestamp: time.Now(),
+			PrevHash:  prevHash,
+		},
 		Transactions: txs,
 	}
-	// Accumulator: SHA-256 over concatenated transactions
+	b.Accumulator = txAccumulator(txs)
+	b.TxRoot = TxRoot(txs)
+	b.ReceiptsRoot = ReceiptsRoot(nil)
+	b.MMRRoot = amf.MMRPeaks{}.Root()
+	b.Seal()
+	return b
+}
+
+// Seal recomputes the derived fields: the two-level Merkle roots, the entropy
+// score of the transactions and the block hash. It must be called after any
+// header field changes.
+func (b *Block) Seal() {
+	hdrHash := b.HeaderHash()
+	b.MultiMerkle = [][]byte{b.TxRoot, hdrHash}
+	b.Entropy = ComputeEntropy(b.Transactions).Score
+	b.Hash = hex.EncodeToString(hdrHash)
+}
+
+// txAccumulator returns the digest of the concatenated transactions.
+func txAccumulator(txs []string) []byte {
 	data := ""
 	for _, tx := range txs {
 		data += tx
 	}
-	accHash := sha256.Sum256([]byte(data))
-	b.Accumulator = accHash[:]
+	return hash([]byte(data))
+}
 
-	// Level-1 Merkle: transactions
-	var dataBytes [][]byte
-	for _, tx := range txs {
-		dataBytes = append(dataBytes, []byte(tx))
-	}
-	tree, err := amf.NewMerkleTree(dataBytes)
-	if err != nil {
-		b.MultiMerkle = [][]byte{}
-	} else {
-		level1 := tree.Root.Hash
-		// Level-2 Merkle: hash(headerHash || level1)
-		header := fmt.Sprintf("%d%s%x", index, prevHash, b.Accumulator)
-		hdrHash := sha256.Sum256([]byte(header))
-		lvl2 := sha256.Sum256(append(hdrHash[:], level1...))
-		b.MultiMerkle = [][]byte{level1, lvl2[:]}
-		// Derive entropy from hdrHash
-		b.Entropy = float64(hdrHash[0]) / 255.0
-		// Block hash is second-level root
-		b.Hash = fmt.Sprintf("%x", lvl2[:])
-	}
-	return b
+// ValidationResult is the outcome of ValidateBlock for a block that was not rejected.
+type ValidationResult struct {
+	Metrics EntropyMetrics
+	Flagged error // Why the block is out of entropy bounds, if it is
 }
 
-// ValidateBlock performs entropy-based and cryptographic validation.
-func (b *Block) ValidateBlock() bool {
-	// Verify accumulator
-	data := ""
-	for _, tx := range b.Transactions {
-		data += tx
-	}
-	hash := sha256.Sum256([]byte(data))
-	if !bytes.Equal(hash[:], b.Accumulator) {
-		return false
-	}
-	// Recompute level-1 Merkle
-	var dataBytes [][]byte
-	for _, tx := range b.Transactions {
-		dataBytes = append(dataBytes, []byte(tx))
-	}
-	tree, err := amf.NewMerkleTree(dataBytes)
-	if err != nil || len(b.MultiMerkle) < 2 {
-		return false
+// ValidateBlock performs the cryptographic checks and entropy validation under
+// bounds. A block out of bounds is rejected with ErrLowEntropy if bounds.Reject
+// is set and flagged in the result otherwise. The metrics are reported either way.
+func (b *Block) ValidateBlock(bounds EntropyBounds) (*ValidationResult, error) {
+	res := &ValidationResult{Metrics: ComputeEntropy(b.Transactions)}
+	if err := b.Verify(); err != nil {
+		return res, err
 	}
-	level1 := tree.Root.Hash
-	if !bytes.Equal(level1, b.MultiMerkle[0]) {
-		return false
+	if err := bounds.Check(res.Metrics); err != nil {
+		if bounds.Reject {
+			return res, err
+		}
+		res.Flagged = err
 	}
-	// Recompute level-2 Merkle
-	header := fmt.Sprintf("%d%s%x", b.Index, b.PrevHash, b.Accumulator)
-	hdrHash := sha256.Sum256([]byte(header))
-	lvl2 := sha256.Sum256(append(hdrHash[:], level1...))
-	if !bytes.Equal(lvl2[:], b.MultiMerkle[1]) {
-		return false
-	}
-	// Verify entropy matches header hash
-	if b.Entropy != float64(hdrHash[0])/255.0 {
-		return false
-	}
-	// Verify block hash equals second-level root
-	if fmt.Sprintf("%x", lvl2[:]) != b.Hash {
-		return false
-	}
-	return true
-}
-
-// AddBlock appends a new block to the blockchain.
-func (bc *Blockchain) AddBlock(block *Block) {
-	bc.Blocks = append(bc.Blocks, block)
+	return res, nil
 }
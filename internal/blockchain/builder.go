@@ -0,0 +1,142 @@
+package blockchain
+
+// builder.go: Candidate block assembly
+// Pulls pending transactions, simulates them against a copy of state and stops
+// at the configured byte and gas limits.
+
+import (
+	"fmt"
+	"time"
+)
+
+// Gas schedule for transaction execution.
+const (
+	TxGas     uint64 = 1000 // Flat cost of every transaction
+	TxDataGas uint64 = 16   // Cost per payload byte
+)
+
+// IntrinsicGas returns the gas a transaction consumes.
+func IntrinsicGas(tx *Transaction) uint64 {
+	return TxGas + uint64(len(tx.Payload))*TxDataGas
+}
+
+// PendingPool supplies candidate transactions in inclusion order, e.g. a mempool.
+type PendingPool interface {
+	Pending(limit int) []*Transaction
+}
+
+// BuilderConfig bounds the blocks a Builder assembles. A zero limit is
+// unlimited, as for the mempool.
+type BuilderConfig struct {
+	MaxBytes int    // Maximum total encoded size of block transactions
+	GasLimit uint64 // Maximum total gas of block transactions
+	MaxTxs   int    // Maximum number of transactions
+}
+
+// DefaultBuilderConfig bounds blocks to 1 MiB and 1,000,000 gas.
+var DefaultBuilderConfig = BuilderConfig{
+	MaxBytes: 1 << 20,
+	GasLimit: 1_000_000,
+}
+
+// Builder assembles candidate blocks from a pending transaction pool.
+type Builder struct {
+	cfg   BuilderConfig
+	pool  PendingPool
+	clock Clock       // Stamps built blocks
+	chain *Blockchain // Gives the median time past of parents, if set
+}
+
+// NewBuilder creates a block builder drawing from pool that stamps blocks with
+// the system clock.
+func NewBuilder(cfg BuilderConfig, pool PendingPool) *Builder {
+	return &Builder{cfg: cfg, pool: pool, clock: SystemClock{}}
+}
+
+// SetClock sets the clock built blocks are stamped with.
+func (bld *Builder) SetClock(c Clock) {
+	bld.clock = c
+}
+
+// SetChain has the builder stamp blocks with chain's clock and keep their
+// timestamps past the median time past of their parent in chain.
+func (bld *Builder) SetChain(chain *Blockchain) {
+	bld.chain = chain
+	bld.clock = chain.Clock()
+}
+
+// timestamp returns the time a block on top of parent is stamped with: the
+// clock's time, or if the clock is behind, the earliest time the chain accepts,
+// no earlier than the parent and just past its median time past.
+func (bld *Builder) timestamp(parent *Block) time.Time {
+	now := bld.clock.Now()
+	if parent == nil {
+		return now
+	}
+	earliest := parent.Timestamp
+	if bld.chain != nil {
+		if mtp, err := bld.chain.MedianTimePast(parent.Hash); err == nil && !mtp.Before(earliest) {
+			earliest = mtp.Add(time.Nanosecond)
+		}
+	}
+	if now.Before(earliest) {
+		return earliest
+	}
+	return now
+}
+
+// Build assembles a block on top of parent (nil for the first block). Pending
+// transactions are executed against a copy of state in pool order; invalid ones
+// and ones that would exceed the byte or gas limit are skipped. The returned
+// block carries the resulting state root, gas used and receipts; state itself
+// is left untouched.
+func (bld *Builder) Build(parent *Block, state *StateDB) (*Block, error) {
+	index, prevHash := 0, ""
+	if parent != nil {
+		index, prevHash = parent.Index+1, parent.Hash
+	}
+	sim := state.Copy()
+	var (
+		txs      []string
+		receipts []*Receipt
+		size     int
+		gasUsed  uint64
+	)
+	for _, tx := range bld.pool.Pending(bld.cfg.MaxTxs) {
+		if bld.cfg.GasLimit > 0 && bld.cfg.GasLimit-gasUsed < TxGas {
+			break
+		}
+		enc := tx.Encode()
+		gas := IntrinsicGas(tx)
+		if bld.cfg.MaxBytes > 0 && size+len(enc) > bld.cfg.MaxBytes {
+			continue
+		}
+		if bld.cfg.GasLimit > 0 && gasUsed+gas > bld.cfg.GasLimit {
+			continue
+		}
+		receipt, err := sim.ApplyTransaction(tx)
+		if err != nil {
+			continue
+		}
+		gasUsed += receipt.GasUsed
+		receipt.CumulativeGasUsed = gasUsed
+		size += len(enc)
+		txs = append(txs, enc)
+		receipts = append(receipts, receipt)
+	}
+	if len(txs) == 0 {
+		return nil, fmt.Errorf("no executable pending transactions")
+	}
+	block := NewBlock(index, prevHash, txs)
+	block.Timestamp = bld.timestamp(parent)
+	if parent != nil {
+		block.MMRRoot = parent.NextMMRRoot()
+	}
+	block.StateRoot = sim.Root()
+	block.GasUsed = gasUsed
+	block.Receipts = receipts
+	block.ReceiptsRoot = ReceiptsRoot(receipts)
+	block.LogsBloom = LogsBloom(receipts)
+	block.Seal()
+	return block, nil
+}
@@ -0,0 +1,43 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+func TestBuilderLimits(t *testing.T) {
+	keys, addrs := newTestKeys(t, 1)
+	state := NewStateDB(amf.NewForest(), amf.DefaultRebalanceConfig)
+	if err := state.SetAccount(addrs[0], Account{Balance: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	var pool slicePool
+	for nonce := uint64(0); nonce < 5; nonce++ {
+		tx := NewTransaction(addrs[0], "0000000000000000000000000000000000000001", 1, 1, nonce, nil)
+		if err := tx.Sign(keys); err != nil {
+			t.Fatal(err)
+		}
+		pool = append(pool, tx)
+	}
+	size := len(pool[0].Encode())
+	for _, tt := range []struct {
+		name string
+		cfg  BuilderConfig
+		want int
+	}{
+		{"zero limits", BuilderConfig{}, 5},
+		{"transactions", BuilderConfig{MaxTxs: 2}, 2},
+		{"gas", BuilderConfig{GasLimit: 3 * TxGas}, 3},
+		{"bytes", BuilderConfig{MaxBytes: 4 * size}, 4},
+		{"gas with zero bytes", BuilderConfig{GasLimit: 3*TxGas + 1}, 3},
+	} {
+		block, err := NewBuilder(tt.cfg, pool).Build(nil, state)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(block.Transactions) != tt.want {
+			t.Errorf("%s: built %d transactions, want %d", tt.name, len(block.Transactions), tt.want)
+		}
+	}
+}
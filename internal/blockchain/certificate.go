@@ -0,0 +1,100 @@
+package blockchain
+
+// certificate.go: Consensus attestations and finality certificates
+// A certificate collects the validator votes that finalized a block; the next
+// block commits to it through its header's CertHash. Each vote is signed with
+// the validator's ed25519 key, so only the validators can produce one.
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrBadAttestation is returned for an attestation whose signature does not verify.
+var ErrBadAttestation = errors.New("invalid attestation signature")
+
+// Attestation is one validator's vote for a block in a consensus round.
+type Attestation struct {
+	Validator string
+	Round     uint64
+	BlockHash string
+	Signature []byte // Validator's ed25519 signature over SigningBytes
+}
+
+// NewAttestation returns validator's vote for blockHash in round, signed by signer.
+func NewAttestation(validator string, round uint64, blockHash string, signer Signer) (Attestation, error) {
+	a := Attestation{Validator: validator, Round: round, BlockHash: blockHash}
+	sig, err := signer.Sign(validator, a.SigningBytes())
+	if err != nil {
+		return Attestation{}, err
+	}
+	a.Signature = sig
+	return a, nil
+}
+
+// SigningBytes returns the encoding covered by the signature: the block hash
+// and round voted for, under a prefix no transaction encoding starts with.
+func (a *Attestation) SigningBytes() []byte {
+	var e encoder
+	e.string("attestation")
+	e.string(a.BlockHash)
+	e.uint64(a.Round)
+	return e.buf
+}
+
+// Verify checks the signature against the validator's public key.
+func (a *Attestation) Verify(publicKey []byte) error {
+	if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, a.SigningBytes(), a.Signature) {
+		return fmt.Errorf("%w: by %s", ErrBadAttestation, a.Validator)
+	}
+	return nil
+}
+
+// Encode returns the canonical binary encoding of the attestation.
+func (a *Attestation) Encode() []byte {
+	var e encoder
+	e.string(a.Validator)
+	e.uint64(a.Round)
+	e.string(a.BlockHash)
+	e.bytes(a.Signature)
+	return e.buf
+}
+
+// Certificate is the set of attestations that finalized a block.
+type Certificate struct {
+	Round        uint64
+	BlockHash    string
+	Attestations []Attestation // Sorted by validator
+}
+
+// NewCertificate builds a certificate for blockHash from the attestations of
+// the validators that voted for it, sorting them so equal vote sets give equal
+// certificates.
+func NewCertificate(round uint64, blockHash string, attestations []Attestation) *Certificate {
+	sorted := append([]Attestation(nil), attestations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Validator < sorted[j].Validator })
+	return &Certificate{Round: round, BlockHash: blockHash, Attestations: sorted}
+}
+
+// Encode returns the canonical binary encoding of the certificate.
+func (c *Certificate) Encode() []byte {
+	var e encoder
+	e.uint64(c.Round)
+	e.string(c.BlockHash)
+	e.uint64(uint64(len(c.Attestations)))
+	for i := range c.Attestations {
+		e.bytes(c.Attestations[i].Encode())
+	}
+	return e.buf
+}
+
+// Hash returns the digest of the encoded certificate; a nil certificate
+// hashes to nil, as used by blocks whose parent has none.
+func (c *Certificate) Hash() []byte {
+	if c == nil {
+		return nil
+	}
+	return hash(c.Encode())
+}
@@ -0,0 +1,369 @@
+package blockchain
+
+// chain.go: Block tree with fork choice and chain reorganization
+// Keeps every valid block indexed by hash, including side chains, and switches
+// the canonical chain when the fork-choice rule prefers another branch.
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+var (
+	// ErrKnownBlock is returned when adding a block that is already in the tree.
+	ErrKnownBlock = errors.New("block already known")
+	// ErrInvalidBlock is returned when re-adding a block that a listener rejected.
+	ErrInvalidBlock = errors.New("block previously rejected")
+	// ErrInvalidAncestor is returned for blocks building on a rejected block.
+	ErrInvalidAncestor = errors.New("block builds on an invalid block")
+)
+
+// BlockNode is a block's position in the block tree.
+type BlockNode struct {
+	Block  *Block
+	Parent *BlockNode
+	Height int
+	Weight uint64 // Cumulative fork-choice weight from the first block
+}
+
+// ForkChoice decides which branch of the block tree is canonical.
+type ForkChoice interface {
+	// Weight returns the contribution of a block to its branch's weight.
+	Weight(b *Block) uint64
+	// Prefer reports whether candidate should replace current as the head.
+	Prefer(candidate, current *BlockNode) bool
+}
+
+// LongestChain prefers the branch with the most blocks; ties keep the current head.
+type LongestChain struct{}
+
+// Weight counts every block equally.
+func (LongestChain) Weight(*Block) uint64 { return 1 }
+
+// Prefer selects the taller branch.
+func (LongestChain) Prefer(candidate, current *BlockNode) bool {
+	return current == nil || candidate.Height > current.Height
+}
+
+// HeaviestChain prefers the branch with the greatest cumulative weight; ties keep
+// the current head. BlockWeight defaults to one plus the gas a block consumed.
+type HeaviestChain struct {
+	BlockWeight func(*Block) uint64
+}
+
+// Weight returns the configured weight of a block.
+func (h HeaviestChain) Weight(b *Block) uint64 {
+	if h.BlockWeight != nil {
+		return h.BlockWeight(b)
+	}
+	return 1 + b.GasUsed
+}
+
+// Prefer selects the heavier branch.
+func (HeaviestChain) Prefer(candidate, current *BlockNode) bool {
+	return current == nil || candidate.Weight > current.Weight
+}
+
+// ChainEvent describes a change of the canonical chain. Disconnected blocks are
+// listed newest first, connected blocks oldest first; a plain extension of the
+// head has no disconnected blocks.
+type ChainEvent struct {
+	Disconnected []*Block
+	Connected    []*Block
+}
+
+// ChainListener is notified of canonical chain changes. Returning an error
+// (e.g. because a connected block fails to execute) aborts the switch: the
+// previous head is restored and the offending branch is marked invalid. A
+// listener that fails must leave its own state as it was before the event.
+// Listeners run while the chain is locked and must not call back into it.
+type ChainListener func(ev ChainEvent) error
+
+// Blockchain is a tree of blocks indexed by hash with a canonical chain
+// selected by a fork-choice rule.
+type Blockchain struct {
+	Blocks     []*Block // Canonical chain, first block at index 0
+	mu         sync.RWMutex
+	nodes      map[string]*BlockNode
+	head       *BlockNode
+	invalid    map[string]struct{} // Hashes of blocks rejected by a listener
+	forkChoice ForkChoice
+	listeners  []ChainListener
+	mmr        *amf.MMR // Over the hashes of the canonical chain, for ancestry proofs
+	genesis    string   // Required hash of the first block, if pinned
+	clock      Clock    // Timestamps are checked against it; the system clock if nil
+	timeRules  TimestampRules
+}
+
+// NewBlockchain creates an empty block tree using the given fork-choice rule
+// (LongestChain if nil).
+func NewBlockchain(fc ForkChoice) *Blockchain {
+	if fc == nil {
+		fc = LongestChain{}
+	}
+	return &Blockchain{
+		Blocks:     []*Block{},
+		nodes:      make(map[string]*BlockNode),
+		invalid:    make(map[string]struct{}),
+		forkChoice: fc,
+		clock:      SystemClock{},
+		timeRules:  DefaultTimestampRules,
+	}
+}
+
+// Subscribe registers a listener for canonical chain changes.
+func (bc *Blockchain) Subscribe(l ChainListener) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.listeners = append(bc.listeners, l)
+}
+
+// SetGenesis pins the hash of the first block: a first block with any other
+// hash is rejected with ErrGenesisMismatch.
+func (bc *Blockchain) SetGenesis(hash string) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.genesis = hash
+}
+
+// Genesis returns the pinned hash of the first block, or "" if none is pinned.
+func (bc *Blockchain) Genesis() string {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.genesis
+}
+
+// Head returns the head of the canonical chain, or nil for an empty chain.
+func (bc *Blockchain) Head() *Block {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if bc.head == nil {
+		return nil
+	}
+	return bc.head.Block
+}
+
+// GetBlock returns any known block, canonical or not, by hash.
+func (bc *Blockchain) GetBlock(hash string) (*Block, bool) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	node, ok := bc.nodes[hash]
+	if !ok {
+		return nil, false
+	}
+	return node.Block, true
+}
+
+// BlockAt returns the canonical block at height.
+func (bc *Blockchain) BlockAt(height int) (*Block, bool) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	if height < 0 || height >= len(bc.Blocks) {
+		return nil, false
+	}
+	return bc.Blocks[height], true
+}
+
+// GetNode returns the tree node for a block hash.
+func (bc *Blockchain) GetNode(hash string) (*BlockNode, bool) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	node, ok := bc.nodes[hash]
+	return node, ok
+}
+
+// IsCanonical reports whether the block with hash is on the canonical chain.
+func (bc *Blockchain) IsCanonical(hash string) bool {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	node, ok := bc.nodes[hash]
+	return ok && node.Height < len(bc.Blocks) && bc.Blocks[node.Height] == node.Block
+}
+
+// Tips returns the leaf nodes of the tree: the canonical head and every side-chain tip.
+func (bc *Blockchain) Tips() []*BlockNode {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	hasChild := make(map[*BlockNode]bool, len(bc.nodes))
+	for _, n := range bc.nodes {
+		if n.Parent != nil {
+			hasChild[n.Parent] = true
+		}
+	}
+	var tips []*BlockNode
+	for _, n := range bc.nodes {
+		if !hasChild[n] {
+			tips = append(tips, n)
+		}
+	}
+	return tips
+}
+
+// AddBlock validates a block against its parent and the timestamp rules and
+// inserts it into the tree, reorganizing the canonical chain if the fork-choice rule prefers the new
+// branch. Validation failures are returned as a *BlockError wrapping one of
+// the Err* values.
+func (bc *Blockchain) AddBlock(block *Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.nodes == nil {
+		bc.nodes = make(map[string]*BlockNode)
+		bc.invalid = make(map[string]struct{})
+	}
+	if bc.forkChoice == nil {
+		bc.forkChoice = LongestChain{}
+	}
+	if _, ok := bc.nodes[block.Hash]; ok {
+		return blockError(block, ErrKnownBlock)
+	}
+	if _, ok := bc.invalid[block.Hash]; ok {
+		return blockError(block, ErrInvalidBlock)
+	}
+	if _, ok := bc.invalid[block.PrevHash]; ok {
+		return blockError(block, ErrInvalidAncestor)
+	}
+
+	var parent *BlockNode
+	if block.Index != 0 || len(bc.nodes) > 0 {
+		p, ok := bc.nodes[block.PrevHash]
+		if !ok {
+			return blockError(block, fmt.Errorf("%w: unknown parent %s", ErrBadParent, block.PrevHash))
+		}
+		parent = p
+	} else if bc.genesis != "" && block.Hash != bc.genesis {
+		return blockError(block, fmt.Errorf("%w: first block %s, want %s", ErrGenesisMismatch, block.Hash, bc.genesis))
+	}
+	var parentBlock *Block
+	if parent != nil {
+		parentBlock = parent.Block
+	}
+	if err := ValidateChild(parentBlock, block); err != nil {
+		return err
+	}
+	if err := bc.checkTimestamp(parent, block); err != nil {
+		return err
+	}
+	var ancestry amf.MMRPeaks
+	if parentBlock != nil {
+		ancestry = parentBlock.ancestry
+	}
+	block.ancestry = ancestry.Append(block.HeaderHash())
+
+	node := &BlockNode{Block: block, Parent: parent, Weight: bc.forkChoice.Weight(block)}
+	if parent != nil {
+		node.Height = parent.Height + 1
+		node.Weight += parent.Weight
+	}
+	bc.nodes[block.Hash] = node
+	if bc.head != nil && !bc.forkChoice.Prefer(node, bc.head) {
+		return nil // stored as a side chain
+	}
+	return bc.setHead(node)
+}
+
+// setHead makes node the canonical head and notifies listeners; bc.mu must be held.
+func (bc *Blockchain) setHead(node *BlockNode) error {
+	oldHead := bc.head
+	ev := reorgEvent(oldHead, node)
+	bc.switchTo(node)
+	for i, l := range bc.listeners {
+		if err := l(ev); err != nil {
+			// Undo for listeners that already applied the event, then restore the old head.
+			undo := ChainEvent{Disconnected: reverse(ev.Connected), Connected: reverse(ev.Disconnected)}
+			for _, prev := range bc.listeners[:i] {
+				prev(undo)
+			}
+			bc.switchTo(oldHead)
+			bc.markInvalid(node, ev.Connected, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// switchTo rewrites the canonical slice, and the MMR over it, to end at node.
+func (bc *Blockchain) switchTo(node *BlockNode) {
+	bc.head = node
+	if bc.mmr == nil {
+		bc.mmr = amf.NewMMR()
+	}
+	if node == nil {
+		bc.Blocks = bc.Blocks[:0]
+		bc.mmr.Truncate(0)
+		return
+	}
+	chain := make([]*Block, node.Height+1)
+	for n := node; n != nil; n = n.Parent {
+		chain[n.Height] = n.Block
+	}
+	fork := 0
+	for fork < len(bc.Blocks) && fork < len(chain) && bc.Blocks[fork] == chain[fork] {
+		fork++
+	}
+	bc.mmr.Truncate(uint64(fork))
+	for _, b := range chain[fork:] {
+		bc.mmr.Append(b.HeaderHash())
+	}
+	bc.Blocks = chain
+}
+
+// markInvalid removes the connected block a listener rejected (or the new tip
+// if the error does not identify one) together with all of its descendants,
+// and remembers their hashes so they are not accepted again.
+func (bc *Blockchain) markInvalid(tip *BlockNode, connected []*Block, err error) {
+	bad := tip
+	var be *BlockError
+	if errors.As(err, &be) {
+		for _, b := range connected {
+			if b.Hash == be.Hash {
+				bad = bc.nodes[b.Hash]
+			}
+		}
+	}
+	for hash, n := range bc.nodes {
+		for a := n; a != nil; a = a.Parent {
+			if a == bad {
+				delete(bc.nodes, hash)
+				bc.invalid[hash] = struct{}{}
+				break
+			}
+		}
+	}
+}
+
+// reorgEvent computes the blocks leaving and joining the canonical chain when
+// the head moves from oldHead to newHead.
+func reorgEvent(oldHead, newHead *BlockNode) ChainEvent {
+	var ev ChainEvent
+	a, b := oldHead, newHead
+	for a != nil && b != nil && a.Height > b.Height {
+		ev.Disconnected = append(ev.Disconnected, a.Block)
+		a = a.Parent
+	}
+	for b != nil && (a == nil || b.Height > a.Height) {
+		ev.Connected = append(ev.Connected, b.Block)
+		b = b.Parent
+	}
+	for a != nil && b != nil && a != b {
+		ev.Disconnected = append(ev.Disconnected, a.Block)
+		ev.Connected = append(ev.Connected, b.Block)
+		a, b = a.Parent, b.Parent
+	}
+	for ; a != nil && b == nil; a = a.Parent {
+		ev.Disconnected = append(ev.Disconnected, a.Block)
+	}
+	ev.Connected = reverse(ev.Connected)
+	return ev
+}
+
+// reverse returns a reversed copy of blocks.
+func reverse(blocks []*Block) []*Block {
+	out := make([]*Block, len(blocks))
+	for i, b := range blocks {
+		out[len(blocks)-1-i] = b
+	}
+	return out
+}
@@ -0,0 +1,186 @@
+package blockchain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+// at returns the time s seconds after start.
+func at(s float64) time.Time {
+	return start.Add(time.Duration(s * float64(time.Second)))
+}
+
+// stateChild returns an empty block on top of parent that executes on state.
+func stateChild(parent *Block, state *StateDB, ts time.Time) *Block {
+	var b *Block
+	if parent == nil {
+		b = NewBlock(0, "", nil)
+	} else {
+		b = NewBlock(parent.Index+1, parent.Hash, nil)
+		b.MMRRoot = parent.NextMMRRoot()
+	}
+	b.Timestamp = ts
+	b.StateRoot = state.Root()
+	b.ReceiptsRoot = ReceiptsRoot(nil)
+	b.LogsBloom = LogsBloom(nil)
+	b.Seal()
+	return b
+}
+
+// hashes returns the hashes of blocks, for comparing events.
+func hashes(blocks []*Block) string {
+	out := make([]string, len(blocks))
+	for i, b := range blocks {
+		out[i] = b.Hash
+	}
+	return strings.Join(out, ",")
+}
+
+// add adds b to bc and returns it. A child's MMR root is only known once
+// its parent has been added.
+func add(t *testing.T, bc *Blockchain, b *Block) *Block {
+	t.Helper()
+	if err := bc.AddBlock(b); err != nil {
+		t.Fatalf("block %d: %v", b.Index, err)
+	}
+	return b
+}
+
+func TestSideChainOvertakesHead(t *testing.T) {
+	bc := NewBlockchain(LongestChain{})
+	var events []ChainEvent
+	bc.Subscribe(func(ev ChainEvent) error {
+		events = append(events, ev)
+		return nil
+	})
+	g := add(t, bc, childAt(nil, at(0)))
+	a1 := add(t, bc, childAt(g, at(1)))
+	a2 := add(t, bc, childAt(a1, at(2)))
+	if len(events) != 3 || len(events[2].Disconnected) != 0 || hashes(events[2].Connected) != a2.Hash {
+		t.Fatalf("extending the head: %d events, last %+v", len(events), events[len(events)-1])
+	}
+
+	b1 := add(t, bc, childAt(g, at(1.5)))
+	b2 := add(t, bc, childAt(b1, at(2.5)))
+	if len(events) != 3 || bc.Head() != a2 {
+		t.Fatalf("side chain no longer than the head moved it: %d events, head %d", len(events), bc.Head().Index)
+	}
+	b3 := add(t, bc, childAt(b2, at(3.5)))
+	if len(events) != 4 {
+		t.Fatalf("%d events after the side chain overtook the head, want 4", len(events))
+	}
+	ev := events[3]
+	if got, want := hashes(ev.Disconnected), hashes([]*Block{a2, a1}); got != want {
+		t.Errorf("disconnected %s, want %s", got, want)
+	}
+	if got, want := hashes(ev.Connected), hashes([]*Block{b1, b2, b3}); got != want {
+		t.Errorf("connected %s, want %s", got, want)
+	}
+	if bc.Head() != b3 || !bc.IsCanonical(b1.Hash) || bc.IsCanonical(a1.Hash) {
+		t.Fatalf("head %s after the reorg, want %s", bc.Head().Hash, b3.Hash)
+	}
+	if got, ok := bc.BlockAt(1); !ok || got != b1 {
+		t.Fatal("canonical block 1 is not on the new branch")
+	}
+}
+
+func TestListenerErrorRollsBackHead(t *testing.T) {
+	bc := NewBlockchain(LongestChain{})
+	var events []ChainEvent
+	bc.Subscribe(func(ev ChainEvent) error {
+		events = append(events, ev)
+		return nil
+	})
+	g := add(t, bc, childAt(nil, at(0)))
+	a1 := add(t, bc, childAt(g, at(1)))
+	a2 := add(t, bc, childAt(a1, at(2)))
+	b1 := add(t, bc, childAt(g, at(1.5)))
+	b2 := add(t, bc, childAt(b1, at(2.5)))
+	b3 := childAt(b2, at(3.5))
+	rejected := errors.New("rejected")
+	bc.Subscribe(func(ev ChainEvent) error {
+		for _, b := range ev.Connected {
+			if b == b2 {
+				return blockError(b, rejected)
+			}
+		}
+		return nil
+	})
+
+	if err := bc.AddBlock(b3); !errors.Is(err, rejected) {
+		t.Fatalf("reorg through a rejected block: %v", err)
+	}
+	if bc.Head() != a2 || len(bc.Blocks) != 3 || bc.Blocks[1] != a1 {
+		t.Fatalf("head %d after a failed reorg, want block 2 of the old branch", bc.Head().Index)
+	}
+	// The listener that applied the reorg was told to undo it
+	undo := events[len(events)-1]
+	if hashes(undo.Disconnected) != hashes([]*Block{b3, b2, b1}) || hashes(undo.Connected) != hashes([]*Block{a1, a2}) {
+		t.Fatalf("undo event disconnects %s and connects %s", hashes(undo.Disconnected), hashes(undo.Connected))
+	}
+
+	// The rejected block and its descendants are dropped, its parent is kept
+	if _, ok := bc.GetBlock(b1.Hash); !ok {
+		t.Fatal("parent of the rejected block was dropped")
+	}
+	for _, b := range []*Block{b2, b3} {
+		if _, ok := bc.GetBlock(b.Hash); ok {
+			t.Fatalf("block %d of the rejected branch kept", b.Index)
+		}
+		if err := bc.AddBlock(b); !errors.Is(err, ErrInvalidBlock) {
+			t.Fatalf("re-adding block %d: %v", b.Index, err)
+		}
+	}
+	if err := bc.AddBlock(childAt(b3, at(4.5))); !errors.Is(err, ErrInvalidAncestor) {
+		t.Fatalf("child of a rejected block: %v", err)
+	}
+	// The parent can still grow another branch
+	add(t, bc, childAt(b1, at(2.7)))
+	add(t, bc, childAt(a2, at(3)))
+}
+
+func TestStateFollowsFailedReorg(t *testing.T) {
+	state := NewStateDB(amf.NewForest(), amf.DefaultRebalanceConfig)
+	bc := NewBlockchain(LongestChain{})
+	bc.Subscribe(state.OnChainEvent)
+	g := add(t, bc, stateChild(nil, state, at(0)))
+	a1 := add(t, bc, stateChild(g, state, at(1)))
+	a2 := add(t, bc, stateChild(a1, state, at(2)))
+
+	b1 := add(t, bc, stateChild(g, state, at(1.5)))
+	b2 := stateChild(b1, state, at(2.5))
+	b2.StateRoot = []byte("not the executed root")
+	b2.Seal()
+	add(t, bc, b2)
+	b3 := stateChild(b2, state, at(3.5))
+	if err := bc.AddBlock(b3); err == nil || strings.Contains(err.Error(), "could not be restored") {
+		t.Fatalf("reorg through a block that fails to execute: %v", err)
+	}
+	if got, want := hashes(state.applied), hashes([]*Block{g, a1, a2}); got != want {
+		t.Fatalf("state applied %s after the failed reorg, want %s", got, want)
+	}
+
+	// A disconnected block that no longer executes leaves the state unrestored
+	c1 := add(t, bc, stateChild(g, state, at(1.2)))
+	c2 := stateChild(c1, state, at(2.2))
+	c2.StateRoot = []byte("not the executed root")
+	c2.Seal()
+	add(t, bc, c2)
+	c3 := stateChild(c2, state, at(3.2))
+	a1.GasUsed++
+	err := bc.AddBlock(c3)
+	if err == nil || !strings.Contains(err.Error(), "could not be restored") {
+		t.Fatalf("failed restore not reported: %v", err)
+	}
+	var be *BlockError
+	if !errors.As(err, &be) || be.Hash != c2.Hash {
+		t.Fatalf("error %v does not name the failing block", err)
+	}
+	if len(state.applied) != 1 {
+		t.Fatalf("state applied %d blocks after the failed restore, want only the fork point", len(state.applied))
+	}
+}
@@ -0,0 +1,87 @@
+package blockchain
+
+// codec.go: Deterministic binary encoding helpers
+// Fixed-width big-endian integers and length-prefixed byte strings.
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	// errShortBuffer is returned when decoding runs past the end of the input.
+	errShortBuffer = errors.New("encoding: unexpected end of input")
+	// errUnknownVersion is returned for an encoding with an unsupported version.
+	errUnknownVersion = errors.New("encoding: unknown version")
+)
+
+// encoder appends fields to a byte buffer.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, v)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+// decoder reads fields written by encoder, recording the first error.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) uint64() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.buf) < 8 {
+		d.err = errShortBuffer
+		return 0
+	}
+	v := binary.BigEndian.Uint64(d.buf)
+	d.buf = d.buf[8:]
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.buf) < 4 {
+		d.err = errShortBuffer
+		return nil
+	}
+	n := binary.BigEndian.Uint32(d.buf)
+	d.buf = d.buf[4:]
+	if uint32(len(d.buf)) < n {
+		d.err = errShortBuffer
+		return nil
+	}
+	b := append([]byte{}, d.buf[:n]...)
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+// finish returns the decoding error, or an error if input remains unread.
+func (d *decoder) finish() error {
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.buf) != 0 {
+		return errors.New("encoding: trailing bytes")
+	}
+	return nil
+}
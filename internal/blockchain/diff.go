@@ -0,0 +1,197 @@
+package blockchain
+
+// diff.go: Per-block state diffs
+// A diff lists every account a block changed with its value before and after,
+// compressed with DEFLATE. Applying the diffs of consecutive blocks to a full
+// snapshot rebuilds the state at any later height.
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+// diffVersion is written first in every encoded diff.
+const diffVersion = 1
+
+// Flags recording which sides of an account change hold a value.
+const (
+	diffHasOld = 1 << iota
+	diffHasNew
+)
+
+var (
+	// ErrDiffUnavailable is returned when the journal no longer holds a block's changes.
+	ErrDiffUnavailable = errors.New("state changes of block no longer journaled")
+	// ErrDiffMismatch is returned when a diff does not fit the state it is applied to.
+	ErrDiffMismatch = errors.New("state diff does not match state")
+)
+
+// AccountChange is the change a block made to one account. A nil Old means the
+// account did not exist before; a nil New means it was deleted.
+type AccountChange struct {
+	Address string
+	Old     *Account
+	New     *Account
+}
+
+// StateDiff is the set of account changes made by one block.
+type StateDiff struct {
+	Height    int    // Index of the block
+	BlockHash string // Hash of the block
+	StateRoot []byte // State root after the block, checked when applying
+	Changes   []AccountChange
+}
+
+// DiffAt returns the changes block made to the state, sorted by address. The
+// block must still be journaled, i.e. executed and not yet pruned.
+func (s *StateDB) DiffAt(block *Block) (*StateDiff, error) {
+	if block.Index <= s.Journal.Floor() {
+		return nil, fmt.Errorf("%w: block %d", ErrDiffUnavailable, block.Index)
+	}
+	// The first entry for a key at the block holds its value before the block;
+	// the first entry after the block, or else the current state, its value after.
+	before := make(map[string]amf.JournalEntry)
+	after := make(map[string]amf.JournalEntry)
+	for _, e := range s.Journal.Since(block.Index) {
+		if e.Height == block.Index {
+			if _, ok := before[e.Key]; !ok {
+				before[e.Key] = e
+			}
+		} else if _, ok := after[e.Key]; !ok {
+			after[e.Key] = e
+		}
+	}
+	diff := &StateDiff{Height: block.Index, BlockHash: block.Hash, StateRoot: block.StateRoot}
+	for key, old := range before {
+		addr, ok := strings.CutPrefix(key, accountPrefix)
+		if !ok {
+			return nil, fmt.Errorf("unexpected state entry %q", key)
+		}
+		change := AccountChange{Address: addr}
+		if old.Existed {
+			acct, _ := old.Prev.(Account)
+			change.Old = &acct
+		}
+		if next, ok := after[key]; ok {
+			if next.Existed {
+				acct, _ := next.Prev.(Account)
+				change.New = &acct
+			}
+		} else if v, ok := s.Forest.Get(key); ok {
+			acct, _ := v.(Account)
+			change.New = &acct
+		}
+		if change.Old != nil && change.New != nil && *change.Old == *change.New {
+			continue
+		}
+		if change.Old == nil && change.New == nil {
+			continue
+		}
+		diff.Changes = append(diff.Changes, change)
+	}
+	sort.Slice(diff.Changes, func(i, j int) bool { return diff.Changes[i].Address < diff.Changes[j].Address })
+	return diff, nil
+}
+
+// Apply moves s from the state before the diff's block to the state after it,
+// checking every old value and the resulting state root.
+func (d *StateDiff) Apply(s *StateDB) error {
+	for _, c := range d.Changes {
+		v, ok := s.Forest.Get(AccountKey(c.Address))
+		acct, _ := v.(Account)
+		if ok != (c.Old != nil) || (ok && acct != *c.Old) {
+			return fmt.Errorf("%w: account %s at block %d", ErrDiffMismatch, c.Address, d.Height)
+		}
+	}
+	for _, c := range d.Changes {
+		if c.New == nil {
+			s.Forest.Delete(AccountKey(c.Address))
+			continue
+		}
+		if err := s.SetAccount(c.Address, *c.New); err != nil {
+			return err
+		}
+	}
+	if got := s.Root(); !bytes.Equal(got, d.StateRoot) {
+		return fmt.Errorf("%w: block %d: got %x, want %x", ErrStateRootMismatch, d.Height, got, d.StateRoot)
+	}
+	return nil
+}
+
+// Encode returns the compressed encoding of the diff.
+func (d *StateDiff) Encode() ([]byte, error) {
+	var e encoder
+	e.uint64(diffVersion)
+	e.uint64(uint64(d.Height))
+	e.string(d.BlockHash)
+	e.bytes(d.StateRoot)
+	e.uint64(uint64(len(d.Changes)))
+	for _, c := range d.Changes {
+		e.string(c.Address)
+		var flags uint64
+		if c.Old != nil {
+			flags |= diffHasOld
+		}
+		if c.New != nil {
+			flags |= diffHasNew
+		}
+		e.uint64(flags)
+		for _, acct := range []*Account{c.Old, c.New} {
+			if acct != nil {
+				e.uint64(acct.Balance)
+				e.uint64(acct.Nonce)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(e.buf); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeStateDiff decodes a diff written by Encode.
+func DecodeStateDiff(data []byte) (*StateDiff, error) {
+	raw, err := io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return nil, fmt.Errorf("state diff: %w", err)
+	}
+	d := decoder{buf: raw}
+	if v := d.uint64(); d.err == nil && v != diffVersion {
+		return nil, errUnknownVersion
+	}
+	diff := &StateDiff{Height: int(d.uint64()), BlockHash: d.string(), StateRoot: d.bytes()}
+	n := d.uint64()
+	if d.err == nil && n > uint64(len(raw)) {
+		return nil, errShortBuffer
+	}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		c := AccountChange{Address: d.string()}
+		flags := d.uint64()
+		if flags&diffHasOld != 0 {
+			c.Old = &Account{Balance: d.uint64(), Nonce: d.uint64()}
+		}
+		if flags&diffHasNew != 0 {
+			c.New = &Account{Balance: d.uint64(), Nonce: d.uint64()}
+		}
+		diff.Changes = append(diff.Changes, c)
+	}
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
@@ -0,0 +1,135 @@
+package blockchain
+
+// entropy.go: Shannon-entropy metrics over block contents
+// Spam blocks tend to repeat one transaction shape from a handful of senders.
+// The metrics below measure how varied a block's transactions, payload bytes
+// and senders are, and EntropyBounds turns them into an acceptance rule.
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ErrLowEntropy is returned for a block whose contents fall below the entropy bounds.
+var ErrLowEntropy = errors.New("block entropy below bounds")
+
+// EntropyMetrics describes the diversity of a block's transactions. Every
+// normalized value is in [0, 1]; blocks with fewer than two transactions score 1.
+type EntropyMetrics struct {
+	Txs             int     // Number of transactions
+	PayloadBytes    int     // Total payload size
+	PayloadEntropy  float64 // Shannon entropy of the payload byte distribution, in bits per byte (0-8)
+	ContentEntropy  float64 // Normalized entropy of the distribution of distinct transaction contents
+	SenderEntropy   float64 // Normalized entropy of the distribution of senders
+	SenderDiversity float64 // Distinct senders per transaction
+	Score           float64 // Mean of the normalized measures, the block's Entropy
+}
+
+// EntropyBounds are the consensus parameters for entropy validation. Blocks
+// with fewer than MinTxs transactions are too small to judge and always pass.
+type EntropyBounds struct {
+	MinTxs             int
+	MinScore           float64
+	MinContentEntropy  float64
+	MinSenderEntropy   float64
+	MinSenderDiversity float64 // Catches a few senders filling a block evenly, which sender entropy misses
+	Reject             bool    // Reject blocks out of bounds rather than only flag them
+}
+
+// DefaultEntropyBounds flags, without rejecting, blocks of at least 8
+// transactions that are mostly copies of one transaction, from one sender or
+// from senders averaging more than 20 transactions each.
+var DefaultEntropyBounds = EntropyBounds{
+	MinTxs:             8,
+	MinScore:           0.25,
+	MinContentEntropy:  0.1,
+	MinSenderEntropy:   0.1,
+	MinSenderDiversity: 0.05,
+}
+
+// shannon returns the Shannon entropy in bits of a distribution given by counts.
+// Terms are summed in sorted order so the result does not depend on map order.
+func shannon[K comparable](counts map[K]int, total int) float64 {
+	sorted := make([]int, 0, len(counts))
+	for _, c := range counts {
+		sorted = append(sorted, c)
+	}
+	sort.Ints(sorted)
+	var h float64
+	for _, c := range sorted {
+		p := float64(c) / float64(total)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// normalized scales an entropy over n samples to [0, 1] by its maximum, log2(n).
+func normalized(h float64, n int) float64 {
+	if n < 2 {
+		return 1
+	}
+	return math.Min(h/math.Log2(float64(n)), 1)
+}
+
+// ComputeEntropy measures the encoded transactions txs. Transactions that do
+// not decode count as content from an unknown sender.
+func ComputeEntropy(txs []string) EntropyMetrics {
+	m := EntropyMetrics{Txs: len(txs)}
+	contents := make(map[[sha256.Size]byte]int)
+	senders := make(map[string]int)
+	payload := make(map[byte]int)
+	for _, s := range txs {
+		tx, err := DecodeTransaction(s)
+		if err != nil {
+			contents[sha256.Sum256([]byte(s))]++
+			senders[""]++
+			continue
+		}
+		// Content is what the sender chose: recipient, amount and payload
+		content := binary.BigEndian.AppendUint64([]byte(tx.To), tx.Amount)
+		contents[sha256.Sum256(append(content, tx.Payload...))]++
+		senders[tx.From]++
+		for _, c := range tx.Payload {
+			payload[c]++
+		}
+		m.PayloadBytes += len(tx.Payload)
+	}
+	m.ContentEntropy = normalized(shannon(contents, len(txs)), len(txs))
+	m.SenderEntropy = normalized(shannon(senders, len(txs)), len(txs))
+	m.SenderDiversity = 1
+	if len(txs) > 0 {
+		m.SenderDiversity = float64(len(senders)) / float64(len(txs))
+	}
+	measures := []float64{m.ContentEntropy, m.SenderEntropy}
+	if m.PayloadBytes > 0 {
+		m.PayloadEntropy = shannon(payload, m.PayloadBytes)
+		measures = append(measures, m.PayloadEntropy/8)
+	}
+	for _, v := range measures {
+		m.Score += v
+	}
+	m.Score /= float64(len(measures))
+	return m
+}
+
+// Check reports the first bound m violates, or nil.
+func (bounds EntropyBounds) Check(m EntropyMetrics) error {
+	if m.Txs < bounds.MinTxs {
+		return nil
+	}
+	switch {
+	case m.Score < bounds.MinScore:
+		return fmt.Errorf("%w: score %.3f < %.3f", ErrLowEntropy, m.Score, bounds.MinScore)
+	case m.ContentEntropy < bounds.MinContentEntropy:
+		return fmt.Errorf("%w: content entropy %.3f < %.3f", ErrLowEntropy, m.ContentEntropy, bounds.MinContentEntropy)
+	case m.SenderEntropy < bounds.MinSenderEntropy:
+		return fmt.Errorf("%w: sender entropy %.3f < %.3f", ErrLowEntropy, m.SenderEntropy, bounds.MinSenderEntropy)
+	case m.SenderDiversity < bounds.MinSenderDiversity:
+		return fmt.Errorf("%w: sender diversity %.3f < %.3f", ErrLowEntropy, m.SenderDiversity, bounds.MinSenderDiversity)
+	}
+	return nil
+}
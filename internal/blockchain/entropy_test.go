@@ -0,0 +1,83 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// entropyBlock returns the encoded transactions of n transfers, the i-th sent
+// by sender(i) with content content(i).
+func entropyBlock(n int, sender, content func(i int) int) []string {
+	txs := make([]string, n)
+	for i := range txs {
+		tx := NewTransaction(fmt.Sprintf("%040x", sender(i)), fmt.Sprintf("%040x", 1000+content(i)), uint64(content(i)), 1, uint64(i), nil)
+		txs[i] = tx.Encode()
+	}
+	return txs
+}
+
+func TestEntropyBounds(t *testing.T) {
+	same := func(int) int { return 0 }
+	each := func(i int) int { return i }
+	bounds := DefaultEntropyBounds
+	for _, c := range []struct {
+		name    string
+		txs     []string
+		flagged string // Part of the reason the block is flagged, or "" if it passes
+	}{
+		{"diverse", entropyBlock(16, each, each), ""},
+		{"copies", entropyBlock(16, each, same), "content entropy"},
+		{"one sender", entropyBlock(16, same, each), "sender entropy"},
+		{"two senders", entropyBlock(64, func(i int) int { return i % 2 }, each), "sender diversity"},
+		{"too small to judge", entropyBlock(4, same, same), ""},
+	} {
+		m := ComputeEntropy(c.txs)
+		err := bounds.Check(m)
+		if c.flagged == "" {
+			if err != nil {
+				t.Errorf("%s: %v (%+v)", c.name, err, m)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrLowEntropy) || !strings.Contains(err.Error(), c.flagged) {
+			t.Errorf("%s: %v, want low %s (%+v)", c.name, err, c.flagged, m)
+		}
+
+		// The block is flagged by default and rejected when bounds say so
+		b := NewBlock(1, "", c.txs)
+		b.Seal()
+		res, err := b.ValidateBlock(bounds)
+		if err != nil || res.Flagged == nil {
+			t.Errorf("%s: flagged %v, error %v", c.name, res.Flagged, err)
+		}
+		reject := bounds
+		reject.Reject = true
+		if _, err := b.ValidateBlock(reject); !errors.Is(err, ErrLowEntropy) {
+			t.Errorf("%s: rejecting bounds gave %v", c.name, err)
+		}
+	}
+	if m := ComputeEntropy(entropyBlock(16, same, each)); m.SenderEntropy != 0 || m.SenderDiversity != 1.0/16 {
+		t.Fatalf("one sender: sender entropy %v, diversity %v", m.SenderEntropy, m.SenderDiversity)
+	}
+}
+
+func TestGenesisRangeChecksEntropyBounds(t *testing.T) {
+	for name, set := range map[string]func(c *ConsensusParams){
+		"content entropy":  func(c *ConsensusParams) { c.MinContentEntropy = 1.5 },
+		"sender entropy":   func(c *ConsensusParams) { c.MinSenderEntropy = -0.1 },
+		"sender diversity": func(c *ConsensusParams) { c.MinSenderDiversity = 2 },
+		"score":            func(c *ConsensusParams) { c.MinEntropy = -1 },
+		"min txs":          func(c *ConsensusParams) { c.EntropyMinTxs = -1 },
+	} {
+		g := DefaultGenesis()
+		set(&g.Consensus)
+		if err := g.Validate(); !errors.Is(err, ErrBadGenesis) {
+			t.Errorf("%s out of range: %v", name, err)
+		}
+	}
+	if err := DefaultGenesis().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,321 @@
+package blockchain
+
+// genesis.go: Genesis specification and the deterministic first block
+// A genesis spec fixes the chain ID, initial balances, validator set and the
+// parameters every node of a chain must share. The first block commits to the
+// allocated state through its state root and to the whole spec through its
+// config hash, so two specs that differ in anything yield different chains.
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+)
+
+var (
+	// ErrBadGenesis is returned for a genesis spec that is malformed or inconsistent.
+	ErrBadGenesis = errors.New("invalid genesis spec")
+	// ErrGenesisMismatch is returned when a block or node belongs to a chain with another genesis.
+	ErrGenesisMismatch = errors.New("genesis mismatch")
+)
+
+// Consistency levels of a ConsistencyPolicy.
+const (
+	ConsistencyStrong   = "strong"
+	ConsistencyEventual = "eventual"
+)
+
+// GenesisValidator is a member of the initial validator set.
+type GenesisValidator struct {
+	Address   string `json:"address"`   // Derived from the public key if empty
+	PublicKey string `json:"publicKey"` // Hex-encoded ed25519 public key
+}
+
+// ConsensusParams are the thresholds validators apply. A block is finalized
+// when more than QuorumNumerator/QuorumDenominator of the validators vote for it.
+type ConsensusParams struct {
+	QuorumNumerator    int     `json:"quorumNumerator"`
+	QuorumDenominator  int     `json:"quorumDenominator"`
+	EntropyMinTxs      int     `json:"entropyMinTxs"`
+	MinEntropy         float64 `json:"minEntropy"`
+	MinContentEntropy  float64 `json:"minContentEntropy"`
+	MinSenderEntropy   float64 `json:"minSenderEntropy"`
+	MinSenderDiversity float64 `json:"minSenderDiversity"`
+	RejectLowEntropy   bool    `json:"rejectLowEntropy"`
+	MedianTimeBlocks   int     `json:"medianTimeBlocks"` // Blocks the median-time-past is taken over
+	MaxClockDriftMs    int64   `json:"maxClockDriftMs"`  // How far ahead of a validator's clock a timestamp may be
+}
+
+// EntropyBounds returns the entropy validation bounds of the parameters.
+func (p ConsensusParams) EntropyBounds() EntropyBounds {
+	return EntropyBounds{
+		MinTxs:             p.EntropyMinTxs,
+		MinScore:           p.MinEntropy,
+		MinContentEntropy:  p.MinContentEntropy,
+		MinSenderEntropy:   p.MinSenderEntropy,
+		MinSenderDiversity: p.MinSenderDiversity,
+		Reject:             p.RejectLowEntropy,
+	}
+}
+
+// TimestampRules returns the block timestamp rules of the parameters.
+func (p ConsensusParams) TimestampRules() TimestampRules {
+	return TimestampRules{MedianBlocks: p.MedianTimeBlocks, MaxDrift: time.Duration(p.MaxClockDriftMs) * time.Millisecond}
+}
+
+// RebalanceParams are the shard rebalancing thresholds of the state forest.
+type RebalanceParams struct {
+	SplitThreshold int `json:"splitThreshold"`
+	MergeThreshold int `json:"mergeThreshold"`
+}
+
+// Config returns the parameters as an amf.RebalanceConfig.
+func (p RebalanceParams) Config() amf.RebalanceConfig {
+	return amf.RebalanceConfig{SplitThreshold: p.SplitThreshold, MergeThreshold: p.MergeThreshold}
+}
+
+// ConsistencyPolicy is the initial CAP consistency setting and its retry policy.
+type ConsistencyPolicy struct {
+	Level     string `json:"level"` // ConsistencyStrong or ConsistencyEventual
+	TimeoutMs int    `json:"timeoutMs"`
+	Retries   int    `json:"retries"`
+	BackoffMs int    `json:"backoffMs"`
+}
+
+// Genesis is the specification of a chain's first block and initial state.
+type Genesis struct {
+	ChainID      uint64             `json:"chainId"`
+	HashFunction string             `json:"hashFunction"` // Chain hash function: crypto.HashSHA256 or crypto.HashKeccak256
+	Timestamp    int64              `json:"timestamp"`    // Unix seconds of the first block
+	Alloc        map[string]uint64  `json:"alloc"`        // Initial balance of each address
+	Validators   []GenesisValidator `json:"validators"`
+	Consensus    ConsensusParams    `json:"consensus"`
+	Rebalance    RebalanceParams    `json:"rebalance"`
+	Consistency  ConsistencyPolicy  `json:"consistency"`
+}
+
+// DefaultGenesis returns the spec of a local development chain with no
+// allocations or validators.
+func DefaultGenesis() *Genesis {
+	return &Genesis{
+		ChainID:      1337,
+		HashFunction: crypto.HashSHA256,
+		Alloc:        map[string]uint64{},
+		Consensus: ConsensusParams{
+			QuorumNumerator:    1,
+			QuorumDenominator:  2,
+			EntropyMinTxs:      DefaultEntropyBounds.MinTxs,
+			MinEntropy:         DefaultEntropyBounds.MinScore,
+			MinContentEntropy:  DefaultEntropyBounds.MinContentEntropy,
+			MinSenderEntropy:   DefaultEntropyBounds.MinSenderEntropy,
+			MinSenderDiversity: DefaultEntropyBounds.MinSenderDiversity,
+			MedianTimeBlocks:   DefaultTimestampRules.MedianBlocks,
+			MaxClockDriftMs:    DefaultTimestampRules.MaxDrift.Milliseconds(),
+		},
+		Rebalance: RebalanceParams{
+			SplitThreshold: amf.DefaultRebalanceConfig.SplitThreshold,
+			MergeThreshold: amf.DefaultRebalanceConfig.MergeThreshold,
+		},
+		Consistency: ConsistencyPolicy{Level: ConsistencyEventual, TimeoutMs: 2000, Retries: 3, BackoffMs: 1000},
+	}
+}
+
+// LoadGenesis reads and validates a JSON genesis spec.
+func LoadGenesis(path string) (*Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	g := new(Genesis)
+	if err := dec.Decode(g); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadGenesis, err)
+	}
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Validate checks the spec and normalizes it: addresses are lowercased,
+// missing validator addresses derived from their public keys and a missing
+// hash function set to SHA-256.
+func (g *Genesis) Validate() error {
+	if g.ChainID == 0 {
+		return fmt.Errorf("%w: chain ID must be set", ErrBadGenesis)
+	}
+	if g.HashFunction == "" {
+		g.HashFunction = crypto.HashSHA256
+	}
+	if _, err := crypto.HashByName(g.HashFunction); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadGenesis, err)
+	}
+	alloc := make(map[string]uint64, len(g.Alloc))
+	for addr, balance := range g.Alloc {
+		addr = strings.ToLower(addr)
+		if !crypto.IsHexAddress(addr) {
+			return fmt.Errorf("%w: allocation to invalid address %q", ErrBadGenesis, addr)
+		}
+		if _, dup := alloc[addr]; dup {
+			return fmt.Errorf("%w: duplicate allocation to %s", ErrBadGenesis, addr)
+		}
+		alloc[addr] = balance
+	}
+	g.Alloc = alloc
+	seen := make(map[string]bool, len(g.Validators))
+	for i := range g.Validators {
+		v := &g.Validators[i]
+		v.PublicKey = strings.ToLower(v.PublicKey)
+		pub, err := hex.DecodeString(v.PublicKey)
+		if err != nil || len(pub) != 32 {
+			return fmt.Errorf("%w: validator %d: public key is not 32 hex bytes", ErrBadGenesis, i)
+		}
+		derived := crypto.PubkeyToAddress(pub)
+		if v.Address == "" {
+			v.Address = derived
+		}
+		if v.Address = strings.ToLower(v.Address); v.Address != derived {
+			return fmt.Errorf("%w: validator %d: address %s does not match public key", ErrBadGenesis, i, v.Address)
+		}
+		if seen[v.Address] {
+			return fmt.Errorf("%w: duplicate validator %s", ErrBadGenesis, v.Address)
+		}
+		seen[v.Address] = true
+	}
+	c := g.Consensus
+	if c.QuorumDenominator <= 0 || c.QuorumNumerator < 0 || c.QuorumNumerator >= c.QuorumDenominator {
+		return fmt.Errorf("%w: quorum %d/%d is not a fraction below 1", ErrBadGenesis, c.QuorumNumerator, c.QuorumDenominator)
+	}
+	if c.EntropyMinTxs < 0 {
+		return fmt.Errorf("%w: entropy minimum of %d transactions", ErrBadGenesis, c.EntropyMinTxs)
+	}
+	for _, bound := range []struct {
+		name  string
+		value float64
+	}{
+		{"minEntropy", c.MinEntropy},
+		{"minContentEntropy", c.MinContentEntropy},
+		{"minSenderEntropy", c.MinSenderEntropy},
+		{"minSenderDiversity", c.MinSenderDiversity},
+	} {
+		if !(bound.value >= 0 && bound.value <= 1) {
+			return fmt.Errorf("%w: %s %v is not in [0, 1]", ErrBadGenesis, bound.name, bound.value)
+		}
+	}
+	if c.MedianTimeBlocks < 0 || c.MaxClockDriftMs <= 0 {
+		return fmt.Errorf("%w: timestamp rules over %d blocks with %dms drift", ErrBadGenesis, c.MedianTimeBlocks, c.MaxClockDriftMs)
+	}
+	if r := g.Rebalance; r.SplitThreshold <= 0 || r.MergeThreshold < 0 || r.MergeThreshold >= r.SplitThreshold {
+		return fmt.Errorf("%w: rebalance thresholds split %d, merge %d", ErrBadGenesis, r.SplitThreshold, r.MergeThreshold)
+	}
+	p := g.Consistency
+	if p.Level != ConsistencyStrong && p.Level != ConsistencyEventual {
+		return fmt.Errorf("%w: consistency level %q", ErrBadGenesis, p.Level)
+	}
+	if p.TimeoutMs <= 0 || p.Retries < 0 || p.BackoffMs < 0 {
+		return fmt.Errorf("%w: consistency timeout and retry policy out of range", ErrBadGenesis)
+	}
+	return nil
+}
+
+// UseHashFunction makes the spec's hash function the chain hash function. It
+// must be called before the first block, state or Merkle root is computed.
+func (g *Genesis) UseHashFunction() error {
+	h, err := crypto.HashByName(g.HashFunction)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadGenesis, err)
+	}
+	return SetHashFunc(h)
+}
+
+// ConfigHash returns the hash of the canonical JSON encoding of the spec,
+// which the first block commits to. The hash function name is part of the
+// spec, so chains hashing with different functions never share a genesis.
+func (g *Genesis) ConfigHash() ([]byte, error) {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return nil, err
+	}
+	return hash(data), nil
+}
+
+// ValidatorIDs returns the addresses of the validator set, in spec order.
+func (g *Genesis) ValidatorIDs() []string {
+	ids := make([]string, len(g.Validators))
+	for i, v := range g.Validators {
+		ids[i] = v.Address
+	}
+	return ids
+}
+
+// State returns the initial state: the allocated balances in a forest
+// rebalanced with the spec's thresholds.
+func (g *Genesis) State() (*StateDB, error) {
+	state := NewStateDB(amf.NewForest(), g.Rebalance.Config())
+	addrs := make([]string, 0, len(g.Alloc))
+	for addr := range g.Alloc {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		if err := state.SetAccount(addr, Account{Balance: g.Alloc[addr]}); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// Block returns the first block of the chain. It depends only on the spec.
+func (g *Genesis) Block() (*Block, error) {
+	state, err := g.State()
+	if err != nil {
+		return nil, err
+	}
+	configHash, err := g.ConfigHash()
+	if err != nil {
+		return nil, err
+	}
+	b := NewBlock(0, "", nil)
+	b.Timestamp = time.Unix(g.Timestamp, 0)
+	b.StateRoot = state.Root()
+	b.ConfigHash = configHash
+	b.Seal()
+	return b, nil
+}
+
+// Handshake returns what a node of the chain announces to its peers.
+func (g *Genesis) Handshake() (Handshake, error) {
+	b, err := g.Block()
+	if err != nil {
+		return Handshake{}, err
+	}
+	return Handshake{ChainID: g.ChainID, Genesis: b.Hash}, nil
+}
+
+// Handshake identifies the chain a node follows. Nodes only connect to peers
+// whose handshake matches their own.
+type Handshake struct {
+	ChainID uint64
+	Genesis string // Hash of the first block
+}
+
+// Check returns ErrGenesisMismatch unless remote is on the same chain as h.
+func (h Handshake) Check(remote Handshake) error {
+	if remote.ChainID != h.ChainID {
+		return fmt.Errorf("%w: chain ID %d, want %d", ErrGenesisMismatch, remote.ChainID, h.ChainID)
+	}
+	if remote.Genesis != h.Genesis {
+		return fmt.Errorf("%w: genesis %s, want %s", ErrGenesisMismatch, remote.Genesis, h.Genesis)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+)
+
+func TestGenesisHashFunction(t *testing.T) {
+	sha := DefaultGenesis()
+	keccak := DefaultGenesis()
+	keccak.HashFunction = crypto.HashKeccak256
+	if err := keccak.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	shaHash, err := sha.ConfigHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keccakHash, err := keccak.ConfigHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(shaHash, keccakHash) {
+		t.Fatal("config hash does not commit to the hash function")
+	}
+
+	unset := DefaultGenesis()
+	unset.HashFunction = ""
+	if err := unset.Validate(); err != nil || unset.HashFunction != crypto.HashSHA256 {
+		t.Fatalf("missing hash function validated to %q, %v", unset.HashFunction, err)
+	}
+	unknown := DefaultGenesis()
+	unknown.HashFunction = "md5"
+	if err := unknown.Validate(); !errors.Is(err, ErrBadGenesis) {
+		t.Fatalf("unknown hash function: %v", err)
+	}
+
+	// The process has hashed with SHA-256 above, so it can no longer switch
+	if err := sha.UseHashFunction(); err != nil {
+		t.Fatalf("selecting the function in use: %v", err)
+	}
+	if err := keccak.UseHashFunction(); !errors.Is(err, amf.ErrHashFuncInUse) {
+		t.Fatalf("switching the hash function after use: %v", err)
+	}
+	if got := hash([]byte("abc")); !bytes.Equal(got, crypto.SHA256([]byte("abc"))) {
+		t.Fatal("rejected switch changed the hash function")
+	}
+}
+
+// devGenesis returns the development spec with one allocation and validator.
+func devGenesis(t *testing.T) *Genesis {
+	t.Helper()
+	g := DefaultGenesis()
+	g.Alloc["1111111111111111111111111111111111111111"] = 1000
+	g.Validators = []GenesisValidator{{PublicKey: "3b0a23f8675b0f2e8c94bc279b9cacea7befa331b15799bf4cb50a564d90dd4f"}}
+	if err := g.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestGenesisIsDeterministic(t *testing.T) {
+	a, b := devGenesis(t), devGenesis(t)
+	blockA, err := a.Block()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockB, err := b.Block()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockA.Hash != blockB.Hash || !bytes.Equal(blockA.StateRoot, blockB.StateRoot) {
+		t.Fatalf("one spec gave blocks %s and %s", blockA.Hash, blockB.Hash)
+	}
+	if ids := a.ValidatorIDs(); len(ids) != 1 || ids[0] != "ba39a5fc7626cef784ea121e57197f80aac2927e" {
+		t.Fatalf("validator set %v", ids)
+	}
+
+	ours, err := a.Handshake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, change := range map[string]func(g *Genesis){
+		"alloc":      func(g *Genesis) { g.Alloc["1111111111111111111111111111111111111111"]++ },
+		"validators": func(g *Genesis) { g.Validators = nil },
+		"quorum":     func(g *Genesis) { g.Consensus.QuorumNumerator = 2; g.Consensus.QuorumDenominator = 3 },
+		"chain ID":   func(g *Genesis) { g.ChainID++ },
+	} {
+		other := devGenesis(t)
+		change(other)
+		theirs, err := other.Handshake()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ours.Check(theirs); !errors.Is(err, ErrGenesisMismatch) {
+			t.Errorf("%s changed: handshake %v", name, err)
+		}
+	}
+	if err := ours.Check(ours); err != nil {
+		t.Fatalf("own handshake: %v", err)
+	}
+}
+
+func TestShippedGenesisHasValidators(t *testing.T) {
+	g, err := LoadGenesis("../../genesis.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.ValidatorIDs()) == 0 {
+		t.Fatal("genesis.json has no validators; certificates could not be checked")
+	}
+}
@@ -0,0 +1,21 @@
+package blockchain
+
+// hash.go: Chain hash function selection
+
+import (
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+)
+
+// SetHashFunc selects the hash used for block, transaction and certificate
+// hashes and, through amf.SetHashFunc, for the Merkle roots they commit to.
+// Every node of a chain must use the function its genesis spec names, set
+// before any block is built; changing it afterwards returns amf.ErrHashFuncInUse.
+func SetHashFunc(h crypto.HashFunc) error {
+	return amf.SetHashFunc(h)
+}
+
+// hash hashes data with the configured chain hash function.
+func hash(data []byte) []byte {
+	return amf.Hash(data)
+}
@@ -0,0 +1,114 @@
+package blockchain
+
+// header.go: Canonical block header and its deterministic binary encoding
+// The block hash is computed over the encoded header, so every field below is
+// committed to by the hash.
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+// headerVersion is written first in every encoded header.
+const headerVersion = 4
+
+// BlockHeader holds the fields of a block covered by its hash.
+type BlockHeader struct {
+	Index        int
+	Timestamp    time.Time
+	PrevHash     string
+	Accumulator  []byte // Compact state representation of the transactions
+	TxRoot       []byte // Merkle root over the transactions
+	StateRoot    []byte // State root after executing the transactions
+	ReceiptsRoot []byte // Merkle root over the execution receipts
+	GasUsed      uint64 // Total gas consumed by the transactions
+	Proposer     string // ID of the validator that proposed the block
+	Round        uint64 // Consensus round the block was proposed in
+	CertHash     []byte // Hash of the certificate that finalized the parent block
+	MMRRoot      []byte // Merkle Mountain Range root over the hashes of every earlier block
+	LogsBloom    []byte // Bloom filter over the addresses and topics of the receipt logs, nil if there are none
+	ConfigHash   []byte // Hash of the genesis spec, set only in the first block
+}
+
+// Encode returns the canonical binary encoding of the header. Integers are
+// fixed-width big-endian, the timestamp is in Unix nanoseconds and byte
+// strings are length-prefixed.
+func (h *BlockHeader) Encode() []byte {
+	var e encoder
+	e.uint64(headerVersion)
+	e.uint64(uint64(h.Index))
+	e.uint64(uint64(h.Timestamp.UnixNano()))
+	e.string(h.PrevHash)
+	e.bytes(h.Accumulator)
+	e.bytes(h.TxRoot)
+	e.bytes(h.StateRoot)
+	e.bytes(h.ReceiptsRoot)
+	e.uint64(h.GasUsed)
+	e.string(h.Proposer)
+	e.uint64(h.Round)
+	e.bytes(h.CertHash)
+	e.bytes(h.MMRRoot)
+	e.bytes(h.LogsBloom)
+	e.bytes(h.ConfigHash)
+	return e.buf
+}
+
+// DecodeBlockHeader parses a header produced by Encode.
+func DecodeBlockHeader(data []byte) (*BlockHeader, error) {
+	d := decoder{buf: data}
+	if v := d.uint64(); d.err == nil && v != headerVersion {
+		return nil, errUnknownVersion
+	}
+	h := &BlockHeader{
+		Index:        int(d.uint64()),
+		Timestamp:    time.Unix(0, int64(d.uint64())),
+		PrevHash:     d.string(),
+		Accumulator:  d.bytes(),
+		TxRoot:       d.bytes(),
+		StateRoot:    d.bytes(),
+		ReceiptsRoot: d.bytes(),
+		GasUsed:      d.uint64(),
+		Proposer:     d.string(),
+		Round:        d.uint64(),
+		CertHash:     d.bytes(),
+		MMRRoot:      d.bytes(),
+		LogsBloom:    d.bytes(),
+		ConfigHash:   d.bytes(),
+	}
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// HeaderHash returns the digest of the encoded header under the chain hash function.
+func (h *BlockHeader) HeaderHash() []byte {
+	return hash(h.Encode())
+}
+
+// Hash returns the hex-encoded header hash, which is the block hash.
+func (h *BlockHeader) Hash() string {
+	return hex.EncodeToString(h.HeaderHash())
+}
+
+// TxRoot returns the Merkle root over encoded transactions; an empty list has
+// the hash of no data as its root.
+func TxRoot(txs []string) []byte {
+	leaves := make([][]byte, len(txs))
+	for i, tx := range txs {
+		leaves[i] = []byte(tx)
+	}
+	return merkleRoot(leaves)
+}
+
+// merkleRoot returns the root of amf.NewMerkleTree over leaves, or the hash of
+// no data if there are none.
+func merkleRoot(leaves [][]byte) []byte {
+	tree, err := amf.NewMerkleTree(leaves)
+	if err != nil {
+		return hash(nil)
+	}
+	return tree.Root.Hash
+}
@@ -0,0 +1,330 @@
+package blockchain
+
+// parallel.go: Optimistic parallel transaction execution (Block-STM style)
+// Transactions run concurrently against a multi-version memory holding every
+// transaction's writes, each reading the latest write of an earlier transaction
+// or the base state. The values a transaction read are then validated against
+// the memory; the transactions that read a value an earlier one has since
+// changed are re-executed, and only those. Re-execution follows the accounts
+// the transactions share, so a chain of dependent transfers runs in order
+// instead of conflicting again. Once every read is valid, the writes are
+// stored in transaction order, so the state, the journal and the receipts are
+// exactly those of serial execution.
+
+import (
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// mvStripes is the number of independently locked parts of a multi-version memory.
+const mvStripes = 64
+
+// ParallelExecutor applies transactions on a pool of workers with the outcome
+// of applying them one after another.
+type ParallelExecutor struct {
+	Workers int // Number of workers; 0 uses one per CPU
+}
+
+// ExecStats describes a parallel execution.
+type ExecStats struct {
+	Rounds     int // Execution rounds until every read was valid
+	Executions int // Transaction executions, at least one per transaction
+}
+
+// Reexecutions returns how many executions were repeated because of conflicts.
+func (st ExecStats) Reexecutions(txs int) int {
+	return st.Executions - txs
+}
+
+// workers returns the size of the worker pool.
+func (e *ParallelExecutor) workers() int {
+	if e.Workers > 0 {
+		return e.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// Execute applies txs to s. It stores the same accounts in the same order and
+// returns the same receipts, or the same error for the first invalid
+// transaction after storing the ones before it, as ApplyTransaction applied to
+// each transaction in turn.
+func (e *ParallelExecutor) Execute(s *StateDB, txs []*Transaction) ([]*Receipt, ExecStats, error) {
+	var stats ExecStats
+	mv := newMVMemory()
+	runs := make([]txRun, len(txs))
+	pending := make([]int, len(txs))
+	for i := range pending {
+		pending[i] = i
+	}
+	for len(pending) > 0 {
+		stats.Rounds++
+		var executions atomic.Int64
+		run := func(i int) {
+			runs[i].execute(i, txs[i], s, mv)
+			executions.Add(1)
+		}
+		if stats.Rounds == 1 {
+			e.each(pending, run)
+		} else {
+			e.reexecute(pending, txs, func(i int, invalid bool) {
+				if invalid || !runs[i].valid(i, s, mv) {
+					run(i)
+				}
+			})
+		}
+		stats.Executions += int(executions.Load())
+		// Transactions before the lowest re-executed one read nothing that
+		// changed this round
+		check := make([]int, 0, len(txs)-pending[0])
+		for i := pending[0]; i < len(txs); i++ {
+			check = append(check, i)
+		}
+		invalid := make([]bool, len(txs))
+		e.each(check, func(i int) {
+			invalid[i] = !runs[i].valid(i, s, mv)
+		})
+		pending = pending[:0]
+		for _, i := range check {
+			if invalid[i] {
+				pending = append(pending, i)
+			}
+		}
+	}
+	receipts := make([]*Receipt, 0, len(txs))
+	for i, tx := range txs {
+		if err := runs[i].err; err != nil {
+			return nil, stats, txError(i, tx, err)
+		}
+		if err := s.storeWrites(runs[i].writes); err != nil {
+			return nil, stats, txError(i, tx, err)
+		}
+		receipts = append(receipts, runs[i].receipt)
+	}
+	return receipts, stats, nil
+}
+
+// reexecute calls fn on the worker pool for the invalid transactions and the
+// later ones that share an account with any of them, directly or through
+// another, and waits for all calls. Such transactions are expected to conflict
+// again, so each is only started once the earlier ones touching its sender or
+// recipient are done; fn then revalidates the valid ones before deciding to
+// run them again.
+func (e *ParallelExecutor) reexecute(invalid []int, txs []*Transaction, fn func(i int, invalid bool)) {
+	isInvalid := make(map[int]bool, len(invalid))
+	for _, i := range invalid {
+		isInvalid[i] = true
+	}
+	var tasks []int
+	waiting := make(map[int]int)
+	dependents := make(map[int][]int)
+	last := make(map[string]int)
+	for j := invalid[0]; j < len(txs); j++ {
+		accounts := []string{txs[j].From, txs[j].To}
+		_, fromTouched := last[accounts[0]]
+		_, toTouched := last[accounts[1]]
+		if !isInvalid[j] && !fromTouched && !toTouched {
+			continue
+		}
+		tasks = append(tasks, j)
+		for _, address := range accounts {
+			if i, ok := last[address]; ok && i != j {
+				dependents[i] = append(dependents[i], j)
+				waiting[j]++
+			}
+			last[address] = j
+		}
+	}
+	ready := make(chan int, len(tasks))
+	for _, j := range tasks {
+		if waiting[j] == 0 {
+			ready <- j
+		}
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(tasks))
+	for w := min(e.workers(), len(tasks)); w > 0; w-- {
+		go func() {
+			for i := range ready {
+				fn(i, isInvalid[i])
+				mu.Lock()
+				for _, j := range dependents[i] {
+					if waiting[j]--; waiting[j] == 0 {
+						ready <- j
+					}
+				}
+				mu.Unlock()
+				wg.Done()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ready)
+}
+
+// each calls fn for every index on the worker pool and waits for all calls.
+func (e *ParallelExecutor) each(indices []int, fn func(i int)) {
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := min(e.workers(), len(indices)); w > 0; w-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				k := int(next.Add(1)) - 1
+				if k >= len(indices) {
+					return
+				}
+				fn(indices[k])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// txRun is the latest execution of one transaction.
+type txRun struct {
+	checked bool
+	sigErr  error              // Signature check, done on the first execution only
+	reads   map[string]Account // Read set: the value seen at each address
+	writes  []accountWrite     // Write set, in the order the accounts are stored
+	receipt *Receipt
+	err     error
+}
+
+// execute runs transaction i against the writes of the transactions before it
+// in mv and the base state s, and replaces its writes in mv with the new ones.
+func (r *txRun) execute(i int, tx *Transaction, s *StateDB, mv *mvMemory) {
+	if !r.checked {
+		r.sigErr, r.checked = tx.VerifySignature(), true
+	}
+	old := r.writes
+	r.reads = make(map[string]Account, 2)
+	r.writes, r.receipt, r.err = nil, nil, r.sigErr
+	if r.err == nil {
+		r.writes, r.receipt, r.err = transition(tx, func(address string) Account {
+			acct := mv.read(address, i, s)
+			r.reads[address] = acct
+			return acct
+		})
+	}
+	mv.record(i, old, r.writes)
+}
+
+// valid reports whether every value transaction i read is still the one it
+// would read now. Transitions are deterministic, so equal values mean an
+// equal outcome even if the write that produced them was re-executed.
+func (r *txRun) valid(i int, s *StateDB, mv *mvMemory) bool {
+	for address, seen := range r.reads {
+		if mv.read(address, i, s) != seen {
+			return false
+		}
+	}
+	return true
+}
+
+// txError wraps the error of transaction i as block execution reports it.
+func txError(i int, tx *Transaction, err error) error {
+	return fmt.Errorf("transaction %d (%s): %w", i, tx.Hash(), err)
+}
+
+// mvWrite is the value transaction tx wrote to an address.
+type mvWrite struct {
+	tx      int
+	account Account
+}
+
+// mvStripe is a locked part of a multi-version memory.
+type mvStripe struct {
+	mu     sync.RWMutex
+	writes map[string][]mvWrite // By address, ordered by transaction index
+}
+
+// mvMemory holds the accounts written by the transactions of a block, every
+// version by the index of the transaction that wrote it. Addresses are spread
+// over stripes by hash so that workers touching different accounts rarely
+// wait for each other, as they rarely share a shard of the forest.
+type mvMemory struct {
+	stripes [mvStripes]mvStripe
+}
+
+// newMVMemory returns an empty multi-version memory.
+func newMVMemory() *mvMemory {
+	mv := new(mvMemory)
+	for i := range mv.stripes {
+		mv.stripes[i].writes = make(map[string][]mvWrite)
+	}
+	return mv
+}
+
+// stripe returns the stripe holding address.
+func (mv *mvMemory) stripe(address string) *mvStripe {
+	h := fnv.New32a()
+	h.Write([]byte(address))
+	return &mv.stripes[h.Sum32()%mvStripes]
+}
+
+// read returns the account at address as transaction tx sees it: the latest
+// write of an earlier transaction, or the account in the base state s.
+func (mv *mvMemory) read(address string, tx int, s *StateDB) Account {
+	st := mv.stripe(address)
+	st.mu.RLock()
+	writes := st.writes[address]
+	k := sort.Search(len(writes), func(k int) bool { return writes[k].tx >= tx })
+	if k > 0 {
+		acct := writes[k-1].account
+		st.mu.RUnlock()
+		return acct
+	}
+	st.mu.RUnlock()
+	return s.GetAccount(address)
+}
+
+// record replaces the writes of transaction tx, old, with its new ones.
+func (mv *mvMemory) record(tx int, old, writes []accountWrite) {
+	final := make(map[string]Account, len(writes))
+	for _, w := range writes {
+		final[w.address] = w.account
+	}
+	for _, w := range old {
+		if _, ok := final[w.address]; !ok {
+			mv.remove(w.address, tx)
+		}
+	}
+	for address, acct := range final {
+		mv.put(address, tx, acct)
+	}
+}
+
+// put sets the value transaction tx wrote to address.
+func (mv *mvMemory) put(address string, tx int, acct Account) {
+	st := mv.stripe(address)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	writes := st.writes[address]
+	k := sort.Search(len(writes), func(k int) bool { return writes[k].tx >= tx })
+	if k < len(writes) && writes[k].tx == tx {
+		writes[k].account = acct
+		return
+	}
+	writes = append(writes, mvWrite{})
+	copy(writes[k+1:], writes[k:])
+	writes[k] = mvWrite{tx: tx, account: acct}
+	st.writes[address] = writes
+}
+
+// remove drops the value transaction tx wrote to address.
+func (mv *mvMemory) remove(address string, tx int) {
+	st := mv.stripe(address)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	writes := st.writes[address]
+	k := sort.Search(len(writes), func(k int) bool { return writes[k].tx >= tx })
+	if k < len(writes) && writes[k].tx == tx {
+		st.writes[address] = append(writes[:k], writes[k+1:]...)
+	}
+}
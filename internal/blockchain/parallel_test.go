@@ -0,0 +1,180 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+// execWorkload is a funded state and a block's worth of transfers against it.
+type execWorkload struct {
+	state  *StateDB
+	keys   testKeys
+	from   []string
+	nonces map[string]uint64 // Next nonce of each sender
+	txs    []*Transaction
+}
+
+// newExecWorkload generates n transfers from the given number of funded
+// senders, round robin. With hot set every transfer pays the same account, so
+// each one conflicts with the one before; otherwise each sender pays an
+// account of its own and no two transfers touch the same account.
+func newExecWorkload(t testing.TB, n, senders int, hot bool) *execWorkload {
+	t.Helper()
+	keys, from := newTestKeys(t, senders)
+	w := &execWorkload{
+		state:  NewStateDB(amf.NewForest(), amf.DefaultRebalanceConfig),
+		keys:   keys,
+		from:   from,
+		nonces: make(map[string]uint64),
+	}
+	for _, addr := range from {
+		if err := w.state.SetAccount(addr, Account{Balance: 1 << 40}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		to := fmt.Sprintf("%040x", i%senders+1)
+		if hot {
+			to = fmt.Sprintf("%040x", 0)
+		}
+		w.add(t, from[i%senders], to, 10)
+	}
+	return w
+}
+
+// add appends a signed transfer with the sender's next nonce.
+func (w *execWorkload) add(t testing.TB, from, to string, amount uint64) {
+	t.Helper()
+	tx := NewTransaction(from, to, amount, 1, w.nonces[from], nil)
+	w.nonces[from]++
+	if err := tx.Sign(w.keys); err != nil {
+		t.Fatal(err)
+	}
+	w.txs = append(w.txs, tx)
+}
+
+// serial applies the workload's transactions to a copy of its state one by one.
+func (w *execWorkload) serial() (*StateDB, []*Receipt, error) {
+	state := w.state.Copy()
+	receipts, err := state.applyTransactions(w.txs)
+	return state, receipts, err
+}
+
+// parallel applies the workload's transactions to a copy of its state on e.
+func (w *execWorkload) parallel(e *ParallelExecutor) (*StateDB, []*Receipt, ExecStats, error) {
+	state := w.state.Copy()
+	receipts, stats, err := e.Execute(state, w.txs)
+	return state, receipts, stats, err
+}
+
+// checkSameExecution fails unless parallel execution of w on workers gives the
+// state, receipts and error of serial execution.
+func checkSameExecution(t *testing.T, w *execWorkload, workers int) {
+	t.Helper()
+	serialState, serialReceipts, serialErr := w.serial()
+	parallelState, parallelReceipts, _, parallelErr := w.parallel(&ParallelExecutor{Workers: workers})
+	if fmt.Sprint(serialErr) != fmt.Sprint(parallelErr) {
+		t.Fatalf("%d workers: error %v, serial execution gave %v", workers, parallelErr, serialErr)
+	}
+	if !bytes.Equal(serialState.Root(), parallelState.Root()) {
+		t.Fatalf("%d workers: state root %x, serial execution gave %x", workers, parallelState.Root(), serialState.Root())
+	}
+	if !bytes.Equal(ReceiptsRoot(serialReceipts), ReceiptsRoot(parallelReceipts)) {
+		t.Fatalf("%d workers: receipts root differs from serial execution", workers)
+	}
+	if len(serialReceipts) != len(parallelReceipts) {
+		t.Fatalf("%d workers: %d receipts, serial execution gave %d", workers, len(parallelReceipts), len(serialReceipts))
+	}
+}
+
+func TestParallelMatchesSerial(t *testing.T) {
+	workloads := map[string]*execWorkload{
+		"low contention":  newExecWorkload(t, 200, 200, false),
+		"high contention": newExecWorkload(t, 200, 16, true),
+	}
+
+	// A transfer beyond the sender's balance in the middle of the block fails
+	// but is included, and the sender's later transfers depend on its nonce
+	failing := newExecWorkload(t, 60, 6, true)
+	failing.add(t, failing.from[2], failing.from[3], 1<<41)
+	for i := 0; i < 60; i++ {
+		failing.add(t, failing.from[i%6], failing.from[(i+1)%6], 5)
+	}
+	failing.add(t, failing.from[1], failing.from[2], 1<<40) // Spends what the others paid in
+	workloads["failed transfer"] = failing
+
+	for name, w := range workloads {
+		t.Run(name, func(t *testing.T) {
+			_, receipts, err := w.serial()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name == "failed transfer" && receipts[60].Status != ReceiptFailed {
+				t.Fatal("overdrawing transfer did not fail")
+			}
+			for _, workers := range []int{1, 2, 8} {
+				checkSameExecution(t, w, workers)
+			}
+		})
+	}
+}
+
+func TestParallelStopsAtInvalidTransaction(t *testing.T) {
+	for name, corrupt := range map[string]func(w *execWorkload){
+		"nonce gap": func(w *execWorkload) {
+			w.txs[30] = w.txs[30+4] // The sender's nonce skips ahead
+		},
+		"bad signature": func(w *execWorkload) {
+			w.txs[30].Signature = append([]byte{}, w.txs[31].Signature...)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := newExecWorkload(t, 80, 4, true)
+			corrupt(w)
+			if _, _, err := w.serial(); err == nil {
+				t.Fatal("serial execution accepted the invalid transaction")
+			}
+			// The transactions before the invalid one are stored by both
+			for _, workers := range []int{1, 2, 8} {
+				checkSameExecution(t, w, workers)
+			}
+		})
+	}
+}
+
+// benchmarkExecute times serial and parallel execution of a workload.
+func benchmarkExecute(b *testing.B, w *execWorkload) {
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := w.serial(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		e := &ParallelExecutor{}
+		var stats ExecStats
+		for i := 0; i < b.N; i++ {
+			var err error
+			if _, _, stats, err = w.parallel(e); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(stats.Rounds), "rounds/op")
+		b.ReportMetric(float64(stats.Reexecutions(len(w.txs))), "reexecutions/op")
+	})
+}
+
+// BenchmarkExecuteLowContention runs independent transfers, one per sender and
+// recipient.
+func BenchmarkExecuteLowContention(b *testing.B) {
+	benchmarkExecute(b, newExecWorkload(b, 500, 500, false))
+}
+
+// BenchmarkExecuteHighContention runs transfers that all pay the same account.
+func BenchmarkExecuteHighContention(b *testing.B) {
+	benchmarkExecute(b, newExecWorkload(b, 500, 16, true))
+}
@@ -0,0 +1,147 @@
+package blockchain
+
+// prune.go: Provable pruning of state history with Merkle checkpoints
+// Pruning drops the journal history of old blocks. Before it is dropped, the
+// history is committed to a checkpoint root, so an archive node that kept the
+// entries can later prove any of them to a node that only kept the checkpoint.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+var (
+	// ErrBadEntryProof is returned when a pruned entry does not verify against its checkpoint.
+	ErrBadEntryProof = errors.New("state entry proof does not match checkpoint")
+	// ErrNothingToPrune is returned when no history lies at or below the pruning height.
+	ErrNothingToPrune = errors.New("no state history to prune")
+)
+
+// StateEntry is one piece of state history: Key held Value (JSON, or nothing
+// if Existed is false) until block Height overwrote it.
+type StateEntry struct {
+	Height  int
+	Key     string
+	Existed bool
+	Value   []byte
+}
+
+// Encode returns the canonical encoding of the entry, its Merkle leaf.
+func (e *StateEntry) Encode() []byte {
+	var enc encoder
+	enc.uint64(uint64(e.Height + 1)) // history before the first block is at -1
+	enc.string(e.Key)
+	existed := uint64(0)
+	if e.Existed {
+		existed = 1
+	}
+	enc.uint64(existed)
+	enc.bytes(e.Value)
+	return enc.buf
+}
+
+// Account decodes the entry's value as an account; a missing key is an empty account.
+func (e *StateEntry) Account() (Account, error) {
+	var acct Account
+	if !e.Existed {
+		return acct, nil
+	}
+	err := json.Unmarshal(e.Value, &acct)
+	return acct, err
+}
+
+// PruneCheckpoint commits to the state history pruned for a range of heights.
+type PruneCheckpoint struct {
+	FromHeight int    // Lowest height whose history was pruned
+	ToHeight   int    // Highest height whose history was pruned
+	Root       []byte // Merkle root over the encoded entries in pruning order
+	Count      int    // Number of entries
+}
+
+// EntryProof proves that an entry belongs to a prune checkpoint.
+type EntryProof struct {
+	Entry StateEntry
+	Index int // Position of the entry among the checkpoint's entries
+	Proof amf.MerkleProof
+}
+
+// CommitEntries returns the Merkle root over entries.
+func CommitEntries(entries []StateEntry) []byte {
+	leaves := make([][]byte, len(entries))
+	for i := range entries {
+		leaves[i] = entries[i].Encode()
+	}
+	return merkleRoot(leaves)
+}
+
+// ProveEntry builds the proof for entries[index] against their commitment.
+func ProveEntry(entries []StateEntry, index int) (*EntryProof, error) {
+	leaves := make([][]byte, len(entries))
+	for i := range entries {
+		leaves[i] = entries[i].Encode()
+	}
+	tree, err := amf.NewMerkleTree(leaves)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := tree.Prove(index)
+	if err != nil {
+		return nil, err
+	}
+	return &EntryProof{Entry: entries[index], Index: index, Proof: proof}, nil
+}
+
+// VerifyEntry checks an entry proof supplied by an archive against the
+// checkpoint. The proof must place the entry at p.Index among the Count
+// committed entries.
+func (cp *PruneCheckpoint) VerifyEntry(p *EntryProof) error {
+	if p.Entry.Height < cp.FromHeight || p.Entry.Height > cp.ToHeight {
+		return fmt.Errorf("%w: height %d outside %d..%d", ErrBadEntryProof, p.Entry.Height, cp.FromHeight, cp.ToHeight)
+	}
+	if !amf.VerifyProofAt(cp.Root, p.Entry.Encode(), p.Index, cp.Count, p.Proof) {
+		return fmt.Errorf("%w: entry %d of %d", ErrBadEntryProof, p.Index, cp.Count)
+	}
+	return nil
+}
+
+// Prune drops the state history of every block at or below height, which can
+// then no longer be reverted. It returns a checkpoint committing to the dropped
+// entries and the entries themselves for an archive to keep.
+func (s *StateDB) Prune(height int) (*PruneCheckpoint, []StateEntry, error) {
+	from := s.Journal.Floor() + 1
+	if height < from {
+		return nil, nil, ErrNothingToPrune
+	}
+	forgotten := s.Journal.Forget(height)
+	entries := make([]StateEntry, len(forgotten))
+	for i, je := range forgotten {
+		entries[i] = StateEntry{Height: je.Height, Key: je.Key, Existed: je.Existed}
+		if je.Existed {
+			v, err := json.Marshal(je.Prev)
+			if err != nil {
+				return nil, nil, err
+			}
+			entries[i].Value = v
+		}
+	}
+	// Blocks whose history is gone can no longer be disconnected
+	n := 0
+	for n < len(s.applied) && s.applied[n].Index <= height {
+		n++
+	}
+	s.applied = append([]*Block(nil), s.applied[n:]...)
+	// History recorded before the first block sits below the journal floor
+	if len(entries) > 0 && entries[0].Height < from {
+		from = entries[0].Height
+	}
+	cp := &PruneCheckpoint{
+		FromHeight: from,
+		ToHeight:   height,
+		Root:       CommitEntries(entries),
+		Count:      len(entries),
+	}
+	return cp, entries, nil
+}
@@ -0,0 +1,226 @@
+package blockchain
+
+// receipt.go: Transaction execution receipts, event logs and receipt proofs
+// Each block commits to a Merkle root over its receipts and to a Bloom filter
+// over the addresses and topics of their logs, so log searches can skip blocks
+// and clients can verify a receipt against a header alone.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+// Receipt status codes.
+const (
+	ReceiptFailed  uint64 = 0 // Fee charged but the transfer was not applied
+	ReceiptSuccess uint64 = 1
+)
+
+// Log topics emitted by transaction execution.
+const (
+	TopicTransfer = "transfer" // [TopicTransfer, from, to], data is the amount
+	TopicMemo     = "memo"     // [TopicMemo, to], data is the transaction payload
+)
+
+// ErrBadReceiptProof is returned when a receipt proof does not match a receipts root.
+var ErrBadReceiptProof = errors.New("receipt proof does not match receipts root")
+
+// Log is an event emitted while executing a transaction.
+type Log struct {
+	Address string   // Account that emitted the log
+	Topics  []string // Indexed fields, searchable through the block's logs Bloom
+	Data    []byte
+}
+
+// Receipt records the outcome of executing one transaction in a block.
+type Receipt struct {
+	TxHash            string
+	Status            uint64
+	GasUsed           uint64
+	CumulativeGasUsed uint64
+	Fee               uint64
+	ShardRoot         []byte // Merkle root over the post-state entries of the accounts the transaction wrote
+	Logs              []Log
+}
+
+// transferLogs returns the logs of a successful transfer.
+func transferLogs(tx *Transaction) []Log {
+	logs := []Log{{
+		Address: tx.From,
+		Topics:  []string{TopicTransfer, tx.From, tx.To},
+		Data:    binary.BigEndian.AppendUint64(nil, tx.Amount),
+	}}
+	if len(tx.Payload) > 0 {
+		logs = append(logs, Log{Address: tx.From, Topics: []string{TopicMemo, tx.To}, Data: tx.Payload})
+	}
+	return logs
+}
+
+// Encode returns the canonical binary encoding of the receipt.
+func (r *Receipt) Encode() []byte {
+	var e encoder
+	e.string(r.TxHash)
+	e.uint64(r.Status)
+	e.uint64(r.GasUsed)
+	e.uint64(r.CumulativeGasUsed)
+	e.uint64(r.Fee)
+	e.bytes(r.ShardRoot)
+	e.uint64(uint64(len(r.Logs)))
+	for _, l := range r.Logs {
+		e.string(l.Address)
+		e.uint64(uint64(len(l.Topics)))
+		for _, t := range l.Topics {
+			e.string(t)
+		}
+		e.bytes(l.Data)
+	}
+	return e.buf
+}
+
+// DecodeReceipt parses a receipt produced by Encode.
+func DecodeReceipt(data []byte) (*Receipt, error) {
+	d := decoder{buf: data}
+	r := &Receipt{
+		TxHash:            d.string(),
+		Status:            d.uint64(),
+		GasUsed:           d.uint64(),
+		CumulativeGasUsed: d.uint64(),
+		Fee:               d.uint64(),
+		ShardRoot:         nilIfEmpty(d.bytes()),
+	}
+	for n := d.uint64(); n > 0 && d.err == nil; n-- {
+		l := Log{Address: d.string()}
+		for t := d.uint64(); t > 0 && d.err == nil; t-- {
+			l.Topics = append(l.Topics, d.string())
+		}
+		l.Data = nilIfEmpty(d.bytes())
+		r.Logs = append(r.Logs, l)
+	}
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ReceiptsRoot returns the Merkle root over encoded receipts.
+func ReceiptsRoot(receipts []*Receipt) []byte {
+	leaves := make([][]byte, len(receipts))
+	for i, r := range receipts {
+		leaves[i] = r.Encode()
+	}
+	return merkleRoot(leaves)
+}
+
+// LogsBloom returns the Bloom filter over the addresses and topics of every
+// log in receipts, or nil if they have no logs.
+func LogsBloom(receipts []*Receipt) []byte {
+	var bloom amf.Bloom
+	for _, r := range receipts {
+		for _, l := range r.Logs {
+			bloom.Add(l.Address)
+			for _, t := range l.Topics {
+				bloom.Add(t)
+			}
+		}
+	}
+	if bloom.Empty() {
+		return nil
+	}
+	return bloom[:]
+}
+
+// MayHaveLog reports whether a log of the block may have item as its address
+// or a topic. False positives are possible, false negatives are not.
+func (h *BlockHeader) MayHaveLog(item string) bool {
+	return len(h.LogsBloom) > 0 && amf.BytesToBloom(h.LogsBloom).Contains(item)
+}
+
+// LogMatch is a log found by FindLogs, with the block and transaction that emitted it.
+type LogMatch struct {
+	Height    int
+	BlockHash string
+	TxHash    string
+	Log       Log
+}
+
+// FindLogs returns the logs of canonical blocks from height from through to
+// whose address or topics include item. Blocks whose logs Bloom rules out item
+// are skipped without reading their receipts.
+func (bc *Blockchain) FindLogs(item string, from, to int) []LogMatch {
+	var matches []LogMatch
+	for h := max(from, 0); h <= to; h++ {
+		b, ok := bc.BlockAt(h)
+		if !ok {
+			break
+		}
+		if !b.MayHaveLog(item) {
+			continue
+		}
+		for _, r := range b.Receipts {
+			for _, l := range r.Logs {
+				if l.has(item) {
+					matches = append(matches, LogMatch{Height: b.Index, BlockHash: b.Hash, TxHash: r.TxHash, Log: l})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// has reports whether item is the log's address or one of its topics.
+func (l *Log) has(item string) bool {
+	if l.Address == item {
+		return true
+	}
+	for _, t := range l.Topics {
+		if t == item {
+			return true
+		}
+	}
+	return false
+}
+
+// ReceiptProof proves that a receipt is included in a block.
+type ReceiptProof struct {
+	BlockHash string
+	Height    int    // Index of the block
+	Index     int    // Position of the receipt, and of its transaction, in the block
+	Count     int    // Number of receipts in the block
+	Receipt   []byte // Encoded receipt, the Merkle leaf
+	Proof     amf.MerkleProof
+}
+
+// ProveReceipt returns the inclusion proof of the receipt at index.
+func (b *Block) ProveReceipt(index int) (*ReceiptProof, error) {
+	if index < 0 || index >= len(b.Receipts) {
+		return nil, fmt.Errorf("block %d has no receipt %d", b.Index, index)
+	}
+	leaves := make([][]byte, len(b.Receipts))
+	for i, r := range b.Receipts {
+		leaves[i] = r.Encode()
+	}
+	tree, err := amf.NewMerkleTree(leaves)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := tree.Prove(index)
+	if err != nil {
+		return nil, err
+	}
+	return &ReceiptProof{BlockHash: b.Hash, Height: b.Index, Index: index, Count: len(leaves), Receipt: leaves[index], Proof: proof}, nil
+}
+
+// Verify checks the proof against receiptsRoot and returns the decoded receipt.
+func (p *ReceiptProof) Verify(receiptsRoot []byte) (*Receipt, error) {
+	if !amf.VerifyProofAt(receiptsRoot, p.Receipt, p.Index, p.Count, p.Proof) {
+		return nil, ErrBadReceiptProof
+	}
+	r, err := DecodeReceipt(p.Receipt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadReceiptProof, err)
+	}
+	return r, nil
+}
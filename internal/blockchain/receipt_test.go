@@ -0,0 +1,142 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// testReceipts returns receipts of n transfers from account i to account i+1,
+// the even ones with a memo.
+func testReceipts(n int) []*Receipt {
+	var receipts []*Receipt
+	for i := 0; i < n; i++ {
+		var payload []byte
+		if i%2 == 0 {
+			payload = []byte("memo")
+		}
+		tx := NewTransaction(fmt.Sprintf("%040x", i), fmt.Sprintf("%040x", i+1), uint64(i+1), 1, 0, payload)
+		receipts = append(receipts, &Receipt{
+			TxHash:            tx.Hash(),
+			Status:            ReceiptSuccess,
+			GasUsed:           1,
+			CumulativeGasUsed: uint64(i + 1),
+			Fee:               1,
+			ShardRoot:         []byte{byte(i)},
+			Logs:              transferLogs(tx),
+		})
+	}
+	return receipts
+}
+
+// withReceipts returns an empty block on top of parent committing to receipts.
+func withReceipts(parent *Block, ts time.Time, receipts []*Receipt) *Block {
+	b := childAt(parent, ts)
+	b.Receipts = receipts
+	b.ReceiptsRoot = ReceiptsRoot(receipts)
+	b.LogsBloom = LogsBloom(receipts)
+	b.Seal()
+	return b
+}
+
+func TestLogsBloom(t *testing.T) {
+	h := BlockHeader{LogsBloom: LogsBloom(testReceipts(3))}
+	for _, item := range []string{fmt.Sprintf("%040x", 0), fmt.Sprintf("%040x", 3), TopicTransfer, TopicMemo} {
+		if !h.MayHaveLog(item) {
+			t.Errorf("Bloom misses %q", item)
+		}
+	}
+	for _, item := range []string{fmt.Sprintf("%040x", 9), "approve", ""} {
+		if h.MayHaveLog(item) {
+			t.Errorf("Bloom hits %q", item)
+		}
+	}
+	empty := BlockHeader{LogsBloom: LogsBloom(nil)}
+	if empty.LogsBloom != nil || empty.MayHaveLog(TopicTransfer) {
+		t.Fatal("block without logs has a Bloom filter")
+	}
+}
+
+func TestReceiptsRootCommitsToEveryField(t *testing.T) {
+	root := ReceiptsRoot(testReceipts(3))
+	for name, change := range map[string]func(r *Receipt){
+		"tx hash":    func(r *Receipt) { r.TxHash = "other" },
+		"status":     func(r *Receipt) { r.Status = ReceiptFailed },
+		"gas used":   func(r *Receipt) { r.GasUsed++ },
+		"cumulative": func(r *Receipt) { r.CumulativeGasUsed++ },
+		"fee":        func(r *Receipt) { r.Fee++ },
+		"shard root": func(r *Receipt) { r.ShardRoot = []byte("other") },
+		"address":    func(r *Receipt) { r.Logs[0].Address = "other" },
+		"topic":      func(r *Receipt) { r.Logs[0].Topics[1] = "other" },
+		"data":       func(r *Receipt) { r.Logs[0].Data = []byte("other") },
+		"log":        func(r *Receipt) { r.Logs = r.Logs[:1] },
+	} {
+		receipts := testReceipts(3)
+		change(receipts[2])
+		if bytes.Equal(ReceiptsRoot(receipts), root) {
+			t.Errorf("changing the %s kept the receipts root", name)
+		}
+	}
+	for i, r := range testReceipts(3) {
+		decoded, err := DecodeReceipt(r.Encode())
+		if err != nil || !bytes.Equal(decoded.Encode(), r.Encode()) {
+			t.Fatalf("receipt %d decoded as %+v, %v", i, decoded, err)
+		}
+	}
+}
+
+func TestReceiptProof(t *testing.T) {
+	b := withReceipts(childAt(nil, start), start.Add(time.Second), testReceipts(5))
+	other := withReceipts(childAt(nil, start), start.Add(time.Second), testReceipts(4))
+	for i, want := range b.Receipts {
+		proof, err := b.ProveReceipt(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := proof.Verify(b.ReceiptsRoot)
+		if err != nil || r.TxHash != want.TxHash {
+			t.Fatalf("receipt %d: %+v, %v", i, r, err)
+		}
+		if _, err := proof.Verify(other.ReceiptsRoot); !errors.Is(err, ErrBadReceiptProof) {
+			t.Fatalf("receipt %d verified against another block: %v", i, err)
+		}
+		proof.Receipt = (&Receipt{TxHash: want.TxHash, Status: ReceiptFailed}).Encode()
+		if _, err := proof.Verify(b.ReceiptsRoot); !errors.Is(err, ErrBadReceiptProof) {
+			t.Fatalf("altered receipt %d verified: %v", i, err)
+		}
+	}
+	if _, err := b.ProveReceipt(5); err == nil {
+		t.Fatal("proved a receipt past the end")
+	}
+}
+
+func TestFindLogs(t *testing.T) {
+	bc := NewBlockchain(LongestChain{})
+	g := add(t, bc, childAt(nil, at(0)))
+	b1 := add(t, bc, withReceipts(g, at(1), testReceipts(2)))
+	b2 := add(t, bc, childAt(b1, at(2)))
+	b3 := add(t, bc, withReceipts(b2, at(3), testReceipts(3)))
+
+	// Account 1 received the first transfer, with a memo, and sent the second
+	matches := bc.FindLogs(fmt.Sprintf("%040x", 1), 0, 3)
+	if len(matches) != 6 {
+		t.Fatalf("found %d logs of account 1, want 6", len(matches))
+	}
+	if m := matches[0]; m.Height != 1 || m.BlockHash != b1.Hash || m.TxHash != b1.Receipts[0].TxHash {
+		t.Fatalf("first match %+v", m)
+	}
+	if m := matches[5]; m.Height != 3 || m.BlockHash != b3.Hash {
+		t.Fatalf("last match %+v", m)
+	}
+	if matches := bc.FindLogs(TopicMemo, 2, 3); len(matches) != 2 || matches[0].Height != 3 {
+		t.Fatalf("memos in blocks 2 to 3: %+v", matches)
+	}
+	if matches := bc.FindLogs(fmt.Sprintf("%040x", 3), 0, 1); len(matches) != 0 {
+		t.Fatalf("found logs outside the range: %+v", matches)
+	}
+	if matches := bc.FindLogs(TopicTransfer, 0, 99); len(matches) != 5 {
+		t.Fatalf("found %d transfers past the head, want 5", len(matches))
+	}
+}
@@ -0,0 +1,66 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// replayChain returns the genesis spec and a chain of n blocks on it that
+// execute on its state.
+func replayChain(t *testing.T, n int) (*Genesis, []*Block) {
+	t.Helper()
+	g := DefaultGenesis()
+	g.Alloc["1111111111111111111111111111111111111111"] = 1000
+	if err := g.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	genesis, err := g.Block()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := g.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc := NewBlockchain(LongestChain{})
+	bc.Subscribe(state.OnChainEvent)
+	blocks := []*Block{add(t, bc, genesis)}
+	for i := 1; i < n; i++ {
+		blocks = append(blocks, add(t, bc, stateChild(blocks[i-1], state, at(float64(i)))))
+	}
+	return g, blocks
+}
+
+func TestReplay(t *testing.T) {
+	g, blocks := replayChain(t, 4)
+	state, err := g.Replay(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(state.Root(), blocks[3].StateRoot) {
+		t.Fatal("replayed state does not match the last block")
+	}
+
+	badRoot := stateChild(blocks[3], state, at(4))
+	badRoot.StateRoot = []byte("not the executed root")
+	badRoot.Seal()
+	_, err = g.Replay(append(blocks[:4:4], badRoot))
+	var be *BlockError
+	if err == nil || !errors.As(err, &be) || be.Hash != badRoot.Hash {
+		t.Fatalf("block with a bad state root: %v", err)
+	}
+
+	// A sibling of block 2 is valid on its own but does not extend block 2
+	sibling := stateChild(blocks[1], state, at(2.5))
+	if _, err := g.Replay(append(blocks[:3:3], sibling)); !errors.Is(err, ErrBadParent) {
+		t.Fatalf("block not extending the previous one: %v", err)
+	}
+	if _, err := g.Replay([]*Block{blocks[0], blocks[2]}); !errors.Is(err, ErrBadParent) {
+		t.Fatalf("dump with a gap: %v", err)
+	}
+	other := DefaultGenesis()
+	if _, err := other.Replay(blocks); !errors.Is(err, ErrGenesisMismatch) {
+		t.Fatalf("dump of another genesis: %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package blockchain
+
+// rlp.go: RLP encoding of the core containers for Ethereum tooling
+// Integers are minimal big-endian strings, hashes and addresses raw bytes. A
+// block is the list [header, transactions, receipts] where each transaction is
+// its raw canonical encoding.
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+	"github.com/bilal2134/Blockchain_A3/internal/rlp"
+)
+
+// hexBytes decodes a hex string field; "" yields nil.
+func hexBytes(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return hex.DecodeString(s)
+}
+
+// bytesHex is the inverse of hexBytes.
+func bytesHex(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// nilIfEmpty maps an empty decoded string to nil so fields round-trip.
+func nilIfEmpty(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// EncodeRLP returns the RLP encoding of the transaction.
+func (tx *Transaction) EncodeRLP() ([]byte, error) {
+	from, to, err := tx.sszAddresses()
+	if err != nil {
+		return nil, err
+	}
+	return rlp.EncodeList(
+		rlp.EncodeBytes(from),
+		rlp.EncodeBytes(to),
+		rlp.EncodeUint(tx.Amount),
+		rlp.EncodeUint(tx.Fee),
+		rlp.EncodeUint(tx.Nonce),
+		rlp.EncodeBytes(tx.Payload),
+		rlp.EncodeBytes(tx.PublicKey),
+		rlp.EncodeBytes(tx.Signature),
+	), nil
+}
+
+// RLPHash returns the Keccak-256 digest of the transaction's RLP encoding.
+func (tx *Transaction) RLPHash() ([]byte, error) {
+	enc, err := tx.EncodeRLP()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(enc), nil
+}
+
+// DecodeTransactionRLP parses an RLP-encoded transaction.
+func DecodeTransactionRLP(b []byte) (*Transaction, error) {
+	it, err := rlp.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return transactionFromRLP(it)
+}
+
+func transactionFromRLP(it rlp.Item) (*Transaction, error) {
+	f, err := it.Elems(8)
+	if err != nil {
+		return nil, err
+	}
+	var r rlpReader
+	tx := &Transaction{
+		From:      bytesHex(r.bytes(f[0])),
+		To:        bytesHex(r.bytes(f[1])),
+		Amount:    r.uint64(f[2]),
+		Fee:       r.uint64(f[3]),
+		Nonce:     r.uint64(f[4]),
+		Payload:   nilIfEmpty(r.bytes(f[5])),
+		PublicKey: nilIfEmpty(r.bytes(f[6])),
+		Signature: nilIfEmpty(r.bytes(f[7])),
+	}
+	return tx, r.err
+}
+
+// EncodeRLP returns the RLP encoding of the header.
+func (h *BlockHeader) EncodeRLP() ([]byte, error) {
+	prev, err := hexBytes(h.PrevHash)
+	if err != nil {
+		return nil, fmt.Errorf("prev hash: %w", err)
+	}
+	return rlp.EncodeList(
+		rlp.EncodeUint(uint64(h.Index)),
+		rlp.EncodeUint(uint64(h.Timestamp.UnixNano())),
+		rlp.EncodeBytes(prev),
+		rlp.EncodeBytes(h.Accumulator),
+		rlp.EncodeBytes(h.TxRoot),
+		rlp.EncodeBytes(h.StateRoot),
+		rlp.EncodeBytes(h.ReceiptsRoot),
+		rlp.EncodeUint(h.GasUsed),
+		rlp.EncodeString(h.Proposer),
+		rlp.EncodeUint(h.Round),
+		rlp.EncodeBytes(h.CertHash),
+		rlp.EncodeBytes(h.MMRRoot),
+		rlp.EncodeBytes(h.LogsBloom),
+		rlp.EncodeBytes(h.ConfigHash),
+	), nil
+}
+
+// RLPHash returns the Keccak-256 digest of the header's RLP encoding.
+func (h *BlockHeader) RLPHash() ([]byte, error) {
+	enc, err := h.EncodeRLP()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(enc), nil
+}
+
+// DecodeHeaderRLP parses an RLP-encoded header.
+func DecodeHeaderRLP(b []byte) (*BlockHeader, error) {
+	it, err := rlp.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return headerFromRLP(it)
+}
+
+func headerFromRLP(it rlp.Item) (*BlockHeader, error) {
+	f, err := it.Elems(14)
+	if err != nil {
+		return nil, err
+	}
+	var r rlpReader
+	h := &BlockHeader{
+		Index:        int(r.uint64(f[0])),
+		Timestamp:    time.Unix(0, int64(r.uint64(f[1]))),
+		PrevHash:     bytesHex(r.bytes(f[2])),
+		Accumulator:  nilIfEmpty(r.bytes(f[3])),
+		TxRoot:       nilIfEmpty(r.bytes(f[4])),
+		StateRoot:    nilIfEmpty(r.bytes(f[5])),
+		ReceiptsRoot: nilIfEmpty(r.bytes(f[6])),
+		GasUsed:      r.uint64(f[7]),
+		Proposer:     string(r.bytes(f[8])),
+		Round:        r.uint64(f[9]),
+		CertHash:     nilIfEmpty(r.bytes(f[10])),
+		MMRRoot:      nilIfEmpty(r.bytes(f[11])),
+		LogsBloom:    nilIfEmpty(r.bytes(f[12])),
+		ConfigHash:   nilIfEmpty(r.bytes(f[13])),
+	}
+	return h, r.err
+}
+
+// EncodeRLP returns the RLP encoding of the receipt.
+func (rc *Receipt) EncodeRLP() ([]byte, error) {
+	hash, err := hexBytes(rc.TxHash)
+	if err != nil {
+		return nil, fmt.Errorf("tx hash: %w", err)
+	}
+	logs := make([][]byte, len(rc.Logs))
+	for i, l := range rc.Logs {
+		topics := make([][]byte, len(l.Topics))
+		for j, t := range l.Topics {
+			topics[j] = rlp.EncodeString(t)
+		}
+		logs[i] = rlp.EncodeList(rlp.EncodeString(l.Address), rlp.EncodeList(topics...), rlp.EncodeBytes(l.Data))
+	}
+	return rlp.EncodeList(
+		rlp.EncodeBytes(hash),
+		rlp.EncodeUint(rc.Status),
+		rlp.EncodeUint(rc.GasUsed),
+		rlp.EncodeUint(rc.CumulativeGasUsed),
+		rlp.EncodeUint(rc.Fee),
+		rlp.EncodeBytes(rc.ShardRoot),
+		rlp.EncodeList(logs...),
+	), nil
+}
+
+func receiptFromRLP(it rlp.Item) (*Receipt, error) {
+	f, err := it.Elems(7)
+	if err != nil {
+		return nil, err
+	}
+	var r rlpReader
+	rc := &Receipt{
+		TxHash:            bytesHex(r.bytes(f[0])),
+		Status:            r.uint64(f[1]),
+		GasUsed:           r.uint64(f[2]),
+		CumulativeGasUsed: r.uint64(f[3]),
+		Fee:               r.uint64(f[4]),
+		ShardRoot:         nilIfEmpty(r.bytes(f[5])),
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	logs, err := f[6].Elems(-1)
+	if err != nil {
+		return nil, err
+	}
+	for i, li := range logs {
+		l, err := logFromRLP(li)
+		if err != nil {
+			return nil, fmt.Errorf("log %d: %w", i, err)
+		}
+		rc.Logs = append(rc.Logs, *l)
+	}
+	return rc, nil
+}
+
+func logFromRLP(it rlp.Item) (*Log, error) {
+	f, err := it.Elems(3)
+	if err != nil {
+		return nil, err
+	}
+	topics, err := f[1].Elems(-1)
+	if err != nil {
+		return nil, err
+	}
+	var r rlpReader
+	l := &Log{Address: string(r.bytes(f[0])), Data: nilIfEmpty(r.bytes(f[2]))}
+	for _, t := range topics {
+		l.Topics = append(l.Topics, string(r.bytes(t)))
+	}
+	return l, r.err
+}
+
+// EncodeRLP returns the RLP encoding of the block.
+func (b *Block) EncodeRLP() ([]byte, error) {
+	header, err := b.BlockHeader.EncodeRLP()
+	if err != nil {
+		return nil, err
+	}
+	txs := make([][]byte, len(b.Transactions))
+	for i, enc := range b.Transactions {
+		raw, err := hex.DecodeString(enc)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		txs[i] = rlp.EncodeBytes(raw)
+	}
+	receipts := make([][]byte, len(b.Receipts))
+	for i, rc := range b.Receipts {
+		if receipts[i], err = rc.EncodeRLP(); err != nil {
+			return nil, err
+		}
+	}
+	return rlp.EncodeList(header, rlp.EncodeList(txs...), rlp.EncodeList(receipts...)), nil
+}
+
+// DecodeBlockRLP parses an RLP-encoded block and recomputes its derived fields.
+func DecodeBlockRLP(data []byte) (*Block, error) {
+	it, err := rlp.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	f, err := it.Elems(3)
+	if err != nil {
+		return nil, err
+	}
+	header, err := headerFromRLP(f[0])
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	b := &Block{BlockHeader: *header}
+	txs, err := f[1].Elems(-1)
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range txs {
+		raw, err := t.Text()
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		b.Transactions = append(b.Transactions, hex.EncodeToString([]byte(raw)))
+	}
+	receipts, err := f[2].Elems(-1)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range receipts {
+		rc, err := receiptFromRLP(r)
+		if err != nil {
+			return nil, fmt.Errorf("receipt %d: %w", i, err)
+		}
+		b.Receipts = append(b.Receipts, rc)
+	}
+	b.Seal()
+	return b, nil
+}
+
+// rlpReader extracts typed fields from decoded items, recording the first error.
+type rlpReader struct {
+	err error
+}
+
+func (r *rlpReader) uint64(it rlp.Item) uint64 {
+	v, err := it.Uint64()
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return v
+}
+
+func (r *rlpReader) bytes(it rlp.Item) []byte {
+	if it.IsList {
+		if r.err == nil {
+			r.err = rlp.ErrExpectedString
+		}
+		return nil
+	}
+	return it.Bytes
+}
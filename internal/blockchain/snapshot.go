@@ -0,0 +1,90 @@
+package blockchain
+
+// snapshot.go: Serialized account state for snapshots
+// A snapshot is every account sorted by address, so equal states always
+// serialize to the same bytes and share one content hash.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+// snapshotVersion is written first in every encoded snapshot.
+const snapshotVersion = 1
+
+// ErrStateRootMismatch is returned when restored state does not have the expected root.
+var ErrStateRootMismatch = errors.New("restored state root mismatch")
+
+// Accounts returns every account in the state keyed by address.
+func (s *StateDB) Accounts() (map[string]Account, error) {
+	accounts := make(map[string]Account)
+	for key, v := range s.Forest.ReconstructState() {
+		addr, ok := strings.CutPrefix(key, accountPrefix)
+		acct, isAcct := v.(Account)
+		if !ok || !isAcct {
+			return nil, fmt.Errorf("unexpected state entry %q", key)
+		}
+		accounts[addr] = acct
+	}
+	return accounts, nil
+}
+
+// EncodeSnapshot serializes the state.
+func (s *StateDB) EncodeSnapshot() ([]byte, error) {
+	accounts, err := s.Accounts()
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(accounts))
+	for addr := range accounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	var e encoder
+	e.uint64(snapshotVersion)
+	e.uint64(uint64(len(addrs)))
+	for _, addr := range addrs {
+		e.string(addr)
+		e.uint64(accounts[addr].Balance)
+		e.uint64(accounts[addr].Nonce)
+	}
+	return e.buf, nil
+}
+
+// RestoreSnapshot rebuilds state from an encoded snapshot and checks that its
+// root equals root.
+func RestoreSnapshot(data, root []byte, cfg amf.RebalanceConfig) (*StateDB, error) {
+	d := decoder{buf: data}
+	if v := d.uint64(); d.err == nil && v != snapshotVersion {
+		return nil, errUnknownVersion
+	}
+	n := d.uint64()
+	if d.err == nil && n > uint64(len(data)) {
+		return nil, errShortBuffer
+	}
+	state := NewStateDB(amf.NewForest(), cfg)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		addr := d.string()
+		acct := Account{Balance: d.uint64(), Nonce: d.uint64()}
+		if d.err != nil {
+			break
+		}
+		if err := state.SetAccount(addr, acct); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+	if got := state.Root(); !bytes.Equal(got, root) {
+		return nil, fmt.Errorf("%w: got %x, want %x", ErrStateRootMismatch, got, root)
+	}
+	// The restored state is the new baseline; nothing before it can be reverted.
+	state.Journal = amf.NewJournal(state.Forest, cfg)
+	return state, nil
+}
@@ -0,0 +1,475 @@
+package blockchain
+
+// ssz.go: SSZ serialization and hash tree roots of the core containers
+// Hashes and roots are 32-byte vectors and addresses 20-byte vectors; an empty
+// hash, root or address is encoded as all zeros and decodes back to empty.
+// Derived block fields (Merkle levels, entropy, hash) are not serialized and
+// are recomputed from the header when a block is decoded.
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+	"github.com/bilal2134/Blockchain_A3/internal/ssz"
+)
+
+// SSZ list limits.
+const (
+	MaxIDLength        = 64      // Proposer and validator IDs
+	MaxPublicKeySize   = 64      // Transaction public keys
+	MaxSignatureSize   = 96      // Transaction and attestation signatures
+	MaxPayloadSize     = 1 << 20 // Transaction payloads
+	MaxTransactionSize = 1 << 21 // Encoded transactions carried in a block
+	MaxBlockTxs        = 1 << 20 // Transactions (and receipts) per block
+	MaxReceiptLogs     = 16      // Logs per receipt
+	MaxLogTopics       = 4       // Topics per log
+	MaxTopicSize       = 64      // Bytes per log topic
+)
+
+// fixedFromHex decodes a hex string of n bytes; "" yields nil.
+func fixedFromHex(s string, n int) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != n {
+		return nil, fmt.Errorf("%w: %q is not %d hex bytes", ssz.ErrBadSize, s, n)
+	}
+	return b, nil
+}
+
+// hexFromFixed is the inverse of fixedFromHex.
+func hexFromFixed(b []byte) string {
+	if isZero(b) {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// nilIfZero maps an all-zero vector back to nil.
+func nilIfZero(b []byte) []byte {
+	if isZero(b) {
+		return nil
+	}
+	return b
+}
+
+func isZero(b []byte) bool {
+	return len(bytes.Trim(b, "\x00")) == 0
+}
+
+// MarshalSSZ returns the SSZ encoding of the header.
+func (h *BlockHeader) MarshalSSZ() ([]byte, error) {
+	prev, err := fixedFromHex(h.PrevHash, 32)
+	if err != nil {
+		return nil, err
+	}
+	var e ssz.Encoder
+	e.Uint64(uint64(h.Index))
+	e.Uint64(uint64(h.Timestamp.UnixNano()))
+	e.Bytes32(prev)
+	e.Bytes32(h.Accumulator)
+	e.Bytes32(h.TxRoot)
+	e.Bytes32(h.StateRoot)
+	e.Bytes32(h.ReceiptsRoot)
+	e.Uint64(h.GasUsed)
+	e.ByteList([]byte(h.Proposer), MaxIDLength)
+	e.Uint64(h.Round)
+	e.Bytes32(h.CertHash)
+	e.Bytes32(h.MMRRoot)
+	e.Fixed(h.LogsBloom, amf.BloomSize)
+	e.Bytes32(h.ConfigHash)
+	return e.Finish()
+}
+
+// UnmarshalSSZ decodes an SSZ-encoded header.
+func (h *BlockHeader) UnmarshalSSZ(buf []byte) error {
+	d := ssz.NewDecoder(buf)
+	var proposer []byte
+	h.Index = int(d.Uint64())
+	h.Timestamp = time.Unix(0, int64(d.Uint64()))
+	h.PrevHash = hexFromFixed(d.Bytes32())
+	h.Accumulator = nilIfZero(d.Bytes32())
+	h.TxRoot = nilIfZero(d.Bytes32())
+	h.StateRoot = nilIfZero(d.Bytes32())
+	h.ReceiptsRoot = nilIfZero(d.Bytes32())
+	h.GasUsed = d.Uint64()
+	d.ByteList(&proposer, MaxIDLength)
+	h.Round = d.Uint64()
+	h.CertHash = nilIfZero(d.Bytes32())
+	h.MMRRoot = nilIfZero(d.Bytes32())
+	h.LogsBloom = nilIfZero(d.Fixed(amf.BloomSize))
+	h.ConfigHash = nilIfZero(d.Bytes32())
+	if err := d.Finish(); err != nil {
+		return err
+	}
+	h.Proposer = string(proposer)
+	return nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the header.
+func (h *BlockHeader) HashTreeRoot() (ssz.Root, error) {
+	prev, err := fixedFromHex(h.PrevHash, 32)
+	if err != nil {
+		return ssz.Root{}, err
+	}
+	return ssz.ContainerRoot(
+		ssz.Uint64Root(uint64(h.Index)),
+		ssz.Uint64Root(uint64(h.Timestamp.UnixNano())),
+		ssz.FixedRoot(prev, 32),
+		ssz.FixedRoot(h.Accumulator, 32),
+		ssz.FixedRoot(h.TxRoot, 32),
+		ssz.FixedRoot(h.StateRoot, 32),
+		ssz.FixedRoot(h.ReceiptsRoot, 32),
+		ssz.Uint64Root(h.GasUsed),
+		ssz.ByteListRoot([]byte(h.Proposer), MaxIDLength),
+		ssz.Uint64Root(h.Round),
+		ssz.FixedRoot(h.CertHash, 32),
+		ssz.FixedRoot(h.MMRRoot, 32),
+		ssz.FixedRoot(h.LogsBloom, amf.BloomSize),
+		ssz.FixedRoot(h.ConfigHash, 32),
+	), nil
+}
+
+// sszAddresses decodes the sender and recipient addresses.
+func (tx *Transaction) sszAddresses() (from, to []byte, err error) {
+	if from, err = fixedFromHex(tx.From, crypto.AddressLength); err != nil {
+		return nil, nil, err
+	}
+	if to, err = fixedFromHex(tx.To, crypto.AddressLength); err != nil {
+		return nil, nil, err
+	}
+	return from, to, nil
+}
+
+// MarshalSSZ returns the SSZ encoding of the transaction.
+func (tx *Transaction) MarshalSSZ() ([]byte, error) {
+	from, to, err := tx.sszAddresses()
+	if err != nil {
+		return nil, err
+	}
+	var e ssz.Encoder
+	e.Fixed(from, crypto.AddressLength)
+	e.Fixed(to, crypto.AddressLength)
+	e.Uint64(tx.Amount)
+	e.Uint64(tx.Fee)
+	e.Uint64(tx.Nonce)
+	e.ByteList(tx.Payload, MaxPayloadSize)
+	e.ByteList(tx.PublicKey, MaxPublicKeySize)
+	e.ByteList(tx.Signature, MaxSignatureSize)
+	return e.Finish()
+}
+
+// UnmarshalSSZ decodes an SSZ-encoded transaction.
+func (tx *Transaction) UnmarshalSSZ(buf []byte) error {
+	d := ssz.NewDecoder(buf)
+	tx.From = hexFromFixed(d.Fixed(crypto.AddressLength))
+	tx.To = hexFromFixed(d.Fixed(crypto.AddressLength))
+	tx.Amount = d.Uint64()
+	tx.Fee = d.Uint64()
+	tx.Nonce = d.Uint64()
+	d.ByteList(&tx.Payload, MaxPayloadSize)
+	d.ByteList(&tx.PublicKey, MaxPublicKeySize)
+	d.ByteList(&tx.Signature, MaxSignatureSize)
+	return d.Finish()
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the transaction.
+func (tx *Transaction) HashTreeRoot() (ssz.Root, error) {
+	from, to, err := tx.sszAddresses()
+	if err != nil {
+		return ssz.Root{}, err
+	}
+	return ssz.ContainerRoot(
+		ssz.FixedRoot(from, crypto.AddressLength),
+		ssz.FixedRoot(to, crypto.AddressLength),
+		ssz.Uint64Root(tx.Amount),
+		ssz.Uint64Root(tx.Fee),
+		ssz.Uint64Root(tx.Nonce),
+		ssz.ByteListRoot(tx.Payload, MaxPayloadSize),
+		ssz.ByteListRoot(tx.PublicKey, MaxPublicKeySize),
+		ssz.ByteListRoot(tx.Signature, MaxSignatureSize),
+	), nil
+}
+
+// topicBytes returns the log topics as byte strings, checking their limits.
+func (l *Log) topicBytes() ([][]byte, error) {
+	if len(l.Topics) > MaxLogTopics {
+		return nil, fmt.Errorf("%w: %d topics, limit %d", ssz.ErrTooLong, len(l.Topics), MaxLogTopics)
+	}
+	topics := make([][]byte, len(l.Topics))
+	for i, t := range l.Topics {
+		if len(t) > MaxTopicSize {
+			return nil, fmt.Errorf("%w: topic of %d bytes, limit %d", ssz.ErrTooLong, len(t), MaxTopicSize)
+		}
+		topics[i] = []byte(t)
+	}
+	return topics, nil
+}
+
+// MarshalSSZ returns the SSZ encoding of the log.
+func (l *Log) MarshalSSZ() ([]byte, error) {
+	topics, err := l.topicBytes()
+	if err != nil {
+		return nil, err
+	}
+	var e ssz.Encoder
+	e.ByteList([]byte(l.Address), MaxIDLength)
+	e.List(topics, MaxLogTopics, true)
+	e.ByteList(l.Data, MaxPayloadSize)
+	return e.Finish()
+}
+
+// UnmarshalSSZ decodes an SSZ-encoded log.
+func (l *Log) UnmarshalSSZ(buf []byte) error {
+	d := ssz.NewDecoder(buf)
+	var address []byte
+	var topics []string
+	d.ByteList(&address, MaxIDLength)
+	d.List(MaxLogTopics, 0, func(_ int, t []byte) error {
+		if len(t) > MaxTopicSize {
+			return ssz.ErrTooLong
+		}
+		topics = append(topics, string(t))
+		return nil
+	})
+	d.ByteList(&l.Data, MaxPayloadSize)
+	if err := d.Finish(); err != nil {
+		return err
+	}
+	l.Address, l.Topics = string(address), topics
+	return nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the log.
+func (l *Log) HashTreeRoot() (ssz.Root, error) {
+	topics, err := l.topicBytes()
+	if err != nil {
+		return ssz.Root{}, err
+	}
+	topicRoots := make([]ssz.Root, len(topics))
+	for i, t := range topics {
+		topicRoots[i] = ssz.ByteListRoot(t, MaxTopicSize)
+	}
+	return ssz.ContainerRoot(
+		ssz.ByteListRoot([]byte(l.Address), MaxIDLength),
+		ssz.ListRoot(topicRoots, MaxLogTopics),
+		ssz.ByteListRoot(l.Data, MaxPayloadSize),
+	), nil
+}
+
+// MarshalSSZ returns the SSZ encoding of the receipt.
+func (r *Receipt) MarshalSSZ() ([]byte, error) {
+	hash, err := fixedFromHex(r.TxHash, 32)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([][]byte, len(r.Logs))
+	for i := range r.Logs {
+		if logs[i], err = r.Logs[i].MarshalSSZ(); err != nil {
+			return nil, err
+		}
+	}
+	var e ssz.Encoder
+	e.Bytes32(hash)
+	e.Uint64(r.Status)
+	e.Uint64(r.GasUsed)
+	e.Uint64(r.CumulativeGasUsed)
+	e.Uint64(r.Fee)
+	e.Bytes32(r.ShardRoot)
+	e.List(logs, MaxReceiptLogs, true)
+	return e.Finish()
+}
+
+// UnmarshalSSZ decodes an SSZ-encoded receipt.
+func (r *Receipt) UnmarshalSSZ(buf []byte) error {
+	d := ssz.NewDecoder(buf)
+	var logs []Log
+	r.TxHash = hexFromFixed(d.Bytes32())
+	r.Status = d.Uint64()
+	r.GasUsed = d.Uint64()
+	r.CumulativeGasUsed = d.Uint64()
+	r.Fee = d.Uint64()
+	r.ShardRoot = nilIfZero(d.Bytes32())
+	d.List(MaxReceiptLogs, 0, func(_ int, enc []byte) error {
+		var l Log
+		if err := l.UnmarshalSSZ(enc); err != nil {
+			return err
+		}
+		logs = append(logs, l)
+		return nil
+	})
+	if err := d.Finish(); err != nil {
+		return err
+	}
+	r.Logs = logs
+	return nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the receipt.
+func (r *Receipt) HashTreeRoot() (ssz.Root, error) {
+	hash, err := fixedFromHex(r.TxHash, 32)
+	if err != nil {
+		return ssz.Root{}, err
+	}
+	logRoots := make([]ssz.Root, len(r.Logs))
+	for i := range r.Logs {
+		if logRoots[i], err = r.Logs[i].HashTreeRoot(); err != nil {
+			return ssz.Root{}, err
+		}
+	}
+	return ssz.ContainerRoot(
+		ssz.FixedRoot(hash, 32),
+		ssz.Uint64Root(r.Status),
+		ssz.Uint64Root(r.GasUsed),
+		ssz.Uint64Root(r.CumulativeGasUsed),
+		ssz.Uint64Root(r.Fee),
+		ssz.FixedRoot(r.ShardRoot, 32),
+		ssz.ListRoot(logRoots, MaxReceiptLogs),
+	), nil
+}
+
+// MarshalSSZ returns the SSZ encoding of the attestation.
+func (a *Attestation) MarshalSSZ() ([]byte, error) {
+	hash, err := fixedFromHex(a.BlockHash, 32)
+	if err != nil {
+		return nil, err
+	}
+	var e ssz.Encoder
+	e.ByteList([]byte(a.Validator), MaxIDLength)
+	e.Uint64(a.Round)
+	e.Bytes32(hash)
+	e.ByteList(a.Signature, MaxSignatureSize)
+	return e.Finish()
+}
+
+// UnmarshalSSZ decodes an SSZ-encoded attestation.
+func (a *Attestation) UnmarshalSSZ(buf []byte) error {
+	d := ssz.NewDecoder(buf)
+	var validator []byte
+	d.ByteList(&validator, MaxIDLength)
+	a.Round = d.Uint64()
+	a.BlockHash = hexFromFixed(d.Bytes32())
+	d.ByteList(&a.Signature, MaxSignatureSize)
+	if err := d.Finish(); err != nil {
+		return err
+	}
+	a.Validator = string(validator)
+	return nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the attestation.
+func (a *Attestation) HashTreeRoot() (ssz.Root, error) {
+	hash, err := fixedFromHex(a.BlockHash, 32)
+	if err != nil {
+		return ssz.Root{}, err
+	}
+	return ssz.ContainerRoot(
+		ssz.ByteListRoot([]byte(a.Validator), MaxIDLength),
+		ssz.Uint64Root(a.Round),
+		ssz.FixedRoot(hash, 32),
+		ssz.ByteListRoot(a.Signature, MaxSignatureSize),
+	), nil
+}
+
+// sszParts returns the encoded header, the raw transactions and the encoded receipts.
+func (b *Block) sszParts() (header []byte, txs, receipts [][]byte, err error) {
+	if header, err = b.BlockHeader.MarshalSSZ(); err != nil {
+		return nil, nil, nil, err
+	}
+	for i, enc := range b.Transactions {
+		raw, err := hex.DecodeString(enc)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		txs = append(txs, raw)
+	}
+	for _, r := range b.Receipts {
+		enc, err := r.MarshalSSZ()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		receipts = append(receipts, enc)
+	}
+	return header, txs, receipts, nil
+}
+
+// MarshalSSZ returns the SSZ encoding of the block: its header, the raw bytes
+// of each transaction and the receipts.
+func (b *Block) MarshalSSZ() ([]byte, error) {
+	header, txs, receipts, err := b.sszParts()
+	if err != nil {
+		return nil, err
+	}
+	for i, tx := range txs {
+		if len(tx) > MaxTransactionSize {
+			return nil, fmt.Errorf("transaction %d: %w", i, ssz.ErrTooLong)
+		}
+	}
+	var e ssz.Encoder
+	e.Variable(header)
+	e.List(txs, MaxBlockTxs, true)
+	e.List(receipts, MaxBlockTxs, true)
+	return e.Finish()
+}
+
+// UnmarshalSSZ decodes an SSZ-encoded block and recomputes its derived fields.
+func (b *Block) UnmarshalSSZ(buf []byte) error {
+	d := ssz.NewDecoder(buf)
+	var header BlockHeader
+	var txs []string
+	var receipts []*Receipt
+	d.Variable(header.UnmarshalSSZ)
+	d.List(MaxBlockTxs, 0, func(_ int, raw []byte) error {
+		if len(raw) > MaxTransactionSize {
+			return ssz.ErrTooLong
+		}
+		txs = append(txs, hex.EncodeToString(raw))
+		return nil
+	})
+	d.List(MaxBlockTxs, 0, func(_ int, enc []byte) error {
+		r := new(Receipt)
+		if err := r.UnmarshalSSZ(enc); err != nil {
+			return err
+		}
+		receipts = append(receipts, r)
+		return nil
+	})
+	if err := d.Finish(); err != nil {
+		return err
+	}
+	*b = Block{BlockHeader: header, Transactions: txs, Receipts: receipts}
+	b.Seal()
+	return nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the block.
+func (b *Block) HashTreeRoot() (ssz.Root, error) {
+	header, err := b.BlockHeader.HashTreeRoot()
+	if err != nil {
+		return ssz.Root{}, err
+	}
+	_, txs, _, err := b.sszParts()
+	if err != nil {
+		return ssz.Root{}, err
+	}
+	txRoots := make([]ssz.Root, len(txs))
+	for i, tx := range txs {
+		txRoots[i] = ssz.ByteListRoot(tx, MaxTransactionSize)
+	}
+	receiptRoots := make([]ssz.Root, len(b.Receipts))
+	for i, r := range b.Receipts {
+		if receiptRoots[i], err = r.HashTreeRoot(); err != nil {
+			return ssz.Root{}, err
+		}
+	}
+	return ssz.ContainerRoot(
+		header,
+		ssz.ListRoot(txRoots, MaxBlockTxs),
+		ssz.ListRoot(receiptRoots, MaxBlockTxs),
+	), nil
+}
@@ -0,0 +1,213 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+	"github.com/bilal2134/Blockchain_A3/internal/ssz"
+)
+
+// testKeys signs with ed25519 keys by address.
+type testKeys map[string]ed25519.PrivateKey
+
+// newTestKeys returns n keys and their addresses.
+func newTestKeys(t testing.TB, n int) (testKeys, []string) {
+	t.Helper()
+	keys := make(testKeys)
+	var addrs []string
+	for i := 0; i < n; i++ {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := crypto.PubkeyToAddress(pub)
+		keys[addr] = priv
+		addrs = append(addrs, addr)
+	}
+	return keys, addrs
+}
+
+// PublicKey returns the public key of address.
+func (k testKeys) PublicKey(address string) ([]byte, error) {
+	key, ok := k[address]
+	if !ok {
+		return nil, errors.New("unknown signer")
+	}
+	return key.Public().(ed25519.PublicKey), nil
+}
+
+// Sign signs msg with the key of address.
+func (k testKeys) Sign(address string, msg []byte) ([]byte, error) {
+	key, ok := k[address]
+	if !ok {
+		return nil, errors.New("unknown signer")
+	}
+	return ed25519.Sign(key, msg), nil
+}
+
+// sszTestBlock returns a sealed block with signed transactions, receipts with
+// logs and every header field set.
+func sszTestBlock(t *testing.T) *Block {
+	t.Helper()
+	keys, addrs := newTestKeys(t, 2)
+	var txs []string
+	var receipts []*Receipt
+	for i := 0; i < 3; i++ {
+		tx := NewTransaction(addrs[i%2], addrs[(i+1)%2], uint64(10+i), 1, uint64(i), []byte{byte(i)})
+		if err := tx.Sign(keys); err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx.Encode())
+		receipts = append(receipts, &Receipt{
+			TxHash:            tx.Hash(),
+			Status:            ReceiptSuccess,
+			GasUsed:           21000,
+			CumulativeGasUsed: uint64(21000 * (i + 1)),
+			Fee:               1,
+			ShardRoot:         bytes.Repeat([]byte{byte(i + 1)}, 32),
+			Logs:              transferLogs(tx),
+		})
+	}
+	b := NewBlock(7, "ab"+string(bytes.Repeat([]byte("0"), 62)), txs)
+	b.Timestamp = time.Unix(1700000000, 123456789)
+	b.StateRoot = bytes.Repeat([]byte{0x11}, 32)
+	b.Receipts = receipts
+	b.ReceiptsRoot = ReceiptsRoot(receipts)
+	b.LogsBloom = LogsBloom(receipts)
+	b.GasUsed = 63000
+	b.Proposer = "validator-1"
+	b.Round = 9
+	b.CertHash = bytes.Repeat([]byte{0x22}, 32)
+	b.MMRRoot = bytes.Repeat([]byte{0x33}, 32)
+	b.ConfigHash = bytes.Repeat([]byte{0x44}, 32)
+	b.Seal()
+	if err := b.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestHeaderSSZRoundTrip(t *testing.T) {
+	b := sszTestBlock(t)
+	for _, h := range []BlockHeader{b.BlockHeader, NewBlock(0, "", nil).BlockHeader} {
+		enc, err := h.MarshalSSZ()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got BlockHeader
+		if err := got.UnmarshalSSZ(enc); err != nil {
+			t.Fatal(err)
+		}
+		if got.Hash() != h.Hash() {
+			t.Fatalf("header %d: hash %s after round trip, want %s", h.Index, got.Hash(), h.Hash())
+		}
+		if !got.Timestamp.Equal(h.Timestamp) || got.Proposer != h.Proposer || got.PrevHash != h.PrevHash {
+			t.Fatalf("header %d: fields differ after round trip", h.Index)
+		}
+		want, err := h.HashTreeRoot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root, err := got.HashTreeRoot(); err != nil || root != want {
+			t.Fatalf("header %d: hash tree root changed in the round trip: %v", h.Index, err)
+		}
+		if err := new(BlockHeader).UnmarshalSSZ(enc[:100]); err == nil {
+			t.Fatalf("header %d: truncated encoding decoded", h.Index)
+		}
+	}
+}
+
+func TestBlockSSZRoundTrip(t *testing.T) {
+	b := sszTestBlock(t)
+	enc, err := b.MarshalSSZ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Block
+	if err := got.UnmarshalSSZ(enc); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Verify(); err != nil {
+		t.Fatalf("decoded block does not verify: %v", err)
+	}
+	if got.Hash != b.Hash {
+		t.Fatalf("hash %s after round trip, want %s", got.Hash, b.Hash)
+	}
+	if !reflect.DeepEqual(got.Transactions, b.Transactions) || !reflect.DeepEqual(got.Receipts, b.Receipts) {
+		t.Fatal("transactions or receipts differ after round trip")
+	}
+	again, err := got.MarshalSSZ()
+	if err != nil || !bytes.Equal(again, enc) {
+		t.Fatalf("re-encoding differs: %v", err)
+	}
+	want, err := b.HashTreeRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, err := got.HashTreeRoot(); err != nil || root != want {
+		t.Fatalf("hash tree root changed in the round trip: %v", err)
+	}
+
+	// Any change to a transaction changes the root
+	tampered := *b
+	tampered.Transactions = append([]string{}, b.Transactions...)
+	tampered.Transactions[1] = b.Transactions[2]
+	if root, _ := tampered.HashTreeRoot(); root == want {
+		t.Fatal("hash tree root ignores the transactions")
+	}
+	bad := append([]byte{}, enc...)
+	bad[0]++ // The header's offset no longer follows the fixed part
+	if err := new(Block).UnmarshalSSZ(bad); !errors.Is(err, ssz.ErrBadOffset) {
+		t.Fatalf("block with a bad offset: %v", err)
+	}
+}
+
+func TestTransactionSSZRoundTrip(t *testing.T) {
+	keys, addrs := newTestKeys(t, 2)
+	tx := NewTransaction(addrs[0], addrs[1], 5, 2, 3, []byte("payload"))
+	if err := tx.Sign(keys); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := tx.MarshalSSZ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two 20-byte addresses, three uint64s and three offsets
+	if fixed := 2*crypto.AddressLength + 3*8 + 3*ssz.BytesPerLengthOffset; len(enc) != fixed+len(tx.Payload)+len(tx.PublicKey)+len(tx.Signature) {
+		t.Fatalf("encoded %d bytes", len(enc))
+	}
+	var got Transaction
+	if err := got.UnmarshalSSZ(enc); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, tx) {
+		t.Fatalf("transaction differs after round trip: %+v", got)
+	}
+	if err := got.VerifySignature(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAttestationSSZRoundTrip(t *testing.T) {
+	keys, addrs := newTestKeys(t, 1)
+	a, err := NewAttestation(addrs[0], 4, sszTestBlock(t).Hash, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := a.MarshalSSZ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Attestation
+	if err := got.UnmarshalSSZ(enc); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, a) {
+		t.Fatalf("attestation differs after round trip: %+v", got)
+	}
+}
@@ -4,51 +4,13 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
 
 	"github.com/bilal2134/Blockchain_A3/internal/amf"
 )
 
-// state.go: State compression, pruning, and archival logic
-// Implements advanced state management for compact and efficient blockchain state.
-
-// PruneState prunes old or excess state entries while maintaining integrity via a Merkle checkpoint.
-func PruneState(state map[string]interface{}) map[string]interface{} {
-	const maxEntries = 100
-	if len(state) <= maxEntries {
-		return state
-	}
-	// Keep first maxEntries after sorting keys
-	keys := make([]string, 0, len(state))
-	for k := range state {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-	pruned := make(map[string]interface{}, maxEntries)
-	for i, k := range keys {
-		if i >= maxEntries {
-			break
-		}
-		pruned[k] = state[k]
-	}
-	return pruned
-}
-
-// ArchiveState archives state data to disk as JSON in the 'state_archives' directory.
-func ArchiveState(state map[string]interface{}) error {
-	dir := "state_archives"
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err
-	}
-	data, err := json.MarshalIndent(state, "", "  ")
-	if err != nil {
-		return err
-	}
-	filename := filepath.Join(dir, fmt.Sprintf("state_%d.json", sha256.Sum256(data)[0]))
-	return os.WriteFile(filename, data, 0o644)
-}
+// state.go: State compression logic
+// Provable pruning of state history lives in prune.go.
 
 // CompactStateRepresentation computes a Merkle root hash over state entries for a compact proof.
 func CompactStateRepresentation(state map[string]interface{}) []byte {
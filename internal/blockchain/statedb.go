@@ -0,0 +1,276 @@
+package blockchain
+
+// statedb.go: Account state held in the Adaptive Merkle Forest
+// Applies signed transactions to per-address balances and nonces.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+var (
+	// ErrNonceMismatch is returned when a transaction nonce is not the sender's next nonce.
+	ErrNonceMismatch = errors.New("nonce mismatch")
+	// ErrInsufficientFunds is returned when the sender cannot cover amount plus fee.
+	ErrInsufficientFunds = errors.New("insufficient funds for amount + fee")
+)
+
+// accountPrefix namespaces account entries among the forest keys.
+const accountPrefix = "acct:"
+
+// Account is the state kept for each address.
+type Account struct {
+	Balance uint64
+	Nonce   uint64
+}
+
+// AccountKey returns the forest key of an address's account entry.
+func AccountKey(address string) string {
+	return accountPrefix + address
+}
+
+// StateDB executes transactions against account state held in a forest.
+type StateDB struct {
+	Forest  *amf.Forest
+	Config  amf.RebalanceConfig
+	Journal *amf.Journal // Undo log grouped by block index, used to roll back
+	// Parallel runs the transactions of executed blocks if set; nil runs them serially.
+	Parallel *ParallelExecutor
+	// applied are the blocks executed on top of the initial state, oldest first.
+	applied []*Block
+}
+
+// NewStateDB wraps a forest with the given shard rebalancing thresholds and
+// attaches a journal to it.
+func NewStateDB(forest *amf.Forest, cfg amf.RebalanceConfig) *StateDB {
+	return &StateDB{Forest: forest, Config: cfg, Journal: amf.NewJournal(forest, cfg)}
+}
+
+// GetAccount returns the account for address; unknown addresses are empty accounts.
+func (s *StateDB) GetAccount(address string) Account {
+	v, ok := s.Forest.Get(AccountKey(address))
+	if !ok {
+		return Account{}
+	}
+	acct, _ := v.(Account)
+	return acct
+}
+
+// SetAccount stores the account for address.
+func (s *StateDB) SetAccount(address string, acct Account) error {
+	return s.Forest.Put(AccountKey(address), acct, s.Config)
+}
+
+// Root returns the state root over all accounts.
+func (s *StateDB) Root() []byte {
+	return s.Forest.StateRoot()
+}
+
+// Copy returns an independent copy of the state for speculative execution.
+// The copy starts with an empty journal.
+func (s *StateDB) Copy() *StateDB {
+	return NewStateDB(s.Forest.Copy(), s.Config)
+}
+
+// ApplyTransaction verifies tx against the current state and executes it.
+// An error means the transaction is invalid and the state is unchanged. A sender
+// who can pay the fee but not the amount gets a failed receipt: the fee is
+// charged and the nonce consumed, but no value moves. A successful transfer
+// emits a transfer log, and a memo log if it carries a payload.
+func (s *StateDB) ApplyTransaction(tx *Transaction) (*Receipt, error) {
+	if err := tx.VerifySignature(); err != nil {
+		return nil, err
+	}
+	writes, receipt, err := transition(tx, s.GetAccount)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storeWrites(writes); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// accountWrite is an account stored by a transaction.
+type accountWrite struct {
+	address string
+	account Account
+}
+
+// transition computes the effect of the signature-checked tx on the accounts
+// read through get, without changing any state: the accounts to store, in
+// order, and the receipt. Each address is read through get at most once.
+func transition(tx *Transaction, get func(address string) Account) ([]accountWrite, *Receipt, error) {
+	from := get(tx.From)
+	if tx.Nonce != from.Nonce {
+		return nil, nil, fmt.Errorf("%w: have %d, want %d", ErrNonceMismatch, tx.Nonce, from.Nonce)
+	}
+	if from.Balance < tx.Fee {
+		return nil, nil, fmt.Errorf("%w: balance %d, fee %d", ErrInsufficientFunds, from.Balance, tx.Fee)
+	}
+	receipt := &Receipt{
+		TxHash:  tx.Hash(),
+		Status:  ReceiptSuccess,
+		GasUsed: IntrinsicGas(tx),
+		Fee:     tx.Fee,
+	}
+	from.Balance -= tx.Fee
+	from.Nonce++
+	if tx.Amount > from.Balance {
+		receipt.Status = ReceiptFailed
+		writes := []accountWrite{{tx.From, from}}
+		receipt.ShardRoot = shardRoot(writes)
+		return writes, receipt, nil
+	}
+	from.Balance -= tx.Amount
+	to := from
+	if tx.To != tx.From {
+		to = get(tx.To)
+	}
+	to.Balance += tx.Amount
+	writes := []accountWrite{{tx.From, from}, {tx.To, to}}
+	receipt.ShardRoot = shardRoot(writes)
+	receipt.Logs = transferLogs(tx)
+	return writes, receipt, nil
+}
+
+// storeWrites stores the accounts written by a transaction, in order.
+func (s *StateDB) storeWrites(writes []accountWrite) error {
+	for _, w := range writes {
+		if err := s.SetAccount(w.address, w.account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shardRoot returns the Merkle root over the entries of the accounts a
+// transaction wrote, with their final values. Leaves are those of the state
+// root, so each entry can also be checked against an account proof; the root
+// does not depend on how the forest happens to be sharded.
+func shardRoot(writes []accountWrite) []byte {
+	data := make(map[string]interface{}, len(writes))
+	for _, w := range writes {
+		data[AccountKey(w.address)] = w.account
+	}
+	return amf.BuildMerkleRoot(&amf.Shard{Data: data}).Hash
+}
+
+// ExecuteBlock applies every transaction in block and checks the resulting gas
+// and state root against the block. Changes are journaled under the block index;
+// if any check fails they are reverted and the state is left unchanged.
+func (s *StateDB) ExecuteBlock(block *Block) ([]*Receipt, error) {
+	txs, err := block.DecodeTransactions()
+	if err != nil {
+		return nil, err
+	}
+	cp := s.Journal.Checkpoint()
+	receipts, err := s.executeBlock(block, txs)
+	if err != nil {
+		if rerr := s.Journal.RevertToCheckpoint(cp); rerr != nil {
+			return nil, fmt.Errorf("%v (revert failed: %w)", err, rerr)
+		}
+		return nil, err
+	}
+	return receipts, s.Journal.DiscardCheckpoint(cp)
+}
+
+// executeBlock runs the transactions of block and checks the outcome.
+func (s *StateDB) executeBlock(block *Block, txs []*Transaction) ([]*Receipt, error) {
+	s.Journal.BeginBlock(block.Index)
+	receipts, err := s.applyTransactions(txs)
+	if err != nil {
+		return nil, err
+	}
+	var gasUsed uint64
+	for _, receipt := range receipts {
+		gasUsed += receipt.GasUsed
+		receipt.CumulativeGasUsed = gasUsed
+	}
+	if gasUsed != block.GasUsed {
+		return nil, fmt.Errorf("gas used mismatch: block %d, executed %d", block.GasUsed, gasUsed)
+	}
+	if root := s.Root(); !bytes.Equal(root, block.StateRoot) {
+		return nil, fmt.Errorf("state root mismatch: block %x, executed %x", block.StateRoot, root)
+	}
+	if root := ReceiptsRoot(receipts); !bytes.Equal(root, block.ReceiptsRoot) {
+		return nil, fmt.Errorf("receipts root mismatch: block %x, executed %x", block.ReceiptsRoot, root)
+	}
+	if bloom := LogsBloom(receipts); !bytes.Equal(bloom, block.LogsBloom) {
+		return nil, fmt.Errorf("%w: does not match executed logs", ErrBadLogsBloom)
+	}
+	return receipts, nil
+}
+
+// applyTransactions applies txs in order, on the parallel executor if one is set.
+func (s *StateDB) applyTransactions(txs []*Transaction) ([]*Receipt, error) {
+	if s.Parallel != nil {
+		receipts, _, err := s.Parallel.Execute(s, txs)
+		return receipts, err
+	}
+	receipts := make([]*Receipt, 0, len(txs))
+	for i, tx := range txs {
+		receipt, err := s.ApplyTransaction(tx)
+		if err != nil {
+			return nil, txError(i, tx, err)
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+// OnChainEvent is a ChainListener that keeps the state in step with the
+// canonical chain. Disconnected blocks are undone through the journal and
+// connected blocks executed. If a connected block fails, the blocks connected so
+// far are reverted and the disconnected ones re-executed, so the state is left
+// as it was before the event.
+func (s *StateDB) OnChainEvent(ev ChainEvent) error {
+	if len(ev.Disconnected) > len(s.applied) {
+		return fmt.Errorf("cannot rewind %d blocks, only %d applied", len(ev.Disconnected), len(s.applied))
+	}
+	fork := len(s.applied) - len(ev.Disconnected)
+	if err := s.rewind(fork); err != nil {
+		return err
+	}
+	for _, b := range ev.Connected {
+		if _, err := s.ExecuteBlock(b); err != nil {
+			if rerr := s.restore(fork, ev.Disconnected); rerr != nil {
+				return fmt.Errorf("%w; state could not be restored: %w", blockError(b, err), rerr)
+			}
+			return blockError(b, err)
+		}
+		s.applied = append(s.applied, b)
+	}
+	return nil
+}
+
+// restore reverts the state to just after the first n applied blocks and
+// re-executes the disconnected blocks on top of them.
+func (s *StateDB) restore(n int, disconnected []*Block) error {
+	if err := s.rewind(n); err != nil {
+		return err
+	}
+	for _, d := range reverse(disconnected) {
+		if _, err := s.ExecuteBlock(d); err != nil {
+			return blockError(d, err)
+		}
+		s.applied = append(s.applied, d)
+	}
+	return nil
+}
+
+// rewind reverts the state to just after the first n applied blocks.
+func (s *StateDB) rewind(n int) error {
+	if n == len(s.applied) {
+		return nil
+	}
+	height := s.applied[n].Index - 1
+	if err := s.Journal.Revert(height); err != nil {
+		return err
+	}
+	s.applied = s.applied[:n]
+	return nil
+}
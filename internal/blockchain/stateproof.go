@@ -0,0 +1,142 @@
+package blockchain
+
+// stateproof.go: Account proofs against a state root
+// The state root is a Merkle tree over every entry sorted by key. An account
+// is proven by its leaf; a missing account by the leaves on either side of
+// where its key would sort, which must be adjacent in the tree.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+// ErrBadAccountProof is returned when an account proof does not match a state root.
+var ErrBadAccountProof = errors.New("account proof does not match state root")
+
+// LeafProof proves one state entry and its position in the state tree.
+type LeafProof struct {
+	Key   string
+	Value []byte // JSON encoding of the stored value
+	Index int
+	Proof amf.MerkleProof
+}
+
+// leaf returns the leaf data the state root hashes for the entry.
+func (l *LeafProof) leaf() []byte {
+	return append([]byte(l.Key+":"), l.Value...)
+}
+
+// AccountProof proves an account's value, or its absence, against a state root.
+type AccountProof struct {
+	Address string
+	Account Account // Empty if the account does not exist
+	Exists  bool
+	Leaves  int         // Number of entries in the state
+	Proofs  []LeafProof // The account's leaf, or the neighbours of its missing key
+}
+
+// ProveAccount returns the proof of address's account against s.Root().
+func (s *StateDB) ProveAccount(address string) (*AccountProof, error) {
+	state := s.Forest.ReconstructState()
+	keys := make([]string, 0, len(state))
+	for k := range state {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	p := &AccountProof{Address: address, Leaves: len(keys)}
+	if len(keys) == 0 {
+		return p, nil
+	}
+	leaves := make([][]byte, len(keys))
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		v, err := json.Marshal(state[k])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+		leaves[i] = append([]byte(k+":"), v...)
+	}
+	tree, err := amf.NewMerkleTree(leaves)
+	if err != nil {
+		return nil, err
+	}
+	prove := func(i int) error {
+		proof, err := tree.Prove(i)
+		if err != nil {
+			return err
+		}
+		p.Proofs = append(p.Proofs, LeafProof{Key: keys[i], Value: values[i], Index: i, Proof: proof})
+		return nil
+	}
+	key := AccountKey(address)
+	i := sort.SearchStrings(keys, key)
+	if i < len(keys) && keys[i] == key {
+		p.Exists = true
+		p.Account, _ = state[key].(Account)
+		return p, prove(i)
+	}
+	if i > 0 {
+		if err := prove(i - 1); err != nil {
+			return nil, err
+		}
+	}
+	if i < len(keys) {
+		if err := prove(i); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Verify checks the proof against root.
+func (p *AccountProof) Verify(root []byte) error {
+	for i := range p.Proofs {
+		lp := &p.Proofs[i]
+		if !amf.VerifyProofAt(root, lp.leaf(), lp.Index, p.Leaves, lp.Proof) {
+			return fmt.Errorf("%w: entry %q", ErrBadAccountProof, lp.Key)
+		}
+	}
+	key := AccountKey(p.Address)
+	if p.Exists {
+		if len(p.Proofs) != 1 || p.Proofs[0].Key != key {
+			return fmt.Errorf("%w: missing account entry", ErrBadAccountProof)
+		}
+		var acct Account
+		if err := json.Unmarshal(p.Proofs[0].Value, &acct); err != nil || acct != p.Account {
+			return fmt.Errorf("%w: account value", ErrBadAccountProof)
+		}
+		return nil
+	}
+	if p.Account != (Account{}) {
+		return fmt.Errorf("%w: missing account with a value", ErrBadAccountProof)
+	}
+	// Absence: the neighbours must be adjacent and bracket the key, with the
+	// first or last leaf standing alone at the ends of the tree.
+	switch len(p.Proofs) {
+	case 0:
+		if p.Leaves != 0 || !bytes.Equal(root, amf.Hash(nil)) {
+			return fmt.Errorf("%w: state is not empty", ErrBadAccountProof)
+		}
+	case 1:
+		lp := p.Proofs[0]
+		first := lp.Index == 0 && key < lp.Key
+		last := lp.Index == p.Leaves-1 && lp.Key < key
+		if !first && !last {
+			return fmt.Errorf("%w: neighbour does not bracket key", ErrBadAccountProof)
+		}
+	case 2:
+		lo, hi := p.Proofs[0], p.Proofs[1]
+		if hi.Index != lo.Index+1 || !(lo.Key < key && key < hi.Key) {
+			return fmt.Errorf("%w: neighbours do not bracket key", ErrBadAccountProof)
+		}
+	default:
+		return fmt.Errorf("%w: too many entries", ErrBadAccountProof)
+	}
+	return nil
+}
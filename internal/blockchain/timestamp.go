@@ -0,0 +1,168 @@
+package blockchain
+
+// timestamp.go: Block timestamp rules and an injectable clock
+// A block's timestamp must be later than the median timestamp of the blocks
+// before it (median-time-past), which a single proposer cannot drag backwards,
+// and no further ahead of the validating node's clock than the allowed drift.
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the current time. Validators check timestamps against it and
+// builders stamp blocks with it; tests substitute a ManualClock.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the local wall clock.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ManualClock is a Clock that only moves when told to.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock returns a clock stopped at now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to now.
+func (c *ManualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TimestampRules are the consensus parameters for block timestamps.
+type TimestampRules struct {
+	MedianBlocks int           // Number of preceding blocks whose median timestamp a block must exceed; 0 disables the rule
+	MaxDrift     time.Duration // How far ahead of the validator's clock a timestamp may be
+}
+
+// DefaultTimestampRules takes the median over 11 blocks and allows two minutes of drift.
+var DefaultTimestampRules = TimestampRules{MedianBlocks: 11, MaxDrift: 2 * time.Minute}
+
+// MedianTime returns the median of times, the later middle one for an even
+// count, or the zero time if there are none.
+func MedianTime(times []time.Time) time.Time {
+	if len(times) == 0 {
+		return time.Time{}
+	}
+	sorted := append([]time.Time(nil), times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	return sorted[len(sorted)/2]
+}
+
+// Check applies the rules to a block timestamp given the median time past of
+// its parent (zero for the first block) and the validator's current time.
+func (r TimestampRules) Check(timestamp, medianTimePast, now time.Time) error {
+	if !medianTimePast.IsZero() && !timestamp.After(medianTimePast) {
+		return fmt.Errorf("%w: %s is not after median time past %s", ErrTimestamp, timestamp, medianTimePast)
+	}
+	if limit := now.Add(r.MaxDrift); timestamp.After(limit) {
+		return fmt.Errorf("%w: %s is more than %s ahead of local time %s", ErrTimestamp, timestamp, r.MaxDrift, now)
+	}
+	return nil
+}
+
+// SetClock sets the clock the chain checks timestamps against.
+func (bc *Blockchain) SetClock(c Clock) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.clock = c
+}
+
+// Clock returns the clock the chain checks timestamps against.
+func (bc *Blockchain) Clock() Clock {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	return bc.clockOrSystem()
+}
+
+// SetTimestampRules sets the timestamp rules blocks are added under.
+func (bc *Blockchain) SetTimestampRules(r TimestampRules) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.timeRules = r
+}
+
+// clockOrSystem returns the chain's clock, the system clock if none is set;
+// bc.mu must be held.
+func (bc *Blockchain) clockOrSystem() Clock {
+	if bc.clock == nil {
+		return SystemClock{}
+	}
+	return bc.clock
+}
+
+// MedianTimePast returns the median timestamp of the known block hash and the
+// ancestors before it, up to the rules' MedianBlocks blocks in all.
+func (bc *Blockchain) MedianTimePast(hash string) (time.Time, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	node, ok := bc.nodes[hash]
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: unknown block %s", ErrBadParent, hash)
+	}
+	return bc.medianTimePast(node), nil
+}
+
+// medianTimePast returns the median timestamp of node and its ancestors; bc.mu
+// must be held.
+func (bc *Blockchain) medianTimePast(node *BlockNode) time.Time {
+	var times []time.Time
+	for ; node != nil && len(times) < bc.timeRules.MedianBlocks; node = node.Parent {
+		times = append(times, node.Block.Timestamp)
+	}
+	return MedianTime(times)
+}
+
+// CheckTimestamp checks block's timestamp against the median time past of its
+// parent in the tree and the chain's clock.
+func (bc *Blockchain) CheckTimestamp(block *Block) error {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+	parent, ok := bc.nodes[block.PrevHash]
+	if !ok && block.Index != 0 {
+		return blockError(block, fmt.Errorf("%w: unknown parent %s", ErrBadParent, block.PrevHash))
+	}
+	return bc.checkTimestamp(parent, block)
+}
+
+// checkTimestamp applies the timestamp rules to block on top of parent (nil for
+// the first block); bc.mu must be held.
+func (bc *Blockchain) checkTimestamp(parent *BlockNode, block *Block) error {
+	var mtp time.Time
+	if parent != nil {
+		mtp = bc.medianTimePast(parent)
+	}
+	if err := bc.timeRules.Check(block.Timestamp, mtp, bc.clockOrSystem().Now()); err != nil {
+		return blockError(block, err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+// start is the timestamp of the first test block.
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// childAt returns an empty block on top of parent stamped at ts.
+func childAt(parent *Block, ts time.Time) *Block {
+	var b *Block
+	if parent == nil {
+		b = NewBlock(0, "", nil)
+	} else {
+		b = NewBlock(parent.Index+1, parent.Hash, nil)
+		b.MMRRoot = parent.NextMMRRoot()
+	}
+	b.Timestamp = ts
+	b.Seal()
+	return b
+}
+
+// clockedChain returns a chain checked against a manual clock with blocks
+// stamped the given seconds after start, and the clock stopped at the last.
+func clockedChain(t *testing.T, rules TimestampRules, seconds ...int) (*Blockchain, *ManualClock) {
+	t.Helper()
+	clock := NewManualClock(start)
+	bc := NewBlockchain(LongestChain{})
+	bc.SetClock(clock)
+	bc.SetTimestampRules(rules)
+	var parent *Block
+	for i, s := range seconds {
+		clock.Set(start.Add(time.Duration(s) * time.Second))
+		b := childAt(parent, clock.Now())
+		if err := bc.AddBlock(b); err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+		parent = b
+	}
+	return bc, clock
+}
+
+func TestTimestampRulesCheck(t *testing.T) {
+	rules := TimestampRules{MedianBlocks: 3, MaxDrift: time.Minute}
+	mtp, now := start, start.Add(time.Hour)
+	for _, tt := range []struct {
+		ts time.Time
+		ok bool
+	}{
+		{mtp.Add(-time.Nanosecond), false},
+		{mtp, false},
+		{mtp.Add(time.Nanosecond), true},
+		{now.Add(time.Minute), true},
+		{now.Add(time.Minute + time.Nanosecond), false},
+	} {
+		if err := rules.Check(tt.ts, mtp, now); (err == nil) != tt.ok {
+			t.Errorf("Check(%s): %v", tt.ts, err)
+		}
+	}
+	if got := MedianTime([]time.Time{start.Add(3), start.Add(1), start.Add(2), start}); !got.Equal(start.Add(2)) {
+		t.Errorf("median of four %s, want the later middle one", got)
+	}
+}
+
+func TestChainMedianTimePast(t *testing.T) {
+	bc, clock := clockedChain(t, TimestampRules{MedianBlocks: 3, MaxDrift: time.Minute}, 0, 1, 2, 3, 4)
+	head := bc.Head()
+	mtp, err := bc.MedianTimePast(head.Hash)
+	if err != nil || !mtp.Equal(start.Add(3*time.Second)) {
+		t.Fatalf("median time past %s, %v; want %s", mtp, err, start.Add(3*time.Second))
+	}
+	// The median time past only reaches the parent's timestamp once two of
+	// the last three blocks share it
+	bc, clock = clockedChain(t, TimestampRules{MedianBlocks: 3, MaxDrift: time.Minute}, 0, 1, 2, 3, 3)
+	head = bc.Head()
+	if mtp, _ = bc.MedianTimePast(head.Hash); !mtp.Equal(head.Timestamp) {
+		t.Fatalf("median time past %s, want the parent's %s", mtp, head.Timestamp)
+	}
+	clock.Advance(time.Hour)
+	if err := bc.AddBlock(childAt(head, mtp)); !errors.Is(err, ErrTimestamp) {
+		t.Fatalf("block at the median time past: %v", err)
+	}
+	if err := bc.AddBlock(childAt(head, mtp.Add(time.Nanosecond))); err != nil {
+		t.Fatalf("block just past the median time past: %v", err)
+	}
+}
+
+func TestChainClockDrift(t *testing.T) {
+	bc, clock := clockedChain(t, TimestampRules{MedianBlocks: 3, MaxDrift: time.Minute}, 0, 1, 2)
+	head := bc.Head()
+	ahead := childAt(head, clock.Now().Add(time.Minute+time.Nanosecond))
+	if err := bc.AddBlock(ahead); !errors.Is(err, ErrTimestamp) {
+		t.Fatalf("block beyond the drift: %v", err)
+	}
+	// The same block is accepted once the clock catches up; a block rejected
+	// for its timestamp is not marked invalid
+	clock.Advance(time.Nanosecond)
+	if err := bc.AddBlock(ahead); err != nil {
+		t.Fatalf("block at the drift limit: %v", err)
+	}
+}
+
+func TestZeroMedianBlocksDisablesRule(t *testing.T) {
+	for _, rules := range []TimestampRules{{MaxDrift: time.Minute}, {}} {
+		// Every block but the first is at the median time past of its parent
+		bc, _ := clockedChain(t, rules, 0, 3, 3, 3, 3)
+		head := bc.Head()
+		if err := bc.AddBlock(childAt(head, head.Timestamp)); err != nil {
+			t.Fatalf("rules %+v: block at its parent's timestamp: %v", rules, err)
+		}
+	}
+}
+
+// slicePool serves a fixed list of pending transactions.
+type slicePool []*Transaction
+
+// Pending returns the transactions, at most limit of them if limit > 0.
+func (p slicePool) Pending(limit int) []*Transaction {
+	if limit > 0 && limit < len(p) {
+		return p[:limit]
+	}
+	return p
+}
+
+func TestBuilderStampsPastMedianTime(t *testing.T) {
+	bc, clock := clockedChain(t, TimestampRules{MedianBlocks: 3, MaxDrift: time.Minute}, 0, 1, 2, 3, 3)
+	head := bc.Head()
+	keys, addrs := newTestKeys(t, 1)
+	state := NewStateDB(amf.NewForest(), amf.DefaultRebalanceConfig)
+	if err := state.SetAccount(addrs[0], Account{Balance: 100}); err != nil {
+		t.Fatal(err)
+	}
+	tx := NewTransaction(addrs[0], "0000000000000000000000000000000000000001", 1, 1, 0, nil)
+	if err := tx.Sign(keys); err != nil {
+		t.Fatal(err)
+	}
+	bld := NewBuilder(DefaultBuilderConfig, slicePool{tx})
+	bld.SetChain(bc)
+
+	// The local clock has fallen behind the chain
+	clock.Set(start)
+	block, err := bld.Build(head, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtp, _ := bc.MedianTimePast(head.Hash)
+	if want := mtp.Add(time.Nanosecond); !block.Timestamp.Equal(want) {
+		t.Fatalf("built block stamped %s, want %s", block.Timestamp, want)
+	}
+	if err := bc.AddBlock(block); err != nil {
+		t.Fatalf("chain rejects its own builder's block: %v", err)
+	}
+
+	// With the clock ahead, blocks carry the clock's time
+	clock.Set(start.Add(time.Hour))
+	block, err = bld.Build(bc.Head(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !block.Timestamp.Equal(clock.Now()) {
+		t.Fatalf("built block stamped %s, want the clock's %s", block.Timestamp, clock.Now())
+	}
+}
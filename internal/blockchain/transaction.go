@@ -0,0 +1,153 @@
+package blockchain
+
+// transaction.go: Signed value-transfer transactions
+// Transactions are carried in blocks as their canonical hex encoding, so the
+// transaction hash is also its Merkle leaf hash.
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+)
+
+var (
+	// ErrInvalidSignature is returned when a transaction signature does not verify.
+	ErrInvalidSignature = errors.New("invalid transaction signature")
+	// ErrSenderMismatch is returned when From is not derived from the signing public key.
+	ErrSenderMismatch = errors.New("sender does not match public key")
+)
+
+// Transaction transfers Amount from From to To, paying Fee to have it included.
+type Transaction struct {
+	From      string // Sender address, derived from PublicKey
+	To        string // Recipient address
+	Amount    uint64
+	Fee       uint64
+	Nonce     uint64 // Sender's transaction sequence number
+	Payload   []byte
+	PublicKey []byte // Sender's ed25519 public key
+	Signature []byte
+}
+
+// Signer signs on behalf of an account, such as a wallet.
+type Signer interface {
+	PublicKey(address string) ([]byte, error)
+	Sign(address string, msg []byte) ([]byte, error)
+}
+
+// NewTransaction creates an unsigned transaction.
+func NewTransaction(from, to string, amount, fee, nonce uint64, payload []byte) *Transaction {
+	return &Transaction{
+		From:    from,
+		To:      to,
+		Amount:  amount,
+		Fee:     fee,
+		Nonce:   nonce,
+		Payload: payload,
+	}
+}
+
+// SigningBytes returns the encoding covered by the signature.
+func (tx *Transaction) SigningBytes() []byte {
+	var e encoder
+	e.string(tx.From)
+	e.string(tx.To)
+	e.uint64(tx.Amount)
+	e.uint64(tx.Fee)
+	e.uint64(tx.Nonce)
+	e.bytes(tx.Payload)
+	e.bytes(tx.PublicKey)
+	return e.buf
+}
+
+// Sign fills in the sender's public key and signature using signer.
+func (tx *Transaction) Sign(signer Signer) error {
+	pub, err := signer.PublicKey(tx.From)
+	if err != nil {
+		return err
+	}
+	tx.PublicKey = pub
+	sig, err := signer.Sign(tx.From, tx.SigningBytes())
+	if err != nil {
+		return err
+	}
+	tx.Signature = sig
+	return nil
+}
+
+// VerifySignature checks that From matches the public key and the signature is valid.
+func (tx *Transaction) VerifySignature() error {
+	if len(tx.PublicKey) != ed25519.PublicKeySize {
+		return ErrInvalidSignature
+	}
+	if crypto.PubkeyToAddress(tx.PublicKey) != tx.From {
+		return ErrSenderMismatch
+	}
+	if !ed25519.Verify(tx.PublicKey, tx.SigningBytes(), tx.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Encode returns the canonical hex encoding carried in Block.Transactions.
+func (tx *Transaction) Encode() string {
+	var e encoder
+	e.buf = tx.SigningBytes()
+	e.bytes(tx.Signature)
+	return hex.EncodeToString(e.buf)
+}
+
+// Hash returns the hex digest of the canonical encoding under the chain hash
+// function, which is also the transaction's leaf hash in the block Merkle tree.
+func (tx *Transaction) Hash() string {
+	return hex.EncodeToString(hash([]byte(tx.Encode())))
+}
+
+// Size returns the length of the canonical encoding in bytes.
+func (tx *Transaction) Size() int {
+	return len(tx.Encode())
+}
+
+// Cost returns the total amount debited from the sender.
+func (tx *Transaction) Cost() uint64 {
+	return tx.Amount + tx.Fee
+}
+
+// DecodeTransaction parses a canonical transaction encoding.
+func DecodeTransaction(s string) (*Transaction, error) {
+	raw, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("transaction is not hex encoded: %w", err)
+	}
+	d := decoder{buf: raw}
+	tx := &Transaction{
+		From:      d.string(),
+		To:        d.string(),
+		Amount:    d.uint64(),
+		Fee:       d.uint64(),
+		Nonce:     d.uint64(),
+		Payload:   d.bytes(),
+		PublicKey: d.bytes(),
+		Signature: d.bytes(),
+	}
+	if err := d.finish(); err != nil {
+		return nil, fmt.Errorf("decode transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// DecodeTransactions decodes every transaction carried by a block.
+func (b *Block) DecodeTransactions() ([]*Transaction, error) {
+	txs := make([]*Transaction, 0, len(b.Transactions))
+	for i, s := range b.Transactions {
+		tx, err := DecodeTransaction(s)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
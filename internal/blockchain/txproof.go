@@ -0,0 +1,64 @@
+package blockchain
+
+// txproof.go: Transaction inclusion proofs
+// A transaction is proven by its leaf in the tree whose root is the header's
+// TxRoot, which is also the block's MultiMerkle[0].
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+// ErrBadTxProof is returned when a transaction proof does not match a transaction root.
+var ErrBadTxProof = errors.New("transaction proof does not match transaction root")
+
+// TxProof proves that a transaction is included in a block.
+type TxProof struct {
+	BlockHash string
+	Height    int    // Index of the block
+	Index     int    // Position of the transaction in the block
+	Count     int    // Number of transactions in the block
+	Tx        string // Encoded transaction, the Merkle leaf
+	Proof     amf.MerkleProof
+}
+
+// ProveTransaction returns the inclusion proof of the transaction at index.
+func (b *Block) ProveTransaction(index int) (*TxProof, error) {
+	leaves := make([][]byte, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		leaves[i] = []byte(tx)
+	}
+	tree, err := amf.NewMerkleTree(leaves)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := tree.Prove(index)
+	if err != nil {
+		return nil, err
+	}
+	return &TxProof{BlockHash: b.Hash, Height: b.Index, Index: index, Count: len(b.Transactions), Tx: b.Transactions[index], Proof: proof}, nil
+}
+
+// FindTransaction returns the index of the transaction with hash txHash in b.
+func (b *Block) FindTransaction(txHash string) (int, bool) {
+	for i, s := range b.Transactions {
+		if tx, err := DecodeTransaction(s); err == nil && tx.Hash() == txHash {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Verify checks the proof against txRoot and returns the decoded transaction.
+func (p *TxProof) Verify(txRoot []byte) (*Transaction, error) {
+	if !amf.VerifyProofAt(txRoot, []byte(p.Tx), p.Index, p.Count, p.Proof) {
+		return nil, ErrBadTxProof
+	}
+	tx, err := DecodeTransaction(p.Tx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadTxProof, err)
+	}
+	return tx, nil
+}
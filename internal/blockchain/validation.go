@@ -0,0 +1,156 @@
+package blockchain
+
+// validation.go: Block and chain linkage validation with typed errors
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+)
+
+var (
+	// ErrBadParent is returned when PrevHash does not match the parent block's hash.
+	ErrBadParent = errors.New("bad parent hash")
+	// ErrBadIndex is returned when a block index does not follow its parent's.
+	ErrBadIndex = errors.New("bad block index")
+	// ErrBadMerkleRoot is returned when the transaction Merkle roots do not match the transactions.
+	ErrBadMerkleRoot = errors.New("bad Merkle root")
+	// ErrBadReceiptsRoot is returned when the receipts root does not match the receipts.
+	ErrBadReceiptsRoot = errors.New("bad receipts root")
+	// ErrBadLogsBloom is returned when the logs Bloom filter does not match the receipt logs.
+	ErrBadLogsBloom = errors.New("bad logs bloom")
+	// ErrBadAccumulator is returned when the accumulator does not match the transactions.
+	ErrBadAccumulator = errors.New("bad accumulator")
+	// ErrBadHash is returned when the block hash does not match its contents.
+	ErrBadHash = errors.New("bad block hash")
+	// ErrBadEntropy is returned when the entropy metric does not match the block.
+	ErrBadEntropy = errors.New("bad entropy")
+	// ErrBadMMRRoot is returned when a header's MMR root does not cover its ancestors.
+	ErrBadMMRRoot = errors.New("bad MMR root")
+	// ErrBadConfigHash is returned when a block other than the first commits to a genesis spec.
+	ErrBadConfigHash = errors.New("config hash outside the first block")
+	// ErrTimestamp is returned when a block timestamp is out of the accepted range.
+	ErrTimestamp = errors.New("timestamp out of range")
+)
+
+// BlockError ties a validation failure to the block it occurred in.
+type BlockError struct {
+	Index int
+	Hash  string
+	Err   error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("block %d (%s): %v", e.Index, e.Hash, e.Err)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
+
+// blockError wraps err with the identity of block b.
+func blockError(b *Block, err error) error {
+	return &BlockError{Index: b.Index, Hash: b.Hash, Err: err}
+}
+
+// Verify performs the cryptographic and entropy checks on a single block,
+// returning a typed error describing the first mismatch.
+func (b *Block) Verify() error {
+	// Verify accumulator
+	if !bytes.Equal(txAccumulator(b.Transactions), b.Accumulator) {
+		return ErrBadAccumulator
+	}
+	// Recompute level-1 Merkle
+	if !bytes.Equal(TxRoot(b.Transactions), b.TxRoot) {
+		return ErrBadMerkleRoot
+	}
+	if !bytes.Equal(ReceiptsRoot(b.Receipts), b.ReceiptsRoot) {
+		return ErrBadReceiptsRoot
+	}
+	if !bytes.Equal(LogsBloom(b.Receipts), b.LogsBloom) {
+		return ErrBadLogsBloom
+	}
+	// Recompute level-2 Merkle over the header
+	hdrHash := b.HeaderHash()
+	if len(b.MultiMerkle) < 2 || !bytes.Equal(b.MultiMerkle[0], b.TxRoot) || !bytes.Equal(b.MultiMerkle[1], hdrHash) {
+		return ErrBadMerkleRoot
+	}
+	// Verify the entropy score matches the transactions
+	if b.Entropy != ComputeEntropy(b.Transactions).Score {
+		return ErrBadEntropy
+	}
+	// Verify block hash equals the header hash
+	if hex.EncodeToString(hdrHash) != b.Hash {
+		return ErrBadHash
+	}
+	return nil
+}
+
+// VerifyLink checks that child correctly extends parent (nil parent means child
+// must be the first block): contiguous index, matching parent hash and a
+// timestamp no earlier than the parent's. The median-time-past and clock drift
+// rules need the chain and are applied by Blockchain.CheckTimestamp.
+func VerifyLink(parent, child *Block) error {
+	if parent == nil {
+		if child.Index != 0 {
+			return fmt.Errorf("%w: first block has index %d", ErrBadIndex, child.Index)
+		}
+		if child.PrevHash != "" {
+			return fmt.Errorf("%w: first block has parent %s", ErrBadParent, child.PrevHash)
+		}
+		if want := (amf.MMRPeaks{}).Root(); !bytes.Equal(child.MMRRoot, want) {
+			return fmt.Errorf("%w: got %x, want %x", ErrBadMMRRoot, child.MMRRoot, want)
+		}
+	} else {
+		if child.Index != parent.Index+1 {
+			return fmt.Errorf("%w: got %d, want %d", ErrBadIndex, child.Index, parent.Index+1)
+		}
+		if child.PrevHash != parent.Hash {
+			return fmt.Errorf("%w: got %s, want %s", ErrBadParent, child.PrevHash, parent.Hash)
+		}
+		// The ancestry of a parent that is not in a block tree is unknown
+		if parent.ancestry.Size > 0 {
+			if want := parent.ancestry.Root(); !bytes.Equal(child.MMRRoot, want) {
+				return fmt.Errorf("%w: got %x, want %x", ErrBadMMRRoot, child.MMRRoot, want)
+			}
+		}
+		if len(child.ConfigHash) > 0 {
+			return fmt.Errorf("%w: block %d", ErrBadConfigHash, child.Index)
+		}
+		if child.Timestamp.Before(parent.Timestamp) {
+			return fmt.Errorf("%w: %s before parent %s", ErrTimestamp, child.Timestamp, parent.Timestamp)
+		}
+	}
+	return nil
+}
+
+// ValidateChild runs the single-block and linkage checks for child on top of parent.
+func ValidateChild(parent, child *Block) error {
+	if err := child.Verify(); err != nil {
+		return blockError(child, err)
+	}
+	if err := VerifyLink(parent, child); err != nil {
+		return blockError(child, err)
+	}
+	return nil
+}
+
+// Validate walks the whole chain, checking every block and its link to its parent.
+func (bc *Blockchain) Validate() error {
+	// Blocks are not changed once added, but the canonical list is rewritten
+	// by AddBlock and reorgs
+	bc.mu.RLock()
+	blocks := append([]*Block(nil), bc.Blocks...)
+	bc.mu.RUnlock()
+	var parent *Block
+	for _, b := range blocks {
+		if err := ValidateChild(parent, b); err != nil {
+			return err
+		}
+		parent = b
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package blockchain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// invalidBlocks are ways to break a block on top of parent, with the error
+// each must be rejected with.
+var invalidBlocks = []struct {
+	name   string
+	mutate func(b, parent *Block)
+	want   error
+}{
+	{"parent", func(b, parent *Block) { b.PrevHash = strings.Repeat("ab", 32); b.Seal() }, ErrBadParent},
+	{"index", func(b, parent *Block) { b.Index = parent.Index + 2; b.Seal() }, ErrBadIndex},
+	{"merkle root", func(b, parent *Block) { b.TxRoot = []byte("not the root") }, ErrBadMerkleRoot},
+	{"timestamp", func(b, parent *Block) { b.Timestamp = parent.Timestamp.Add(-time.Second); b.Seal() }, ErrTimestamp},
+}
+
+// checkBlockError fails unless err is a *BlockError for b wrapping want.
+func checkBlockError(t *testing.T, name string, err, want error, b *Block) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("%s: %v, want %v", name, err, want)
+		return
+	}
+	var be *BlockError
+	if !errors.As(err, &be) || be.Hash != b.Hash || be.Index != b.Index {
+		t.Errorf("%s: error %v does not carry block %d (%s)", name, err, b.Index, b.Hash)
+	}
+}
+
+func TestAddBlockRejectsInvalidBlocks(t *testing.T) {
+	for _, c := range invalidBlocks {
+		bc, _ := clockedChain(t, DefaultTimestampRules, 0, 1, 2)
+		head := bc.Head()
+		b := childAt(head, start.Add(3*time.Second))
+		c.mutate(b, head)
+		checkBlockError(t, c.name, bc.AddBlock(b), c.want, b)
+		if bc.Head() != head {
+			t.Errorf("%s: invalid block moved the head", c.name)
+		}
+	}
+}
+
+func TestValidateReportsInvalidBlocks(t *testing.T) {
+	for _, c := range invalidBlocks {
+		bc, _ := clockedChain(t, DefaultTimestampRules, 0, 1, 2, 3)
+		if err := bc.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		// Blocks are not changed once added; break one behind the chain's back
+		b := bc.Blocks[2]
+		c.mutate(b, bc.Blocks[1])
+		checkBlockError(t, c.name, bc.Validate(), c.want, b)
+	}
+}
+
+func TestValidateWhileAddingBlocks(t *testing.T) {
+	bc := NewBlockchain(LongestChain{})
+	genesis := childAt(nil, start)
+	if err := bc.AddBlock(genesis); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		parent := genesis
+		for i := 1; i <= 100; i++ {
+			b := childAt(parent, start.Add(time.Duration(i)*time.Second))
+			if err := bc.AddBlock(b); err != nil {
+				done <- err
+				return
+			}
+			parent = b
+		}
+		done <- nil
+	}()
+	for {
+		if err := bc.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := bc.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			return
+		default:
+		}
+	}
+}
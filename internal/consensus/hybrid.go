@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/bilal2134/Blockchain_A3/internal/bft"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/mempool"
 )
 
 // HybridConsensus represents the hybrid consensus protocol.
@@ -19,7 +21,17 @@ type HybridConsensus struct {
 	leader     string
 	validators []string
 	powRandom  *big.Int
-	dbftState  map[string]string
+	dbftState  map[string]string                 // validator -> vote for the current proposal
+	attested   map[string]blockchain.Attestation // validator -> signed "yes" vote for the current proposal
+	signer     blockchain.Signer                 // Signs attestations for the validators whose keys it holds
+	proposal   string                            // Block hash proposed by the current leader
+	vrf        *bft.VRF                          // Persistent VRF key; a fresh one is used per round when nil
+	pool       *mempool.Pool                     // Pending transactions proposals are drawn from
+	builderCfg blockchain.BuilderConfig          // Limits proposed blocks are built under
+	entropy    blockchain.EntropyBounds          // Entropy bounds validators hold proposals to
+	quorumNum  int                               // A proposal is finalized by more than quorumNum/quorumDen of the validators
+	quorumDen  int
+	chain      *blockchain.Blockchain // Supplies the clock and median time past for timestamps, if set
 }
 
 // NewHybridConsensus creates a new instance of HybridConsensus.
@@ -29,14 +41,98 @@ func NewHybridConsensus(validators []string) *HybridConsensus {
 		validators: validators,
 		powRandom:  big.NewInt(0),
 		dbftState:  make(map[string]string),
+		attested:   make(map[string]blockchain.Attestation),
+		entropy:    blockchain.DefaultEntropyBounds,
+		quorumNum:  1,
+		quorumDen:  2,
 	}
 }
 
+// SetVRF binds the consensus instance to a persistent VRF key (e.g., loaded from the keystore).
+func (hc *HybridConsensus) SetVRF(vrf *bft.VRF) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.vrf = vrf
+}
+
+// SetMempool attaches the transaction pool that block proposals are drawn from
+// and the limits proposed blocks are built under.
+func (hc *HybridConsensus) SetMempool(pool *mempool.Pool, cfg blockchain.BuilderConfig) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.pool = pool
+	hc.builderCfg = cfg
+}
+
+// SetSigner sets what signs the attestations of validators voting for a
+// proposal, such as a wallet holding their keys. A validator it cannot sign
+// for cannot vote for a block.
+func (hc *HybridConsensus) SetSigner(signer blockchain.Signer) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.signer = signer
+}
+
+// SetEntropyBounds sets the entropy bounds validators hold proposed blocks to.
+func (hc *HybridConsensus) SetEntropyBounds(bounds blockchain.EntropyBounds) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.entropy = bounds
+}
+
+// SetQuorum sets the fraction of validators, num/den, that a proposal needs
+// more than of the votes to be finalized. The default is a simple majority.
+func (hc *HybridConsensus) SetQuorum(num, den int) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.quorumNum, hc.quorumDen = num, den
+}
+
+// SetChain has proposals stamped with the chain's clock and validators reject
+// proposals that break the chain's timestamp rules.
+func (hc *HybridConsensus) SetChain(bc *blockchain.Blockchain) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.chain = bc
+}
+
+// ProposeFromPool has the round leader build a block on top of parent from the
+// mempool's pending transactions, simulated against state, and propose it. The
+// block header records the leader, the round and the hash of parentCert, the
+// certificate that finalized parent (nil if it has none).
+func (hc *HybridConsensus) ProposeFromPool(parent *blockchain.Block, parentCert *blockchain.Certificate, state *blockchain.StateDB) (*blockchain.Block, error) {
+	hc.mu.Lock()
+	pool, cfg, chain := hc.pool, hc.builderCfg, hc.chain
+	leader, round := hc.leader, hc.round
+	hc.mu.Unlock()
+	if pool == nil {
+		return nil, fmt.Errorf("no mempool attached")
+	}
+	builder := blockchain.NewBuilder(cfg, pool)
+	if chain != nil {
+		builder.SetChain(chain)
+	}
+	block, err := builder.Build(parent, state)
+	if err != nil {
+		return nil, err
+	}
+	block.Proposer = leader
+	block.Round = uint64(round)
+	block.CertHash = parentCert.Hash()
+	block.Seal()
+	hc.ProposeBlock(block.Hash)
+	return block, nil
+}
+
 // StartRound starts a new round of the hybrid consensus protocol.
 func (hc *HybridConsensus) StartRound() {
 	hc.mu.Lock()
 	defer hc.mu.Unlock()
+	hc.startRound()
+}
 
+// startRound advances the round and elects its leader; hc.mu must be held.
+func (hc *HybridConsensus) startRound() {
 	hc.round++
 	// Generate PoW randomness
 	r, err := GenerateRandomness()
@@ -45,12 +141,17 @@ func (hc *HybridConsensus) StartRound() {
 	}
 	hc.powRandom = r
 	// Secure VRF-based leader selection
-	vrf, _ := bft.NewVRF()
+	vrf := hc.vrf
+	if vrf == nil {
+		vrf, _ = bft.NewVRF()
+	}
 	proofOutput, _, _ := vrf.Evaluate(r.Bytes())
 	idx := new(big.Int).SetBytes(proofOutput)
 	hc.leader = hc.validators[int(idx.Mod(idx, big.NewInt(int64(len(hc.validators)))).Int64())]
 	// Note: VRF proof could be published alongside for verification
 	hc.dbftState = make(map[string]string)
+	hc.attested = make(map[string]blockchain.Attestation)
+	hc.proposal = ""
 
 	fmt.Printf("Round %d started with leader %s and PoW randomness %s\n", hc.round, hc.leader, hc.powRandom.String())
 }
@@ -61,7 +162,7 @@ func (hc *HybridConsensus) ProposeBlock(block string) {
 	defer hc.mu.Unlock()
 
 	if hc.leader != "" {
-		hc.dbftState[hc.leader] = block
+		hc.proposal = block
 		fmt.Printf("Leader %s proposed block: %s\n", hc.leader, block)
 	}
 }
@@ -71,33 +172,106 @@ func (hc *HybridConsensus) Vote(validator, vote string) {
 	hc.mu.Lock()
 	defer hc.mu.Unlock()
 
-	if _, exists := hc.dbftState[validator]; exists {
-		hc.dbftState[validator] = vote
-		fmt.Printf("Validator %s voted: %s\n", validator, vote)
+	if hc.proposal == "" || !hc.isValidator(validator) {
+		return
 	}
+	hc.dbftState[validator] = vote
+	fmt.Printf("Validator %s voted: %s\n", validator, vote)
 }
 
-// FinalizeRound finalizes the current round and commits the block if consensus is reached.
-func (hc *HybridConsensus) FinalizeRound() {
+// VoteOnBlock has a validator check the proposed block against its parent, the
+// entropy bounds and, with a chain set, the timestamp rules, and vote "yes"
+// with a signed attestation only if it is valid and matches the current
+// proposal. The validation result carries the block's entropy metrics and is
+// nil only if the block is not the current proposal.
+func (hc *HybridConsensus) VoteOnBlock(validator string, parent, block *blockchain.Block) (*blockchain.ValidationResult, error) {
 	hc.mu.Lock()
-	defer hc.mu.Unlock()
+	proposal, bounds, chain, signer, round := hc.proposal, hc.entropy, hc.chain, hc.signer, hc.round
+	hc.mu.Unlock()
+	if block.Hash != proposal {
+		hc.Vote(validator, "no")
+		return nil, fmt.Errorf("block %s is not the current proposal", block.Hash)
+	}
+	res, err := block.ValidateBlock(bounds)
+	if err == nil {
+		err = blockchain.ValidateChild(parent, block)
+	}
+	if err == nil && chain != nil {
+		err = chain.CheckTimestamp(block)
+	}
+	var a blockchain.Attestation
+	if err == nil {
+		if signer == nil {
+			err = fmt.Errorf("no signer for validator %s", validator)
+		} else if a, err = blockchain.NewAttestation(validator, uint64(round), block.Hash, signer); err != nil {
+			err = fmt.Errorf("validator %s cannot attest: %w", validator, err)
+		}
+	}
+	if err != nil {
+		hc.Vote(validator, "no")
+		return res, err
+	}
+	hc.mu.Lock()
+	if hc.proposal == block.Hash && hc.isValidator(validator) {
+		hc.attested[validator] = a
+	}
+	hc.mu.Unlock()
+	hc.Vote(validator, "yes")
+	return res, nil
+}
 
-	// Simulate consensus check
-	time.Sleep(1 * time.Second)
+// isValidator reports whether id is in the validator set; hc.mu must be held.
+func (hc *HybridConsensus) isValidator(id string) bool {
+	for _, v := range hc.validators {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
 
-	// Check if consensus is reached (simple majority for demonstration)
-	voteCount := make(map[string]int)
-	for _, vote := range hc.dbftState {
-		voteCount[vote]++
+// Certificate returns the certificate for the current proposal built from the
+// signed attestations of the validators that voted "yes", or nil if there is no
+// proposal. It is meaningful after FinalizeRound has reported consensus.
+func (hc *HybridConsensus) Certificate() *blockchain.Certificate {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.proposal == "" {
+		return nil
 	}
+	return blockchain.NewCertificate(uint64(hc.round), hc.proposal, hc.yesAttestations())
+}
 
-	for block, count := range voteCount {
-		if count > len(hc.validators)/2 {
-			fmt.Printf("Consensus reached on block: %s\n", block)
-			return
+// yesAttestations returns the attestations of the validators whose current
+// vote is "yes"; hc.mu must be held.
+func (hc *HybridConsensus) yesAttestations() []blockchain.Attestation {
+	var attestations []blockchain.Attestation
+	for v, vote := range hc.dbftState {
+		if a, ok := hc.attested[v]; ok && vote == "yes" {
+			attestations = append(attestations, a)
 		}
 	}
+	return attestations
+}
+
+// FinalizeRound finalizes the current round and reports whether the quorum of
+// validators voted for the proposal. If not, a new round is started.
+func (hc *HybridConsensus) FinalizeRound() bool {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	// Simulate consensus check
+	time.Sleep(1 * time.Second)
+
+	// Check if consensus is reached: more than the quorum fraction voted yes
+	// with a signed attestation
+	yes := len(hc.yesAttestations())
+	if hc.proposal != "" && yes*hc.quorumDen > len(hc.validators)*hc.quorumNum {
+		fmt.Printf("Consensus reached on block: %s\n", hc.proposal)
+		return true
+	}
 
 	fmt.Println("Consensus not reached, starting new round.")
-	hc.StartRound()
+	hc.startRound()
+	return false
 }
@@ -0,0 +1,27 @@
+package crypto
+
+// address.go: Account address derivation from public keys
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// AddressLength is the number of bytes in an account address.
+const AddressLength = 20
+
+// PubkeyToAddress derives the hex account address for a public key as the
+// first AddressLength bytes of its SHA-256 digest.
+func PubkeyToAddress(pub []byte) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:AddressLength])
+}
+
+// IsHexAddress reports whether s is a well-formed hex account address.
+func IsHexAddress(s string) bool {
+	if len(s) != 2*AddressLength {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
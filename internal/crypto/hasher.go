@@ -0,0 +1,34 @@
+package crypto
+
+// hasher.go: Selectable hash functions for chain data structures
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// HashFunc computes a 32-byte digest of data.
+type HashFunc func(data []byte) []byte
+
+// Hash function names accepted by HashByName.
+const (
+	HashSHA256    = "sha256"
+	HashKeccak256 = "keccak256"
+)
+
+// SHA256 returns the SHA-256 digest of data.
+func SHA256(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// HashByName returns the hash function with the given name.
+func HashByName(name string) (HashFunc, error) {
+	switch name {
+	case HashSHA256, "":
+		return SHA256, nil
+	case HashKeccak256:
+		return Keccak256, nil
+	}
+	return nil, fmt.Errorf("unknown hash function %q", name)
+}
@@ -0,0 +1,128 @@
+package crypto
+
+// keccak.go: Keccak-256 as used by Ethereum
+// The original Keccak submission with 0x01 domain padding, which differs from
+// the standardized SHA3-256 (0x06 padding).
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// keccakRate is the sponge rate in bytes for a 256-bit capacity-512 Keccak.
+const keccakRate = 136
+
+// keccakRoundConstants are the iota step constants of Keccak-f[1600].
+var keccakRoundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// keccakRotations are the rho step offsets indexed by lane x+5y.
+var keccakRotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// keccakF1600 applies the Keccak-f[1600] permutation to the state.
+func keccakF1600(a *[25]uint64) {
+	var c [5]uint64
+	var b [25]uint64
+	for round := 0; round < 24; round++ {
+		// theta
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[x+y] ^= d
+			}
+		}
+		// rho and pi
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], keccakRotations[x+5*y])
+			}
+		}
+		// chi
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[x+y] = b[x+y] ^ (^b[(x+1)%5+y] & b[(x+2)%5+y])
+			}
+		}
+		// iota
+		a[0] ^= keccakRoundConstants[round]
+	}
+}
+
+// keccak256 is a streaming Keccak-256 hash.
+type keccak256 struct {
+	state [25]uint64
+	buf   []byte // Pending input shorter than the rate
+}
+
+// NewKeccak256 returns a hash.Hash computing Keccak-256.
+func NewKeccak256() hash.Hash {
+	return &keccak256{buf: make([]byte, 0, keccakRate)}
+}
+
+func (k *keccak256) Size() int      { return 32 }
+func (k *keccak256) BlockSize() int { return keccakRate }
+
+func (k *keccak256) Reset() {
+	k.state = [25]uint64{}
+	k.buf = k.buf[:0]
+}
+
+func (k *keccak256) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		take := min(keccakRate-len(k.buf), len(p))
+		k.buf = append(k.buf, p[:take]...)
+		p = p[take:]
+		if len(k.buf) == keccakRate {
+			k.absorb(k.buf)
+			k.buf = k.buf[:0]
+		}
+	}
+	return n, nil
+}
+
+// absorb XORs one rate-sized block into the state and permutes it.
+func (k *keccak256) absorb(block []byte) {
+	for i := 0; i < keccakRate/8; i++ {
+		k.state[i] ^= binary.LittleEndian.Uint64(block[i*8:])
+	}
+	keccakF1600(&k.state)
+}
+
+// Sum appends the digest of the data written so far without changing the hash state.
+func (k *keccak256) Sum(in []byte) []byte {
+	dup := *k
+	var block [keccakRate]byte
+	copy(block[:], k.buf)
+	block[len(k.buf)] ^= 0x01
+	block[keccakRate-1] ^= 0x80
+	dup.absorb(block[:])
+	var out [32]byte
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(out[i*8:], dup.state[i])
+	}
+	return append(in, out[:]...)
+}
+
+// Keccak256 returns the Keccak-256 digest of data.
+func Keccak256(data []byte) []byte {
+	h := NewKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestKeccak256Vectors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{"abc", "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"},
+		{"The quick brown fox jumps over the lazy dog", "4d741b6f1eb29cb2a9b9911c82f56fa8d73b04959d3d9d222895df6c0b28aa15"},
+		{"The quick brown fox jumps over the lazy dog.", "578951e24efd62a3d63a86f7cd19aaa53c898fe287d2552133220370240b572d"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(Keccak256([]byte(tt.in))); got != tt.want {
+			t.Errorf("Keccak256(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestKeccak256Streaming hashes inputs around the 136-byte rate in pieces and
+// checks they match the one-shot digest.
+func TestKeccak256Streaming(t *testing.T) {
+	data := make([]byte, 3*keccakRate+7)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	for _, n := range []int{keccakRate - 1, keccakRate, keccakRate + 1, 2 * keccakRate, len(data)} {
+		want := Keccak256(data[:n])
+		for _, piece := range []int{1, 5, 64, keccakRate} {
+			h := NewKeccak256()
+			for i := 0; i < n; i += piece {
+				end := i + piece
+				if end > n {
+					end = n
+				}
+				h.Write(data[i:end])
+			}
+			if got := h.Sum(nil); !bytes.Equal(got, want) {
+				t.Fatalf("%d bytes written %d at a time: %x, want %x", n, piece, got, want)
+			}
+		}
+	}
+	h := NewKeccak256()
+	h.Write([]byte("abc"))
+	h.Sum(nil)
+	h.Reset()
+	if got := hex.EncodeToString(h.Sum(nil)); got != "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470" {
+		t.Fatalf("digest after Reset %s, want the empty input's", got)
+	}
+}
+
+func TestHashByName(t *testing.T) {
+	for name, want := range map[string]string{
+		"":            "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		HashSHA256:    "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		HashKeccak256: "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45",
+	} {
+		h, err := HashByName(name)
+		if err != nil {
+			t.Fatalf("HashByName(%q): %v", name, err)
+		}
+		if got := hex.EncodeToString(h([]byte("abc"))); got != want {
+			t.Errorf("HashByName(%q)(abc) = %s, want %s", name, got, want)
+		}
+	}
+	if _, err := HashByName("md5"); err == nil {
+		t.Fatal("HashByName accepted an unknown function")
+	}
+}
@@ -0,0 +1,44 @@
+package crypto
+
+// pbkdf2.go: Password-based key derivation (RFC 8018, PBKDF2)
+// Stretches passphrases into symmetric keys for the encrypted keystore.
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+)
+
+// PBKDF2 derives a keyLen-byte key from password and salt using HMAC over h
+// as the pseudo-random function and the given iteration count.
+func PBKDF2(password, salt []byte, iterations, keyLen int, h func() hash.Hash) []byte {
+	prf := hmac.New(h, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		// U_1 = PRF(password, salt || INT(block))
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		// U_n = PRF(password, U_{n-1}); T = U_1 ^ U_2 ^ ... ^ U_c
+		for n := 2; n <= iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
@@ -0,0 +1,346 @@
+package indexer
+
+// indexer.go: Transaction and address index over the canonical chain
+// Maps transaction hashes to their position in the chain and addresses to the
+// transactions that sent to or from them. The index follows the chain as a
+// listener, is kept on disk one file per height, and can be caught up or
+// rebuilt from any source of blocks such as the block store.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+var (
+	// ErrNotIndexed is returned for a transaction the index does not hold.
+	ErrNotIndexed = errors.New("transaction not indexed")
+	// ErrBadIndexEvent is returned for a chain event that does not extend the index.
+	ErrBadIndexEvent = errors.New("chain event does not match index")
+)
+
+// blockFileExt is the extension of the per-height index files.
+const blockFileExt = ".json"
+
+// TxLocation is where a transaction sits in the canonical chain.
+type TxLocation struct {
+	TxHash    string
+	BlockHash string
+	Height    int
+	Index     int // Position of the transaction in the block
+}
+
+// indexedTx is the part of a transaction the index keeps.
+type indexedTx struct {
+	Hash string
+	From string
+	To   string
+}
+
+// indexedBlock is the index file of one canonical block.
+type indexedBlock struct {
+	Height   int
+	Hash     string
+	PrevHash string
+	Txs      []indexedTx
+}
+
+// BlockSource looks blocks up by hash, as store.BlockStore does.
+type BlockSource interface {
+	Get(hash string) (*blockchain.Block, error)
+}
+
+// Indexer indexes the transactions of the canonical chain by hash and address.
+type Indexer struct {
+	mu     sync.RWMutex
+	dir    string
+	blocks []*indexedBlock         // Indexed canonical blocks by height
+	txs    map[string]TxLocation   // Transaction hash -> location
+	addrs  map[string][]TxLocation // Address -> transactions, oldest first
+}
+
+// Open opens or creates the index in dir and loads the blocks indexed so far.
+// Loading stops at the first missing or unlinked height; the index is then
+// behind the chain and is brought up to date with Sync.
+func Open(dir string) (*Indexer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	idx := &Indexer{dir: dir}
+	idx.reset()
+	heights, err := idx.heights()
+	if err != nil {
+		return nil, err
+	}
+	for i, h := range heights {
+		if h != i {
+			break
+		}
+		data, err := os.ReadFile(idx.path(h))
+		if err != nil {
+			return nil, err
+		}
+		var b indexedBlock
+		if err := json.Unmarshal(data, &b); err != nil {
+			return nil, fmt.Errorf("index of block %d: %w", h, err)
+		}
+		if b.Height != h || (h > 0 && b.PrevHash != idx.blocks[h-1].Hash) {
+			break
+		}
+		idx.add(&b)
+	}
+	return idx, idx.removeFrom(len(idx.blocks))
+}
+
+// reset empties the in-memory index; idx.mu must be held.
+func (idx *Indexer) reset() {
+	idx.blocks = nil
+	idx.txs = make(map[string]TxLocation)
+	idx.addrs = make(map[string][]TxLocation)
+}
+
+// path returns the file name of the index of the block at height.
+func (idx *Indexer) path(height int) string {
+	return filepath.Join(idx.dir, fmt.Sprintf("%010d%s", height, blockFileExt))
+}
+
+// heights lists the heights that have an index file, in ascending order.
+func (idx *Indexer) heights() ([]int, error) {
+	entries, err := os.ReadDir(idx.dir)
+	if err != nil {
+		return nil, err
+	}
+	var heights []int
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, blockFileExt) {
+			continue
+		}
+		if h, err := strconv.Atoi(strings.TrimSuffix(name, blockFileExt)); err == nil {
+			heights = append(heights, h)
+		}
+	}
+	sort.Ints(heights)
+	return heights, nil
+}
+
+// add indexes b in memory as the next block; idx.mu must be held.
+func (idx *Indexer) add(b *indexedBlock) {
+	idx.blocks = append(idx.blocks, b)
+	for i, tx := range b.Txs {
+		loc := TxLocation{TxHash: tx.Hash, BlockHash: b.Hash, Height: b.Height, Index: i}
+		idx.txs[tx.Hash] = loc
+		idx.addrs[tx.From] = append(idx.addrs[tx.From], loc)
+		if tx.To != tx.From {
+			idx.addrs[tx.To] = append(idx.addrs[tx.To], loc)
+		}
+	}
+}
+
+// truncate drops every block from height on from memory; idx.mu must be held.
+// Address lists are oldest first, so the dropped entries are at their ends.
+func (idx *Indexer) truncate(height int) {
+	for _, b := range idx.blocks[height:] {
+		for _, tx := range b.Txs {
+			delete(idx.txs, tx.Hash)
+			for _, addr := range []string{tx.From, tx.To} {
+				locs := idx.addrs[addr]
+				n := len(locs)
+				for n > 0 && locs[n-1].Height >= height {
+					n--
+				}
+				if n == 0 {
+					delete(idx.addrs, addr)
+				} else {
+					idx.addrs[addr] = locs[:n]
+				}
+			}
+		}
+	}
+	idx.blocks = idx.blocks[:height]
+}
+
+// removeFrom deletes the index files of every height from height on.
+func (idx *Indexer) removeFrom(height int) error {
+	heights, err := idx.heights()
+	if err != nil {
+		return err
+	}
+	for _, h := range heights {
+		if h >= height {
+			if err := os.Remove(idx.path(h)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// write persists the index of b.
+func (idx *Indexer) write(b *indexedBlock) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	tmp := idx.path(b.Height) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, idx.path(b.Height))
+}
+
+// newIndexedBlock extracts the indexed fields of block.
+func newIndexedBlock(block *blockchain.Block) (*indexedBlock, error) {
+	txs, err := block.DecodeTransactions()
+	if err != nil {
+		return nil, fmt.Errorf("block %d: %w", block.Index, err)
+	}
+	b := &indexedBlock{Height: block.Index, Hash: block.Hash, PrevHash: block.PrevHash}
+	for _, tx := range txs {
+		b.Txs = append(b.Txs, indexedTx{Hash: tx.Hash(), From: tx.From, To: tx.To})
+	}
+	return b, nil
+}
+
+// connect indexes blocks, which must extend the indexed chain in order, and
+// persists them; idx.mu must be held.
+func (idx *Indexer) connect(blocks []*blockchain.Block) error {
+	for _, block := range blocks {
+		head := ""
+		if n := len(idx.blocks); n > 0 {
+			head = idx.blocks[n-1].Hash
+		}
+		if block.Index != len(idx.blocks) || block.PrevHash != head {
+			return fmt.Errorf("%w: block %d does not extend indexed height %d", ErrBadIndexEvent, block.Index, len(idx.blocks)-1)
+		}
+		b, err := newIndexedBlock(block)
+		if err != nil {
+			return err
+		}
+		if err := idx.write(b); err != nil {
+			return err
+		}
+		idx.add(b)
+	}
+	return nil
+}
+
+// OnChainEvent is a blockchain.ChainListener that keeps the index in step with
+// the canonical chain. Disconnected blocks are dropped and connected ones
+// indexed. A failure leaves the index behind the chain rather than wrong;
+// Sync brings it up to date again.
+func (idx *Indexer) OnChainEvent(ev blockchain.ChainEvent) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	fork := len(idx.blocks)
+	for _, b := range ev.Disconnected {
+		if b.Index < len(idx.blocks) && idx.blocks[b.Index].Hash == b.Hash {
+			fork = min(fork, b.Index)
+		}
+	}
+	if len(ev.Connected) > 0 {
+		fork = min(fork, ev.Connected[0].Index)
+	}
+	if fork < len(idx.blocks) {
+		idx.truncate(fork)
+		if err := idx.removeFrom(fork); err != nil {
+			return err
+		}
+	}
+	return idx.connect(ev.Connected)
+}
+
+// Head returns the height and hash of the newest indexed block, or -1 and ""
+// for an empty index.
+func (idx *Indexer) Head() (int, string) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if len(idx.blocks) == 0 {
+		return -1, ""
+	}
+	b := idx.blocks[len(idx.blocks)-1]
+	return b.Height, b.Hash
+}
+
+// Sync brings the index up to the canonical chain ending in headHash, reading
+// blocks from src. Blocks are walked back from the head until one the index
+// already holds; everything indexed above it is replaced. An empty headHash
+// empties the index.
+func (idx *Indexer) Sync(src BlockSource, headHash string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	var missing []*blockchain.Block
+	base := -1 // Height of the newest block on the chain the index already holds
+	for hash := headHash; hash != ""; {
+		block, err := src.Get(hash)
+		if err != nil {
+			return fmt.Errorf("index sync: %w", err)
+		}
+		if block.Index < len(idx.blocks) && idx.blocks[block.Index].Hash == hash {
+			base = block.Index
+			break
+		}
+		missing = append(missing, block)
+		hash = block.PrevHash
+	}
+	if fork := base + 1; fork < len(idx.blocks) {
+		idx.truncate(fork)
+		if err := idx.removeFrom(fork); err != nil {
+			return err
+		}
+	}
+	for i, j := 0, len(missing)-1; i < j; i, j = i+1, j-1 {
+		missing[i], missing[j] = missing[j], missing[i]
+	}
+	return idx.connect(missing)
+}
+
+// Rebuild discards the index and rebuilds it from the canonical chain ending
+// in headHash, reading blocks from src.
+func (idx *Indexer) Rebuild(src BlockSource, headHash string) error {
+	idx.mu.Lock()
+	idx.reset()
+	err := idx.removeFrom(0)
+	idx.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return idx.Sync(src, headHash)
+}
+
+// Tx returns the location of the canonical transaction txHash.
+func (idx *Indexer) Tx(txHash string) (TxLocation, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	loc, ok := idx.txs[txHash]
+	if !ok {
+		return TxLocation{}, fmt.Errorf("%w: %s", ErrNotIndexed, txHash)
+	}
+	return loc, nil
+}
+
+// AddressTxs returns a page of the transactions sent from or to address,
+// newest first: up to limit of them after skipping offset. It also returns the
+// total number of transactions of the address.
+func (idx *Indexer) AddressTxs(address string, offset, limit int) ([]TxLocation, int) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	locs := idx.addrs[address]
+	total := len(locs)
+	if offset < 0 || offset >= total || limit <= 0 {
+		return nil, total
+	}
+	page := make([]TxLocation, 0, min(limit, total-offset))
+	for i := total - 1 - offset; i >= 0 && len(page) < limit; i-- {
+		page = append(page, locs[i])
+	}
+	return page, total
+}
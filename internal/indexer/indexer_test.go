@@ -0,0 +1,213 @@
+package indexer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+// mapSource serves blocks by hash.
+type mapSource map[string]*blockchain.Block
+
+// Get returns the block with hash.
+func (s mapSource) Get(hash string) (*blockchain.Block, error) {
+	b, ok := s[hash]
+	if !ok {
+		return nil, fmt.Errorf("block %s not found", hash)
+	}
+	return b, nil
+}
+
+// addr returns a test address.
+func addr(n int) string {
+	return fmt.Sprintf("%040x", n)
+}
+
+// block returns a block on top of parent carrying one transaction from and
+// to the given addresses per pair, and adds it to src.
+func block(src mapSource, parent *blockchain.Block, pairs ...[2]int) *blockchain.Block {
+	index, prev := 0, ""
+	if parent != nil {
+		index, prev = parent.Index+1, parent.Hash
+	}
+	var txs []string
+	for i, p := range pairs {
+		tx := blockchain.NewTransaction(addr(p[0]), addr(p[1]), 1, 0, uint64(index*10+i), nil)
+		txs = append(txs, tx.Encode())
+	}
+	b := blockchain.NewBlock(index, prev, txs)
+	b.Seal()
+	src[b.Hash] = b
+	return b
+}
+
+// txHash returns the hash of transaction i of b.
+func txHash(t *testing.T, b *blockchain.Block, i int) string {
+	t.Helper()
+	txs, err := b.DecodeTransactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txs[i].Hash()
+}
+
+// openChain opens an index in a temporary directory and connects n blocks
+// from address 1 to address 2.
+func openChain(t *testing.T, n int) (*Indexer, mapSource, []*blockchain.Block) {
+	t.Helper()
+	idx, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := mapSource{}
+	var blocks []*blockchain.Block
+	var parent *blockchain.Block
+	for i := 0; i < n; i++ {
+		parent = block(src, parent, [2]int{1, 2})
+		blocks = append(blocks, parent)
+	}
+	if err := idx.OnChainEvent(blockchain.ChainEvent{Connected: blocks}); err != nil {
+		t.Fatal(err)
+	}
+	return idx, src, blocks
+}
+
+func TestReorgDropsReplacedTxs(t *testing.T) {
+	idx, src, a := openChain(t, 3)
+	b1 := block(src, a[0], [2]int{3, 3})
+	b2 := block(src, b1, [2]int{3, 4})
+	b3 := block(src, b2)
+	ev := blockchain.ChainEvent{Disconnected: []*blockchain.Block{a[2], a[1]}, Connected: []*blockchain.Block{b1, b2, b3}}
+	if err := idx.OnChainEvent(ev); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range a[1:] {
+		if _, err := idx.Tx(txHash(t, b, 0)); !errors.Is(err, ErrNotIndexed) {
+			t.Fatalf("transaction of replaced block %d: %v", b.Index, err)
+		}
+	}
+	if loc, err := idx.Tx(txHash(t, a[0], 0)); err != nil || loc.Height != 0 {
+		t.Fatalf("transaction below the fork: %+v, %v", loc, err)
+	}
+	if loc, err := idx.Tx(txHash(t, b2, 0)); err != nil || loc.BlockHash != b2.Hash || loc.Height != 2 {
+		t.Fatalf("transaction of the new branch: %+v, %v", loc, err)
+	}
+	for n, want := range map[int]int{1: 1, 2: 1, 3: 2, 4: 1} {
+		if _, total := idx.AddressTxs(addr(n), 0, 10); total != want {
+			t.Errorf("address %d has %d transactions, want %d", n, total, want)
+		}
+	}
+	if h, hash := idx.Head(); h != 3 || hash != b3.Hash {
+		t.Fatalf("head %d %s, want block 3 of the new branch", h, hash)
+	}
+
+	// The files on disk follow the reorg too
+	reopened, err := Open(idx.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, hash := reopened.Head(); h != 3 || hash != b3.Hash {
+		t.Fatalf("reopened head %d %s", h, hash)
+	}
+	if _, total := reopened.AddressTxs(addr(1), 0, 10); total != 1 {
+		t.Fatalf("reopened index holds %d transactions of address 1, want 1", total)
+	}
+}
+
+func TestSyncAfterFailedEvent(t *testing.T) {
+	idx, src, blocks := openChain(t, 2)
+	b2 := block(src, blocks[1], [2]int{5, 6})
+	b3 := block(src, b2, [2]int{5, 6})
+	// The event for block 2 was missed
+	if err := idx.OnChainEvent(blockchain.ChainEvent{Connected: []*blockchain.Block{b3}}); !errors.Is(err, ErrBadIndexEvent) {
+		t.Fatalf("event skipping a height: %v", err)
+	}
+	if h, _ := idx.Head(); h != 1 {
+		t.Fatalf("failed event moved the head to %d", h)
+	}
+	if err := idx.Sync(src, b3.Hash); err != nil {
+		t.Fatal(err)
+	}
+	if h, hash := idx.Head(); h != 3 || hash != b3.Hash {
+		t.Fatalf("head %d after sync, want 3", h)
+	}
+	if _, total := idx.AddressTxs(addr(5), 0, 10); total != 2 {
+		t.Fatalf("address 5 has %d transactions after sync, want 2", total)
+	}
+
+	// Syncing to another branch replaces everything above the fork
+	c2 := block(src, blocks[1], [2]int{7, 8})
+	if err := idx.Sync(src, c2.Hash); err != nil {
+		t.Fatal(err)
+	}
+	if _, total := idx.AddressTxs(addr(5), 0, 10); total != 0 {
+		t.Fatalf("address 5 keeps %d transactions of the replaced branch", total)
+	}
+	if _, err := idx.Tx(txHash(t, c2, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Sync(src, "unknown"); err == nil {
+		t.Fatal("synced to a head the source does not hold")
+	}
+}
+
+func TestOpenLoadsLinkedPrefix(t *testing.T) {
+	for name, damage := range map[string]func(t *testing.T, idx *Indexer){
+		"missing": func(t *testing.T, idx *Indexer) {
+			if err := os.Remove(idx.path(2)); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"unlinked": func(t *testing.T, idx *Indexer) {
+			b := *idx.blocks[2]
+			b.PrevHash = idx.blocks[0].Hash
+			data, err := json.Marshal(&b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(idx.path(2), data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		},
+	} {
+		idx, _, blocks := openChain(t, 5)
+		damage(t, idx)
+		reopened, err := Open(idx.dir)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if h, hash := reopened.Head(); h != 1 || hash != blocks[1].Hash {
+			t.Fatalf("%s: reopened at %d, want the linked prefix up to 1", name, h)
+		}
+		if _, err := reopened.Tx(txHash(t, blocks[3], 0)); !errors.Is(err, ErrNotIndexed) {
+			t.Fatalf("%s: transaction above the gap: %v", name, err)
+		}
+		if heights, err := reopened.heights(); err != nil || len(heights) != 2 {
+			t.Fatalf("%s: index files for heights %v left, want 0 and 1", name, heights)
+		}
+	}
+}
+
+func TestAddressTxsPages(t *testing.T) {
+	idx, _, blocks := openChain(t, 5)
+	page, total := idx.AddressTxs(addr(1), 0, 2)
+	if total != 5 || len(page) != 2 || page[0].Height != 4 || page[1].Height != 3 {
+		t.Fatalf("first page %+v of %d, want heights 4 and 3 of 5", page, total)
+	}
+	page, _ = idx.AddressTxs(addr(1), 4, 2)
+	if len(page) != 1 || page[0].TxHash != txHash(t, blocks[0], 0) {
+		t.Fatalf("last page %+v, want the oldest transaction", page)
+	}
+	for _, c := range []struct{ offset, limit int }{{5, 2}, {9, 1}, {0, 0}, {0, -1}, {-1, 2}} {
+		if page, total := idx.AddressTxs(addr(1), c.offset, c.limit); page != nil || total != 5 {
+			t.Errorf("offset %d, limit %d: %d transactions of %d", c.offset, c.limit, len(page), total)
+		}
+	}
+	if page, total := idx.AddressTxs(addr(9), 0, 10); page != nil || total != 0 {
+		t.Fatalf("unknown address: %+v of %d", page, total)
+	}
+}
@@ -0,0 +1,136 @@
+package keystore
+
+// key.go: Key material held by the keystore
+// Supports ed25519 signing keys for wallets/validators and P-256 keys for VRF leader election.
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+)
+
+// KeyType identifies the algorithm of a stored key.
+type KeyType string
+
+const (
+	// KeyEd25519 is an ed25519 signing key used by wallets and validators.
+	KeyEd25519 KeyType = "ed25519"
+	// KeyP256 is an ECDSA P-256 key used by the VRF for leader election.
+	KeyP256 KeyType = "p256"
+)
+
+// Key is an unlocked private key together with its derived address.
+type Key struct {
+	Address string
+	Type    KeyType
+	// Private holds the raw key material: the 32-byte seed for ed25519 keys
+	// or the SEC 1 DER encoding for P-256 keys.
+	Private []byte
+}
+
+// GenerateKey creates a fresh random key of the given type.
+func GenerateKey(typ KeyType) (*Key, error) {
+	switch typ {
+	case KeyEd25519:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(typ, priv.Seed())
+	case KeyP256:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(priv)
+		if err != nil {
+			return nil, err
+		}
+		return NewKey(typ, der)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", typ)
+	}
+}
+
+// NewKey wraps raw private key material, validating it and deriving the address.
+func NewKey(typ KeyType, private []byte) (*Key, error) {
+	k := &Key{Type: typ, Private: append([]byte{}, private...)}
+	pub, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	k.Address = crypto.PubkeyToAddress(pub)
+	return k, nil
+}
+
+// PublicKey returns the encoded public key: the raw 32 bytes for ed25519 keys
+// or the uncompressed SEC 1 point for P-256 keys.
+func (k *Key) PublicKey() ([]byte, error) {
+	switch k.Type {
+	case KeyEd25519:
+		priv, err := k.Ed25519()
+		if err != nil {
+			return nil, err
+		}
+		return priv.Public().(ed25519.PublicKey), nil
+	case KeyP256:
+		priv, err := k.ECDSA()
+		if err != nil {
+			return nil, err
+		}
+		pub, err := priv.PublicKey.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		return pub.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Type)
+	}
+}
+
+// Ed25519 returns the key as an ed25519 private key.
+func (k *Key) Ed25519() (ed25519.PrivateKey, error) {
+	if k.Type != KeyEd25519 {
+		return nil, fmt.Errorf("key %s is %s, not ed25519", k.Address, k.Type)
+	}
+	if len(k.Private) != ed25519.SeedSize {
+		return nil, errors.New("invalid ed25519 seed length")
+	}
+	return ed25519.NewKeyFromSeed(k.Private), nil
+}
+
+// ECDSA returns the key as a P-256 ECDSA private key.
+func (k *Key) ECDSA() (*ecdsa.PrivateKey, error) {
+	if k.Type != KeyP256 {
+		return nil, fmt.Errorf("key %s is %s, not p256", k.Address, k.Type)
+	}
+	return x509.ParseECPrivateKey(k.Private)
+}
+
+// Sign signs msg with an ed25519 key.
+func (k *Key) Sign(msg []byte) ([]byte, error) {
+	priv, err := k.Ed25519()
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(priv, msg), nil
+}
+
+// copy returns a key with its own copy of the private key material.
+func (k *Key) copy() *Key {
+	return &Key{Address: k.Address, Type: k.Type, Private: append([]byte(nil), k.Private...)}
+}
+
+// zero wipes the private key material from memory.
+func (k *Key) zero() {
+	for i := range k.Private {
+		k.Private[i] = 0
+	}
+	k.Private = nil
+}
@@ -0,0 +1,440 @@
+package keystore
+
+// keystore.go: Encrypted on-disk keystore
+// Keys are sealed with AES-256-GCM under a PBKDF2-SHA256 stretched passphrase,
+// one JSON key file per address, written atomically and never overwritten.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+)
+
+const (
+	keyFileVersion = 1
+	cipherName     = "aes-256-gcm"
+	kdfName        = "pbkdf2-sha256"
+	// DefaultIterations is the PBKDF2 iteration count for newly sealed keys.
+	DefaultIterations = 262144
+	// MaxIterations bounds the iteration count of a key file, so a crafted
+	// file cannot stall unlocking.
+	MaxIterations = 10 * DefaultIterations
+	saltSize      = 32
+	derivedKeyLen = 32
+)
+
+var (
+	// ErrNoKey is returned when no key file exists for an address.
+	ErrNoKey = errors.New("no key for given address")
+	// ErrLocked is returned when a key is used before it has been unlocked.
+	ErrLocked = errors.New("key is locked")
+	// ErrDecrypt is returned when a passphrase fails to open a key file.
+	ErrDecrypt = errors.New("could not decrypt key with given passphrase")
+	// ErrExists is returned when importing a key that is already stored.
+	ErrExists = errors.New("key already exists")
+)
+
+// KeyInfo describes a stored key without exposing private material.
+type KeyInfo struct {
+	Address   string
+	Type      KeyType
+	PublicKey string
+	Unlocked  bool
+}
+
+// keyFile is the on-disk JSON representation of an encrypted key.
+type keyFile struct {
+	Version   int        `json:"version"`
+	Address   string     `json:"address"`
+	Type      KeyType    `json:"type"`
+	PublicKey string     `json:"publicKey"`
+	Crypto    cryptoJSON `json:"crypto"`
+}
+
+type cryptoJSON struct {
+	Cipher     string    `json:"cipher"`
+	CipherText string    `json:"ciphertext"`
+	Nonce      string    `json:"nonce"`
+	KDF        string    `json:"kdf"`
+	KDFParams  kdfParams `json:"kdfparams"`
+}
+
+type kdfParams struct {
+	Salt       string `json:"salt"`
+	Iterations int    `json:"iterations"`
+	KeyLen     int    `json:"dklen"`
+}
+
+// KeyStore manages encrypted key files in a directory and the set of unlocked keys.
+type KeyStore struct {
+	dir        string
+	iterations int
+	mu         sync.RWMutex
+	unlocked   map[string]*Key
+}
+
+// Open opens (creating if needed) a keystore rooted at dir.
+func Open(dir string) (*KeyStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &KeyStore{
+		dir:        dir,
+		iterations: DefaultIterations,
+		unlocked:   make(map[string]*Key),
+	}, nil
+}
+
+// SetIterations overrides the PBKDF2 iteration count used when sealing keys,
+// clamped to between 1 and MaxIterations.
+func (ks *KeyStore) SetIterations(n int) {
+	ks.iterations = max(1, min(n, MaxIterations))
+}
+
+// Dir returns the keystore directory.
+func (ks *KeyStore) Dir() string {
+	return ks.dir
+}
+
+// NewKey generates a key of the given type, stores it encrypted and returns its address.
+func (ks *KeyStore) NewKey(typ KeyType, passphrase string) (string, error) {
+	key, err := GenerateKey(typ)
+	if err != nil {
+		return "", err
+	}
+	defer key.zero()
+	if err := ks.store(key, passphrase); err != nil {
+		return "", err
+	}
+	return key.Address, nil
+}
+
+// Import stores raw private key material encrypted under passphrase. It
+// returns ErrExists if the address already has a key file.
+func (ks *KeyStore) Import(typ KeyType, private []byte, passphrase string) (string, error) {
+	key, err := NewKey(typ, private)
+	if err != nil {
+		return "", err
+	}
+	defer key.zero()
+	if err := ks.store(key, passphrase); err != nil {
+		return "", err
+	}
+	return key.Address, nil
+}
+
+// ImportKeyFile imports an exported key file sealed with passphrase and
+// re-seals it in this keystore under newPassphrase.
+func (ks *KeyStore) ImportKeyFile(data []byte, passphrase, newPassphrase string) (string, error) {
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return "", fmt.Errorf("invalid key file: %w", err)
+	}
+	key, err := decryptKey(&kf, passphrase)
+	if err != nil {
+		return "", err
+	}
+	defer key.zero()
+	return ks.Import(key.Type, key.Private, newPassphrase)
+}
+
+// Export returns the key file for address re-sealed under newPassphrase.
+func (ks *KeyStore) Export(address, passphrase, newPassphrase string) ([]byte, error) {
+	kf, err := ks.load(address)
+	if err != nil {
+		return nil, err
+	}
+	key, err := decryptKey(kf, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer key.zero()
+	out, err := encryptKey(key, newPassphrase, ks.iterations)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// Delete removes the key file for address after verifying the passphrase.
+func (ks *KeyStore) Delete(address, passphrase string) error {
+	kf, err := ks.load(address)
+	if err != nil {
+		return err
+	}
+	key, err := decryptKey(kf, passphrase)
+	if err != nil {
+		return err
+	}
+	key.zero()
+	ks.Lock(address)
+	return os.Remove(ks.path(address))
+}
+
+// Unlock decrypts the key for address and keeps it in memory until Lock is called.
+func (ks *KeyStore) Unlock(address, passphrase string) error {
+	kf, err := ks.load(address)
+	if err != nil {
+		return err
+	}
+	key, err := decryptKey(kf, passphrase)
+	if err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if old, ok := ks.unlocked[address]; ok {
+		old.zero()
+	}
+	ks.unlocked[address] = key
+	return nil
+}
+
+// Lock wipes the unlocked key for address from memory.
+func (ks *KeyStore) Lock(address string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if key, ok := ks.unlocked[address]; ok {
+		key.zero()
+		delete(ks.unlocked, address)
+	}
+}
+
+// LockAll wipes every unlocked key from memory.
+func (ks *KeyStore) LockAll() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for addr, key := range ks.unlocked {
+		key.zero()
+		delete(ks.unlocked, addr)
+	}
+}
+
+// Key returns a copy of the unlocked key for address. The copy belongs to the
+// caller: locking the address does not wipe it.
+func (ks *KeyStore) Key(address string) (*Key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.unlocked[address]
+	if !ok {
+		return nil, ErrLocked
+	}
+	return key.copy(), nil
+}
+
+// List returns all stored keys sorted by address.
+func (ks *KeyStore) List() ([]KeyInfo, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, err
+	}
+	var infos []KeyInfo
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, "key-") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		address := strings.TrimSuffix(strings.TrimPrefix(name, "key-"), ".json")
+		kf, err := ks.load(address)
+		if err != nil {
+			continue
+		}
+		ks.mu.RLock()
+		_, unlocked := ks.unlocked[address]
+		ks.mu.RUnlock()
+		infos = append(infos, KeyInfo{
+			Address:   kf.Address,
+			Type:      kf.Type,
+			PublicKey: kf.PublicKey,
+			Unlocked:  unlocked,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Address < infos[j].Address })
+	return infos, nil
+}
+
+// path returns the key file location for address.
+func (ks *KeyStore) path(address string) string {
+	return filepath.Join(ks.dir, "key-"+address+".json")
+}
+
+// load reads and parses the key file for address.
+func (ks *KeyStore) load(address string) (*keyFile, error) {
+	if !crypto.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid address %q", address)
+	}
+	data, err := os.ReadFile(ks.path(address))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoKey
+	} else if err != nil {
+		return nil, err
+	}
+	var kf keyFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("corrupt key file for %s: %w", address, err)
+	}
+	if kf.Address != address {
+		return nil, fmt.Errorf("key file for %s holds address %s", address, kf.Address)
+	}
+	return &kf, nil
+}
+
+// store seals key under passphrase and atomically creates its key file,
+// failing with ErrExists if the file is already there.
+func (ks *KeyStore) store(key *Key, passphrase string) error {
+	kf, err := encryptKey(key, passphrase, ks.iterations)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = createFileAtomic(ks.path(key.Address), data, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%w: %s", ErrExists, key.Address)
+	}
+	return err
+}
+
+// encryptKey seals the private key material with AES-256-GCM, binding the address as associated data.
+func encryptKey(key *Key, passphrase string, iterations int) (*keyFile, error) {
+	pub, err := key.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	ciphertext := aead.Seal(nil, nonce, key.Private, []byte(key.Address))
+	return &keyFile{
+		Version:   keyFileVersion,
+		Address:   key.Address,
+		Type:      key.Type,
+		PublicKey: hex.EncodeToString(pub),
+		Crypto: cryptoJSON{
+			Cipher:     cipherName,
+			CipherText: hex.EncodeToString(ciphertext),
+			Nonce:      hex.EncodeToString(nonce),
+			KDF:        kdfName,
+			KDFParams: kdfParams{
+				Salt:       hex.EncodeToString(salt),
+				Iterations: iterations,
+				KeyLen:     derivedKeyLen,
+			},
+		},
+	}, nil
+}
+
+// decryptKey opens a key file with passphrase and checks the recovered key matches its address.
+func decryptKey(kf *keyFile, passphrase string) (*Key, error) {
+	if kf.Version != keyFileVersion {
+		return nil, fmt.Errorf("unsupported key file version %d", kf.Version)
+	}
+	if kf.Crypto.Cipher != cipherName || kf.Crypto.KDF != kdfName {
+		return nil, fmt.Errorf("unsupported cipher %q / kdf %q", kf.Crypto.Cipher, kf.Crypto.KDF)
+	}
+	if kf.Crypto.KDFParams.KeyLen != derivedKeyLen || kf.Crypto.KDFParams.Iterations < 1 || kf.Crypto.KDFParams.Iterations > MaxIterations {
+		return nil, errors.New("invalid kdf parameters")
+	}
+	salt, err := hex.DecodeString(kf.Crypto.KDFParams.Salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(kf.Crypto.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := hex.DecodeString(kf.Crypto.CipherText)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt, kf.Crypto.KDFParams.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce length")
+	}
+	private, err := aead.Open(nil, nonce, ciphertext, []byte(kf.Address))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	key, err := NewKey(kf.Type, private)
+	if err != nil {
+		return nil, err
+	}
+	if key.Address != kf.Address {
+		key.zero()
+		return nil, fmt.Errorf("decrypted key does not match address %s", kf.Address)
+	}
+	return key, nil
+}
+
+// newAEAD stretches passphrase with PBKDF2 and returns an AES-256-GCM cipher.
+func newAEAD(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	derived := crypto.PBKDF2([]byte(passphrase), salt, iterations, derivedKeyLen, sha256.New)
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// createFileAtomic writes data to a temporary file in the same directory,
+// syncs it and links it to path so readers never see a partial file. It fails
+// with an os.ErrExist error rather than replace an existing file.
+func createFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Link(tmpName, path); err != nil {
+		return err
+	}
+	// Persist the rename itself; not all platforms support syncing directories.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
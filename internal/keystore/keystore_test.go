@@ -0,0 +1,118 @@
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+)
+
+// testStore opens a keystore that seals keys with a single PBKDF2 iteration.
+func testStore(t *testing.T) *KeyStore {
+	t.Helper()
+	ks, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.SetIterations(1)
+	return ks
+}
+
+func TestKeySurvivesLock(t *testing.T) {
+	ks := testStore(t)
+	addr, err := ks.NewKey(KeyEd25519, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Unlock(addr, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	key, err := ks.Key(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := key.Sign([]byte("msg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Lock(addr)
+	after, err := key.Sign([]byte("msg"))
+	if err != nil || string(after) != string(before) {
+		t.Fatalf("key changed by Lock: %v", err)
+	}
+	if _, err := ks.Key(addr); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Key after Lock: %v", err)
+	}
+}
+
+func TestConcurrentImportStoresOnce(t *testing.T) {
+	ks := testStore(t)
+	key, err := GenerateKey(KeyEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = ks.Import(KeyEd25519, key.Private, "pass")
+		}(i)
+	}
+	wg.Wait()
+	stored := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			stored++
+		case !errors.Is(err, ErrExists):
+			t.Fatalf("Import: %v", err)
+		}
+	}
+	if stored != 1 {
+		t.Fatalf("%d concurrent imports stored the key, want 1", stored)
+	}
+}
+
+func TestIterationBounds(t *testing.T) {
+	ks := testStore(t)
+	addr, err := ks.NewKey(KeyEd25519, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kf, err := ks.load(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kf.Crypto.KDFParams.Iterations = MaxIterations + 1
+	data, err := json.Marshal(kf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ks.path(addr), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Unlock(addr, "pass"); err == nil {
+		t.Fatal("unlocked a key file beyond the iteration bound")
+	}
+	ks.SetIterations(MaxIterations + 1)
+	if ks.iterations != MaxIterations {
+		t.Fatalf("iterations set to %d, above the bound", ks.iterations)
+	}
+	for _, n := range []int{0, -5} {
+		ks.SetIterations(n)
+		if ks.iterations != 1 {
+			t.Fatalf("SetIterations(%d) set %d iterations, want 1", n, ks.iterations)
+		}
+	}
+	// Keys sealed at the lower bound still open
+	addr, err = ks.NewKey(KeyEd25519, "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Unlock(addr, "pass"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,407 @@
+package lightclient
+
+// lightclient.go: Header-only client verifying data served by a full node
+// Syncs block headers, checks their linkage and the consensus certificates
+// they commit to, and accepts transactions and account state from the node
+// only with Merkle proofs against a verified header. Old headers can be pruned;
+// they are recovered on demand with an ancestry proof against the head.
+// Receipts are checked the same way against the header's receipts root.
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+var (
+	// ErrBadHeader is returned for a header that does not extend the verified chain.
+	ErrBadHeader = errors.New("header does not extend verified chain")
+	// ErrBadCertificate is returned for a certificate that does not finalize its block.
+	ErrBadCertificate = errors.New("invalid consensus certificate")
+	// ErrFinalityViolation is returned when the node abandons a certified header.
+	ErrFinalityViolation = errors.New("node reorganized a finalized header")
+	// ErrUnknownBlock is returned when a proof refers to a header the client has not verified.
+	ErrUnknownBlock = errors.New("block header not verified")
+)
+
+// headerBatch is the number of headers requested at a time while syncing.
+const headerBatch = 128
+
+// maxRollback is the deepest reorganization of unfinalized headers Sync follows.
+const maxRollback = 64
+
+// Node is the interface a light client uses to reach a full node.
+type Node interface {
+	// Handshake identifies the chain the node follows.
+	Handshake() (blockchain.Handshake, error)
+	// Headers returns up to count canonical headers starting at height from.
+	Headers(from, count int) ([]blockchain.BlockHeader, error)
+	// Certificate returns the certificate that finalized a block, or nil if it has none.
+	Certificate(blockHash string) (*blockchain.Certificate, error)
+	// TransactionProof returns the inclusion proof of a canonical transaction.
+	TransactionProof(txHash string) (*blockchain.TxProof, error)
+	// ReceiptProof returns the inclusion proof of a canonical transaction's receipt.
+	ReceiptProof(txHash string) (*blockchain.ReceiptProof, error)
+	// AccountProof returns the proof of an account against the state root at height.
+	AccountProof(address string, height int) (*blockchain.AccountProof, error)
+	// AncestryProof returns the canonical header at height and its ancestry
+	// proof against the canonical header at height head.
+	AncestryProof(height, head int) (*blockchain.AncestryProof, *blockchain.BlockHeader, error)
+}
+
+// Config controls what a light client trusts.
+type Config struct {
+	ChainID             uint64                        // Chain ID the node must follow; 0 accepts any
+	Genesis             string                        // Trusted hash of the first block; empty trusts the first header served
+	Validators          []blockchain.GenesisValidator // Validator set whose signed attestations certificates need
+	QuorumNumerator     int                           // Certificates need attestations from more than
+	QuorumDenominator   int                           // QuorumNumerator/QuorumDenominator of the validators
+	RequireCertificates bool                          // Reject headers after the first that commit to no certificate
+}
+
+// Client is a light client following one full node.
+type Client struct {
+	mu        sync.Mutex
+	node      Node
+	cfg       Config
+	headers   []blockchain.BlockHeader // Verified canonical headers from height base on
+	ancestry  []amf.MMRPeaks           // MMR over the hashes up to each retained header
+	base      int                      // Height of the oldest retained header
+	finalized int                      // Highest height with a verified certificate, -1 if none
+	connected bool                     // Whether the node passed the handshake
+}
+
+// New creates a light client for node.
+func New(node Node, cfg Config) *Client {
+	return &Client{node: node, cfg: cfg, finalized: -1}
+}
+
+// Sync fetches and verifies every header the node has beyond the client's head,
+// following reorganizations of unfinalized headers. It returns the new height
+// of the head, or -1 if the node has no blocks.
+func (c *Client) Sync() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.handshake(); err != nil {
+		return c.next() - 1, err
+	}
+	rolledBack := 0
+	for {
+		headers, err := c.node.Headers(c.next(), headerBatch)
+		if err != nil {
+			return c.next() - 1, err
+		}
+		if len(headers) == 0 {
+			return c.next() - 1, nil
+		}
+		for i := range headers {
+			err := c.extend(&headers[i])
+			if errors.Is(err, ErrBadHeader) && i == 0 && len(c.headers) > 0 && headers[i].Index == c.next() {
+				// The node may have switched branches: drop our head and retry
+				if rolledBack == maxRollback {
+					return c.next() - 1, err
+				}
+				if err := c.rollback(); err != nil {
+					return c.next() - 1, err
+				}
+				rolledBack++
+				break
+			}
+			if err != nil {
+				return c.next() - 1, err
+			}
+		}
+	}
+}
+
+// handshake checks, once, that the node follows the configured chain and pins
+// the genesis it announces if none is configured; c.mu must be held.
+func (c *Client) handshake() error {
+	if c.connected {
+		return nil
+	}
+	remote, err := c.node.Handshake()
+	if err != nil {
+		return err
+	}
+	want := blockchain.Handshake{ChainID: c.cfg.ChainID, Genesis: c.cfg.Genesis}
+	if want.ChainID == 0 {
+		want.ChainID = remote.ChainID
+	}
+	if want.Genesis == "" {
+		want.Genesis = remote.Genesis
+	}
+	if err := want.Check(remote); err != nil {
+		return err
+	}
+	c.cfg.Genesis = remote.Genesis
+	c.connected = true
+	return nil
+}
+
+// next returns the height of the next header to sync; c.mu must be held.
+func (c *Client) next() int {
+	return c.base + len(c.headers)
+}
+
+// extend verifies h as the next header and appends it; c.mu must be held.
+func (c *Client) extend(h *blockchain.BlockHeader) error {
+	height := c.next()
+	hash := h.Hash()
+	if h.Index != height {
+		return fmt.Errorf("%w: index %d, want %d", ErrBadHeader, h.Index, height)
+	}
+	// The header must commit to the MMR over every header before it, which
+	// Header later trusts for the pruned ones
+	var ancestry amf.MMRPeaks
+	if len(c.ancestry) > 0 {
+		ancestry = c.ancestry[len(c.ancestry)-1]
+	}
+	if want := ancestry.Root(); !bytes.Equal(h.MMRRoot, want) {
+		return fmt.Errorf("%w: MMR root %x, want %x", ErrBadHeader, h.MMRRoot, want)
+	}
+	if height == 0 {
+		if h.PrevHash != "" {
+			return fmt.Errorf("%w: first header has parent %s", ErrBadHeader, h.PrevHash)
+		}
+		if c.cfg.Genesis != "" && hash != c.cfg.Genesis {
+			return fmt.Errorf("%w: genesis %s, want %s", ErrBadHeader, hash, c.cfg.Genesis)
+		}
+	} else {
+		parent := &c.headers[len(c.headers)-1]
+		if h.PrevHash != parent.Hash() {
+			return fmt.Errorf("%w: parent %s, want %s", ErrBadHeader, h.PrevHash, parent.Hash())
+		}
+		if h.Timestamp.Before(parent.Timestamp) {
+			return fmt.Errorf("%w: timestamp before parent", ErrBadHeader)
+		}
+		if err := c.checkCertificate(parent, h); err != nil {
+			return err
+		}
+	}
+	c.headers = append(c.headers, *h)
+	c.ancestry = append(c.ancestry, ancestry.Append(h.HeaderHash()))
+	return nil
+}
+
+// checkCertificate verifies the certificate of parent that child commits to;
+// c.mu must be held.
+func (c *Client) checkCertificate(parent, child *blockchain.BlockHeader) error {
+	if child.CertHash == nil {
+		if c.cfg.RequireCertificates {
+			return fmt.Errorf("%w: block %d has no certificate", ErrBadCertificate, parent.Index)
+		}
+		return nil
+	}
+	cert, err := c.node.Certificate(parent.Hash())
+	if err != nil {
+		return err
+	}
+	if cert == nil || !bytes.Equal(cert.Hash(), child.CertHash) {
+		return fmt.Errorf("%w: block %d: certificate does not match hash committed by child", ErrBadCertificate, parent.Index)
+	}
+	if err := VerifyCertificate(cert, parent, c.cfg.Validators, c.cfg.QuorumNumerator, c.cfg.QuorumDenominator); err != nil {
+		return err
+	}
+	c.finalized = parent.Index
+	return nil
+}
+
+// rollback drops the head header unless it is finalized or the only one
+// retained; c.mu must be held.
+func (c *Client) rollback() error {
+	head := c.next() - 1
+	if head <= c.finalized {
+		return fmt.Errorf("%w: height %d", ErrFinalityViolation, head)
+	}
+	if len(c.headers) == 1 && c.base > 0 {
+		return fmt.Errorf("%w: reorganization below pruned headers", ErrBadHeader)
+	}
+	c.headers = c.headers[:len(c.headers)-1]
+	c.ancestry = c.ancestry[:len(c.ancestry)-1]
+	return nil
+}
+
+// Prune drops all but the newest keep headers (at least the head). Pruned
+// headers are still usable through ancestry proofs against the head.
+func (c *Client) Prune(keep int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	drop := len(c.headers) - max(keep, 1)
+	if drop <= 0 {
+		return
+	}
+	c.headers = append([]blockchain.BlockHeader(nil), c.headers[drop:]...)
+	c.ancestry = append([]amf.MMRPeaks(nil), c.ancestry[drop:]...)
+	c.base += drop
+}
+
+// VerifyCertificate checks that cert finalizes the block with header h: every
+// attestation is for that block and round and signed by a distinct member of
+// validators, and more than quorumNum/quorumDen of the set attested.
+func VerifyCertificate(cert *blockchain.Certificate, h *blockchain.BlockHeader, validators []blockchain.GenesisValidator, quorumNum, quorumDen int) error {
+	if len(validators) == 0 {
+		return fmt.Errorf("%w: no validator set to check it against", ErrBadCertificate)
+	}
+	if quorumDen <= 0 || quorumNum < 0 || quorumNum >= quorumDen {
+		return fmt.Errorf("%w: quorum %d/%d is not a fraction below 1", ErrBadCertificate, quorumNum, quorumDen)
+	}
+	hash := h.Hash()
+	if cert.BlockHash != hash {
+		return fmt.Errorf("%w: certifies %s, not %s", ErrBadCertificate, cert.BlockHash, hash)
+	}
+	if cert.Round != h.Round {
+		return fmt.Errorf("%w: round %d, block proposed in %d", ErrBadCertificate, cert.Round, h.Round)
+	}
+	keys := make(map[string][]byte, len(validators))
+	for _, v := range validators {
+		pub, err := hex.DecodeString(v.PublicKey)
+		if err != nil {
+			return fmt.Errorf("%w: validator %s: bad public key", ErrBadCertificate, v.Address)
+		}
+		keys[v.Address] = pub
+	}
+	seen := make(map[string]bool, len(cert.Attestations))
+	for _, a := range cert.Attestations {
+		if a.BlockHash != hash || a.Round != cert.Round {
+			return fmt.Errorf("%w: attestation by %s is for another vote", ErrBadCertificate, a.Validator)
+		}
+		if seen[a.Validator] {
+			return fmt.Errorf("%w: duplicate attestation by %s", ErrBadCertificate, a.Validator)
+		}
+		seen[a.Validator] = true
+		pub, ok := keys[a.Validator]
+		if !ok {
+			return fmt.Errorf("%w: %s is not a validator", ErrBadCertificate, a.Validator)
+		}
+		if err := a.Verify(pub); err != nil {
+			return fmt.Errorf("%w: %v", ErrBadCertificate, err)
+		}
+	}
+	if len(seen)*quorumDen <= len(keys)*quorumNum {
+		return fmt.Errorf("%w: %d of %d validators, need more than %d/%d", ErrBadCertificate, len(seen), len(keys), quorumNum, quorumDen)
+	}
+	return nil
+}
+
+// Head returns the verified head header, or nil before the first sync.
+func (c *Client) Head() *blockchain.BlockHeader {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.headers) == 0 {
+		return nil
+	}
+	h := c.headers[len(c.headers)-1]
+	return &h
+}
+
+// Header returns the verified header at height. A pruned header is fetched
+// from the node and checked with an ancestry proof against the head.
+func (c *Client) Header(height int) (*blockchain.BlockHeader, error) {
+	c.mu.Lock()
+	head := c.next() - 1
+	if height > head || height < 0 {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%w: height %d", ErrUnknownBlock, height)
+	}
+	if height >= c.base {
+		h := c.headers[height-c.base]
+		c.mu.Unlock()
+		return &h, nil
+	}
+	headHeader := c.headers[len(c.headers)-1]
+	c.mu.Unlock()
+	proof, h, err := c.node.AncestryProof(height, head)
+	if err != nil {
+		return nil, err
+	}
+	if err := proof.Verify(&headHeader); err != nil {
+		return nil, err
+	}
+	if h.Index != height || h.Hash() != proof.BlockHash {
+		return nil, fmt.Errorf("%w: header does not match proven block %s", blockchain.ErrBadAncestryProof, proof.BlockHash)
+	}
+	return h, nil
+}
+
+// Finalized returns the highest height whose certificate was verified, or -1.
+func (c *Client) Finalized() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.finalized
+}
+
+// VerifyTransaction asks the node for the inclusion proof of txHash and checks
+// it against the transaction root of a verified header. It returns the
+// transaction and the header of the block including it.
+func (c *Client) VerifyTransaction(txHash string) (*blockchain.Transaction, *blockchain.BlockHeader, error) {
+	proof, err := c.node.TransactionProof(txHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	h, err := c.Header(proof.Height)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.Hash() != proof.BlockHash {
+		return nil, nil, fmt.Errorf("%w: %s at height %d", ErrUnknownBlock, proof.BlockHash, proof.Height)
+	}
+	tx, err := proof.Verify(h.TxRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tx.Hash() != txHash {
+		return nil, nil, fmt.Errorf("%w: proof is for transaction %s", blockchain.ErrBadTxProof, tx.Hash())
+	}
+	return tx, h, nil
+}
+
+// VerifyReceipt asks the node for the receipt of txHash with its inclusion
+// proof and checks it against the receipts root of a verified header. It
+// returns the receipt and the header of the block including the transaction.
+func (c *Client) VerifyReceipt(txHash string) (*blockchain.Receipt, *blockchain.BlockHeader, error) {
+	proof, err := c.node.ReceiptProof(txHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	h, err := c.Header(proof.Height)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.Hash() != proof.BlockHash {
+		return nil, nil, fmt.Errorf("%w: %s at height %d", ErrUnknownBlock, proof.BlockHash, proof.Height)
+	}
+	r, err := proof.Verify(h.ReceiptsRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.TxHash != txHash {
+		return nil, nil, fmt.Errorf("%w: proof is for transaction %s", blockchain.ErrBadReceiptProof, r.TxHash)
+	}
+	return r, h, nil
+}
+
+// Account asks the node for address's account at height and checks the proof
+// against the state root of the verified header at that height.
+func (c *Client) Account(address string, height int) (*blockchain.AccountProof, error) {
+	h, err := c.Header(height)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := c.node.AccountProof(address, height)
+	if err != nil {
+		return nil, err
+	}
+	if proof.Address != address {
+		return nil, fmt.Errorf("%w: proof is for %s", blockchain.ErrBadAccountProof, proof.Address)
+	}
+	if err := proof.Verify(h.StateRoot); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
@@ -0,0 +1,337 @@
+package lightclient
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+	"github.com/bilal2134/Blockchain_A3/internal/store"
+)
+
+// testChain returns a chain of n empty blocks one second apart.
+func testChain(t *testing.T, n int) *blockchain.Blockchain {
+	t.Helper()
+	bc := blockchain.NewBlockchain(blockchain.LongestChain{})
+	start := time.Now().Add(-time.Duration(n) * time.Second)
+	var parent *blockchain.Block
+	for i := 0; i < n; i++ {
+		var b *blockchain.Block
+		if parent == nil {
+			b = blockchain.NewBlock(0, "", nil)
+		} else {
+			b = blockchain.NewBlock(i, parent.Hash, nil)
+			b.MMRRoot = parent.NextMMRRoot()
+		}
+		b.Timestamp = start.Add(time.Duration(i) * time.Second)
+		b.Seal()
+		if err := bc.AddBlock(b); err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+		parent = b
+	}
+	return bc
+}
+
+// forgedNode serves headers that are linked correctly but rewritten by forge.
+type forgedNode struct {
+	*LocalNode
+	forge func(h *blockchain.BlockHeader)
+}
+
+// Headers returns the node's headers, forged and relinked.
+func (n *forgedNode) Headers(from, count int) ([]blockchain.BlockHeader, error) {
+	all, err := n.LocalNode.Headers(0, from+count)
+	if err != nil {
+		return nil, err
+	}
+	prev := ""
+	for i := range all {
+		all[i].PrevHash = prev
+		n.forge(&all[i])
+		prev = all[i].Hash()
+	}
+	if from >= len(all) {
+		return nil, nil
+	}
+	return all[from:], nil
+}
+
+// Handshake announces the forged genesis.
+func (n *forgedNode) Handshake() (blockchain.Handshake, error) {
+	headers, err := n.Headers(0, 1)
+	if err != nil || len(headers) == 0 {
+		return blockchain.Handshake{}, ErrNoGenesis
+	}
+	return blockchain.Handshake{Genesis: headers[0].Hash()}, nil
+}
+
+func TestSyncAndPrunedHeaders(t *testing.T) {
+	bc := testChain(t, 40)
+	c := New(NewLocalNode(bc, nil), Config{})
+	head, err := c.Sync()
+	if err != nil || head != 39 {
+		t.Fatalf("Sync: head %d, %v", head, err)
+	}
+	c.Prune(1)
+	for _, height := range []int{0, 1, 17, 38, 39} {
+		h, err := c.Header(height)
+		if err != nil {
+			t.Fatalf("Header(%d): %v", height, err)
+		}
+		want, _ := bc.BlockAt(height)
+		if h.Hash() != want.Hash {
+			t.Fatalf("Header(%d) = %s, want %s", height, h.Hash(), want.Hash)
+		}
+	}
+}
+
+func TestSyncRejectsForgedMMRRoot(t *testing.T) {
+	bc := testChain(t, 10)
+	node := &forgedNode{LocalNode: NewLocalNode(bc, nil), forge: func(h *blockchain.BlockHeader) {
+		if h.Index == 5 {
+			h.MMRRoot = make([]byte, len(h.MMRRoot))
+		}
+	}}
+	c := New(node, Config{})
+	head, err := c.Sync()
+	if !errors.Is(err, ErrBadHeader) {
+		t.Fatalf("Sync accepted a forged MMR root: head %d, err %v", head, err)
+	}
+	if head != 4 {
+		t.Fatalf("head %d after rejecting header 5, want 4", head)
+	}
+}
+
+// testValidators returns n validators and the keys they sign with.
+func testValidators(t *testing.T, n int) ([]blockchain.GenesisValidator, keySigner) {
+	t.Helper()
+	var validators []blockchain.GenesisValidator
+	keys := make(keySigner)
+	for i := 0; i < n; i++ {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := crypto.PubkeyToAddress(pub)
+		validators = append(validators, blockchain.GenesisValidator{Address: addr, PublicKey: hex.EncodeToString(pub)})
+		keys[addr] = priv
+	}
+	return validators, keys
+}
+
+// keySigner signs with ed25519 keys by address.
+type keySigner map[string]ed25519.PrivateKey
+
+// PublicKey returns the public key of address.
+func (k keySigner) PublicKey(address string) ([]byte, error) {
+	key, ok := k[address]
+	if !ok {
+		return nil, errors.New("unknown signer")
+	}
+	return key.Public().(ed25519.PublicKey), nil
+}
+
+// Sign signs msg with the key of address.
+func (k keySigner) Sign(address string, msg []byte) ([]byte, error) {
+	key, ok := k[address]
+	if !ok {
+		return nil, errors.New("unknown signer")
+	}
+	return ed25519.Sign(key, msg), nil
+}
+
+// certify returns the certificate of h with attestations by voters.
+func certify(t *testing.T, h *blockchain.BlockHeader, voters []blockchain.GenesisValidator, signer blockchain.Signer) *blockchain.Certificate {
+	t.Helper()
+	var attestations []blockchain.Attestation
+	for _, v := range voters {
+		a, err := blockchain.NewAttestation(v.Address, h.Round, h.Hash(), signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestations = append(attestations, a)
+	}
+	return blockchain.NewCertificate(h.Round, h.Hash(), attestations)
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	validators, keys := testValidators(t, 4)
+	b := blockchain.NewBlock(1, "parent", nil)
+	b.Round = 3
+	b.Seal()
+	h := &b.BlockHeader
+	// 3 of 4 is more than 2/3 and than 1/2; 2 of 4 is neither
+	if err := VerifyCertificate(certify(t, h, validators[:3], keys), h, validators, 2, 3); err != nil {
+		t.Fatalf("3 of 4 signed attestations rejected at 2/3: %v", err)
+	}
+	if err := VerifyCertificate(certify(t, h, validators[:2], keys), h, validators, 1, 2); !errors.Is(err, ErrBadCertificate) {
+		t.Fatalf("2 of 4 accepted at 1/2: %v", err)
+	}
+	if err := VerifyCertificate(certify(t, h, validators[:3], keys), h, validators, 3, 4); !errors.Is(err, ErrBadCertificate) {
+		t.Fatalf("3 of 4 accepted at 3/4: %v", err)
+	}
+	if err := VerifyCertificate(certify(t, h, validators[:3], keys), h, nil, 2, 3); !errors.Is(err, ErrBadCertificate) {
+		t.Fatalf("certificate accepted without a validator set: %v", err)
+	}
+
+	unsigned := blockchain.NewCertificate(h.Round, h.Hash(), nil)
+	for _, v := range validators {
+		unsigned.Attestations = append(unsigned.Attestations, blockchain.Attestation{Validator: v.Address, Round: h.Round, BlockHash: h.Hash()})
+	}
+	if err := VerifyCertificate(unsigned, h, validators, 2, 3); !errors.Is(err, ErrBadCertificate) {
+		t.Fatalf("unsigned attestations accepted: %v", err)
+	}
+
+	// Signed by the wrong key: every vote cast by one validator
+	forger := keySigner{}
+	for _, v := range validators {
+		forger[v.Address] = keys[validators[0].Address]
+	}
+	if err := VerifyCertificate(certify(t, h, validators, forger), h, validators, 2, 3); !errors.Is(err, ErrBadCertificate) {
+		t.Fatalf("attestations signed with another key accepted: %v", err)
+	}
+
+	// Signatures over another block and round do not carry over to h
+	other := blockchain.NewBlock(1, "other parent", nil)
+	other.Round = 2
+	other.Seal()
+	moved := certify(t, &other.BlockHeader, validators, keys)
+	moved.Round, moved.BlockHash = h.Round, h.Hash()
+	for i := range moved.Attestations {
+		moved.Attestations[i].Round, moved.Attestations[i].BlockHash = h.Round, h.Hash()
+	}
+	if err := VerifyCertificate(moved, h, validators, 2, 3); !errors.Is(err, ErrBadCertificate) {
+		t.Fatalf("attestations signed for another block accepted: %v", err)
+	}
+
+	outsiders, outsiderKeys := testValidators(t, 3)
+	if err := VerifyCertificate(certify(t, h, outsiders, outsiderKeys), h, validators, 2, 3); !errors.Is(err, ErrBadCertificate) {
+		t.Fatalf("attestations by non-validators accepted: %v", err)
+	}
+}
+
+func TestSyncVerifiesSignedCertificates(t *testing.T) {
+	validators, keys := testValidators(t, 3)
+	bc := blockchain.NewBlockchain(blockchain.LongestChain{})
+	node := NewLocalNode(bc, nil)
+	start := time.Now().Add(-time.Minute)
+	var parent *blockchain.Block
+	var cert *blockchain.Certificate
+	for i := 0; i < 4; i++ {
+		var b *blockchain.Block
+		if parent == nil {
+			b = blockchain.NewBlock(0, "", nil)
+		} else {
+			b = blockchain.NewBlock(i, parent.Hash, nil)
+			b.MMRRoot = parent.NextMMRRoot()
+			b.CertHash = cert.Hash()
+		}
+		b.Round = uint64(i + 1)
+		b.Timestamp = start.Add(time.Duration(i) * time.Second)
+		b.Seal()
+		if err := bc.AddBlock(b); err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+		cert = certify(t, &b.BlockHeader, validators[:2], keys)
+		node.AddCertificate(cert)
+		parent = b
+	}
+	c := New(node, Config{Validators: validators, QuorumNumerator: 1, QuorumDenominator: 2, RequireCertificates: true})
+	if head, err := c.Sync(); err != nil || head != 3 {
+		t.Fatalf("Sync: head %d, %v", head, err)
+	}
+	if c.Finalized() != 2 {
+		t.Fatalf("finalized through %d, want 2", c.Finalized())
+	}
+	strict := New(node, Config{Validators: validators, QuorumNumerator: 2, QuorumDenominator: 3, RequireCertificates: true})
+	if head, err := strict.Sync(); !errors.Is(err, ErrBadCertificate) || head != 0 {
+		t.Fatalf("2 of 3 attestations accepted at a 2/3 quorum: head %d, %v", head, err)
+	}
+}
+
+func TestLocalNodeServesPrunedEntries(t *testing.T) {
+	node := NewLocalNode(testChain(t, 2), nil)
+	if _, err := node.ProveEntry("key", 0); !errors.Is(err, store.ErrNotArchived) {
+		t.Fatalf("node without checkpoints: %v", err)
+	}
+	archive, err := store.OpenCheckpoints(t.TempDir(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := store.OpenCheckpoints(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := blockchain.NewStateDB(amf.NewForest(), amf.DefaultRebalanceConfig)
+	addr := "0000000000000000000000000000000000000001"
+	for h, balance := range []uint64{5, 8} {
+		state.Journal.BeginBlock(h)
+		if err := state.SetAccount(addr, blockchain.Account{Balance: balance}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cp, entries, err := state.Prune(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*store.CheckpointStore{archive, pruned} {
+		if err := c.Add(cp, entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+	node.Checkpoints = archive
+	entry, err := pruned.FetchEntry(node, blockchain.AccountKey(addr), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acct, _ := entry.Account(); acct.Balance != 5 {
+		t.Fatalf("account before block 1 has balance %d, want 5", acct.Balance)
+	}
+}
+
+func TestSyncRejectsOtherGenesis(t *testing.T) {
+	spec := func(balance uint64) *blockchain.Genesis {
+		g := blockchain.DefaultGenesis()
+		g.Alloc["1111111111111111111111111111111111111111"] = balance
+		if err := g.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+	ours, theirs := spec(1000), spec(2000)
+	genesis, err := theirs.Block()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc := blockchain.NewBlockchain(blockchain.LongestChain{})
+	if err := bc.AddBlock(genesis); err != nil {
+		t.Fatal(err)
+	}
+	node := NewLocalNode(bc, nil)
+	node.ChainID = theirs.ChainID
+
+	want, err := ours.Handshake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(node, Config{ChainID: want.ChainID, Genesis: want.Genesis})
+	if _, err := c.Sync(); !errors.Is(err, blockchain.ErrGenesisMismatch) {
+		t.Fatalf("synced from a node with another genesis: %v", err)
+	}
+	other := New(node, Config{ChainID: theirs.ChainID + 1})
+	if _, err := other.Sync(); !errors.Is(err, blockchain.ErrGenesisMismatch) {
+		t.Fatalf("synced from a node on another chain ID: %v", err)
+	}
+	same, err := theirs.Handshake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, err := New(node, Config{ChainID: same.ChainID, Genesis: same.Genesis}).Sync(); err != nil || head != 0 {
+		t.Fatalf("same genesis: head %d, %v", head, err)
+	}
+}
@@ -0,0 +1,149 @@
+package lightclient
+
+// local.go: In-process full node for light clients
+// Serves headers, certificates and proofs straight from a node's own chain,
+// so a light client can run against it without a network.
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/store"
+)
+
+var (
+	// ErrTxNotFound is returned when no canonical block includes a transaction.
+	ErrTxNotFound = errors.New("transaction not found")
+	// ErrNoGenesis is returned for a handshake before the chain has a first block.
+	ErrNoGenesis = errors.New("chain has no genesis block")
+)
+
+// LocalNode implements Node over a full node's chain, state history and the
+// certificates its consensus produced.
+type LocalNode struct {
+	Chain       *blockchain.Blockchain
+	History     *store.History         // Serves account proofs at past heights
+	Checkpoints *store.CheckpointStore // Serves pruned state entries in archive mode; may be nil
+	ChainID     uint64                 // Announced in the handshake
+	mu          sync.Mutex
+	certs       map[string]*blockchain.Certificate
+}
+
+// NewLocalNode serves chain, with account state from history.
+func NewLocalNode(chain *blockchain.Blockchain, history *store.History) *LocalNode {
+	return &LocalNode{Chain: chain, History: history, certs: make(map[string]*blockchain.Certificate)}
+}
+
+// AddCertificate records the certificate that finalized a block.
+func (n *LocalNode) AddCertificate(cert *blockchain.Certificate) {
+	if cert == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.certs[cert.BlockHash] = cert
+}
+
+// Headers returns up to count canonical headers starting at height from.
+func (n *LocalNode) Headers(from, count int) ([]blockchain.BlockHeader, error) {
+	var headers []blockchain.BlockHeader
+	for h := from; h < from+count; h++ {
+		block, ok := n.Chain.BlockAt(h)
+		if !ok {
+			break
+		}
+		headers = append(headers, block.BlockHeader)
+	}
+	return headers, nil
+}
+
+// Handshake announces the chain ID and the hash of the first block, the
+// pinned genesis if the chain has one.
+func (n *LocalNode) Handshake() (blockchain.Handshake, error) {
+	genesis := n.Chain.Genesis()
+	if genesis == "" {
+		block, ok := n.Chain.BlockAt(0)
+		if !ok {
+			return blockchain.Handshake{}, ErrNoGenesis
+		}
+		genesis = block.Hash
+	}
+	return blockchain.Handshake{ChainID: n.ChainID, Genesis: genesis}, nil
+}
+
+// Certificate returns the recorded certificate for blockHash, or nil.
+func (n *LocalNode) Certificate(blockHash string) (*blockchain.Certificate, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.certs[blockHash], nil
+}
+
+// TransactionProof searches the canonical chain, newest block first, for txHash.
+func (n *LocalNode) TransactionProof(txHash string) (*blockchain.TxProof, error) {
+	block, i, err := n.findTransaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+	return block.ProveTransaction(i)
+}
+
+// ReceiptProof proves the receipt of the canonical transaction txHash.
+func (n *LocalNode) ReceiptProof(txHash string) (*blockchain.ReceiptProof, error) {
+	block, i, err := n.findTransaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+	return block.ProveReceipt(i)
+}
+
+// findTransaction returns the newest canonical block including txHash and the
+// transaction's index in it.
+func (n *LocalNode) findTransaction(txHash string) (*blockchain.Block, int, error) {
+	head := n.Chain.Head()
+	if head == nil {
+		return nil, 0, fmt.Errorf("%w: %s", ErrTxNotFound, txHash)
+	}
+	for h := head.Index; h >= 0; h-- {
+		block, ok := n.Chain.BlockAt(h)
+		if !ok {
+			continue
+		}
+		if i, ok := block.FindTransaction(txHash); ok {
+			return block, i, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("%w: %s", ErrTxNotFound, txHash)
+}
+
+// AccountProof proves address's account at height from the state history.
+func (n *LocalNode) AccountProof(address string, height int) (*blockchain.AccountProof, error) {
+	proof, _, err := n.History.AccountAt(address, height)
+	return proof, err
+}
+
+// ProveEntry proves the pruned state entry for key overwritten at height
+// from the archived checkpoint history, for a pruned node to check against its
+// own checkpoint with store.CheckpointStore.FetchEntry.
+func (n *LocalNode) ProveEntry(key string, height int) (*blockchain.EntryProof, error) {
+	if n.Checkpoints == nil {
+		return nil, store.ErrNotArchived
+	}
+	return n.Checkpoints.ProveEntry(key, height)
+}
+
+// AncestryProof returns the canonical header at height with its ancestry proof
+// against the canonical header at height head.
+func (n *LocalNode) AncestryProof(height, head int) (*blockchain.AncestryProof, *blockchain.BlockHeader, error) {
+	proof, err := n.Chain.ProveAncestry(height, head)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, ok := n.Chain.BlockAt(height)
+	if !ok {
+		return nil, nil, fmt.Errorf("no canonical block at height %d", height)
+	}
+	h := block.BlockHeader
+	return proof, &h, nil
+}
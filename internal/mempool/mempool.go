@@ -0,0 +1,371 @@
+package mempool
+
+// mempool.go: Pending transaction pool
+// Validates transactions against current state, orders them by fee while keeping
+// per-sender nonce order, supports replace-by-fee and evicts under size limits.
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+var (
+	// ErrAlreadyKnown is returned for a transaction already in the pool.
+	ErrAlreadyKnown = errors.New("transaction already known")
+	// ErrNonceTooLow is returned when the sender's state nonce has moved past the transaction.
+	ErrNonceTooLow = errors.New("nonce too low")
+	// ErrNonceGap is returned when a transaction is too far ahead of the sender's state nonce.
+	ErrNonceGap = errors.New("nonce too far in the future")
+	// ErrFeeTooLow is returned for transactions paying less than the pool minimum.
+	ErrFeeTooLow = errors.New("fee below pool minimum")
+	// ErrReplaceUnderpriced is returned when a replacement does not bump the fee enough.
+	ErrReplaceUnderpriced = errors.New("replacement transaction underpriced")
+	// ErrPoolFull is returned when the pool is full and the transaction pays too little to evict another.
+	ErrPoolFull = errors.New("transaction pool is full")
+	// ErrOversized is returned for a single transaction larger than the pool byte limit.
+	ErrOversized = errors.New("transaction larger than pool limit")
+)
+
+// StateReader exposes the account state transactions are checked against.
+type StateReader interface {
+	GetAccount(address string) blockchain.Account
+}
+
+// Config bounds the pool and sets its admission policy.
+type Config struct {
+	MaxTxs      int    // Maximum number of pooled transactions
+	MaxBytes    int    // Maximum total encoded size of pooled transactions
+	MinFee      uint64 // Minimum fee accepted
+	PriceBump   uint64 // Minimum fee increase, in percent, to replace a pending transaction
+	MaxNonceGap uint64 // How far ahead of the state nonce a transaction may be queued
+}
+
+// DefaultConfig is a pool configuration suitable for a single node.
+var DefaultConfig = Config{
+	MaxTxs:      4096,
+	MaxBytes:    4 << 20,
+	MinFee:      0,
+	PriceBump:   10,
+	MaxNonceGap: 64,
+}
+
+// Pool holds validated transactions waiting to be included in a block.
+type Pool struct {
+	mu      sync.Mutex
+	cfg     Config
+	state   StateReader
+	all     map[string]*blockchain.Transaction            // tx hash -> tx
+	senders map[string]map[uint64]*blockchain.Transaction // sender -> nonce -> tx
+	bytes   int
+}
+
+// New creates an empty pool validating against state.
+func New(cfg Config, state StateReader) *Pool {
+	return &Pool{
+		cfg:     cfg,
+		state:   state,
+		all:     make(map[string]*blockchain.Transaction),
+		senders: make(map[string]map[uint64]*blockchain.Transaction),
+	}
+}
+
+// SetState switches the state transactions are validated against, e.g. after a reorg.
+func (p *Pool) SetState(state StateReader) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = state
+	p.demoteStale()
+}
+
+// Add validates tx and inserts it, replacing a same-nonce transaction from the
+// same sender if tx pays a sufficiently higher fee.
+func (p *Pool) Add(tx *blockchain.Transaction) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	hash := tx.Hash()
+	if _, ok := p.all[hash]; ok {
+		return ErrAlreadyKnown
+	}
+	if err := tx.VerifySignature(); err != nil {
+		return err
+	}
+	if tx.Fee < p.cfg.MinFee {
+		return ErrFeeTooLow
+	}
+	size := tx.Size()
+	if p.cfg.MaxBytes > 0 && size > p.cfg.MaxBytes {
+		return ErrOversized
+	}
+	acct := p.state.GetAccount(tx.From)
+	if tx.Nonce < acct.Nonce {
+		return fmt.Errorf("%w: have %d, state %d", ErrNonceTooLow, tx.Nonce, acct.Nonce)
+	}
+	if p.cfg.MaxNonceGap > 0 && tx.Nonce-acct.Nonce > p.cfg.MaxNonceGap {
+		return ErrNonceGap
+	}
+	if tx.Amount+tx.Fee < tx.Amount {
+		return blockchain.ErrInsufficientFunds
+	}
+	// The sender must afford every queued transaction along with this one
+	cost := tx.Cost()
+	for nonce, queued := range p.senders[tx.From] {
+		if nonce == tx.Nonce {
+			continue // Replaced by tx
+		}
+		if cost+queued.Cost() < cost {
+			return blockchain.ErrInsufficientFunds
+		}
+		cost += queued.Cost()
+	}
+	if acct.Balance < cost {
+		return fmt.Errorf("%w: balance %d, pending cost %d", blockchain.ErrInsufficientFunds, acct.Balance, cost)
+	}
+
+	// Replace-by-fee for an existing sender/nonce slot
+	old, replacing := p.senders[tx.From][tx.Nonce]
+	if replacing {
+		minFee := old.Fee + old.Fee*p.cfg.PriceBump/100
+		if tx.Fee <= old.Fee || tx.Fee < minFee {
+			return fmt.Errorf("%w: fee %d, need at least %d", ErrReplaceUnderpriced, tx.Fee, max(minFee, old.Fee+1))
+		}
+		p.remove(old.Hash())
+	}
+
+	// Make room by evicting cheaper transactions; if that is not enough, the
+	// pool is restored as it was
+	var evicted []*blockchain.Transaction
+	for p.overLimit(1, size) {
+		victim := p.evictionCandidate(tx)
+		if victim == nil || victim.Fee >= tx.Fee {
+			for _, v := range evicted {
+				p.insert(v.Hash(), v)
+			}
+			if replacing {
+				p.insert(old.Hash(), old)
+			}
+			return ErrPoolFull
+		}
+		p.remove(victim.Hash())
+		evicted = append(evicted, victim)
+	}
+
+	p.insert(hash, tx)
+	return nil
+}
+
+// Get returns the pooled transaction with the given hash.
+func (p *Pool) Get(hash string) (*blockchain.Transaction, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tx, ok := p.all[hash]
+	return tx, ok
+}
+
+// Len returns the number of pooled transactions.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.all)
+}
+
+// Size returns the total encoded size of pooled transactions in bytes.
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.bytes
+}
+
+// NextNonce returns the nonce for the sender's next transaction, counting
+// contiguous pooled transactions on top of the state nonce.
+func (p *Pool) NextNonce(address string) uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	nonce := p.state.GetAccount(address).Nonce
+	for {
+		if _, ok := p.senders[address][nonce]; !ok {
+			return nonce
+		}
+		nonce++
+	}
+}
+
+// Pending returns up to limit executable transactions (limit <= 0 means all),
+// highest fee first while preserving each sender's nonce order. Only transactions
+// whose nonces follow on contiguously from the sender's state nonce are returned.
+func (p *Pool) Pending(limit int) []*blockchain.Transaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Executable run of transactions per sender, in nonce order
+	runs := make(map[string][]*blockchain.Transaction)
+	for sender, byNonce := range p.senders {
+		nonce := p.state.GetAccount(sender).Nonce
+		for {
+			tx, ok := byNonce[nonce]
+			if !ok {
+				break
+			}
+			runs[sender] = append(runs[sender], tx)
+			nonce++
+		}
+	}
+
+	// Merge the runs by fee, always taking the head of a sender's run
+	h := &feeHeap{}
+	for _, run := range runs {
+		heap.Push(h, run)
+	}
+	var out []*blockchain.Transaction
+	for h.Len() > 0 && (limit <= 0 || len(out) < limit) {
+		run := heap.Pop(h).([]*blockchain.Transaction)
+		out = append(out, run[0])
+		if len(run) > 1 {
+			heap.Push(h, run[1:])
+		}
+	}
+	return out
+}
+
+// Remove drops a transaction by hash.
+func (p *Pool) Remove(hash string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.remove(hash)
+}
+
+// RemoveBlock drops every transaction a committed block included, along with
+// any pooled transactions whose nonces are now stale against the state.
+func (p *Pool) RemoveBlock(block *blockchain.Block) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, enc := range block.Transactions {
+		tx, err := blockchain.DecodeTransaction(enc)
+		if err != nil {
+			continue
+		}
+		p.remove(tx.Hash())
+	}
+	p.demoteStale()
+}
+
+// OnChainEvent is a blockchain.ChainListener that returns transactions from
+// disconnected blocks to the pool and drops those the new chain included. It
+// should be subscribed after the state listener so re-added transactions are
+// checked against the post-reorg state.
+func (p *Pool) OnChainEvent(ev blockchain.ChainEvent) error {
+	for _, block := range ev.Disconnected {
+		txs, err := block.DecodeTransactions()
+		if err != nil {
+			continue
+		}
+		for _, tx := range txs {
+			p.Add(tx)
+		}
+	}
+	for _, block := range ev.Connected {
+		p.RemoveBlock(block)
+	}
+	return nil
+}
+
+// insert adds tx to the indexes.
+func (p *Pool) insert(hash string, tx *blockchain.Transaction) {
+	p.all[hash] = tx
+	if p.senders[tx.From] == nil {
+		p.senders[tx.From] = make(map[uint64]*blockchain.Transaction)
+	}
+	p.senders[tx.From][tx.Nonce] = tx
+	p.bytes += tx.Size()
+}
+
+// remove deletes a transaction from the indexes.
+func (p *Pool) remove(hash string) {
+	tx, ok := p.all[hash]
+	if !ok {
+		return
+	}
+	delete(p.all, hash)
+	if byNonce := p.senders[tx.From]; byNonce != nil {
+		if byNonce[tx.Nonce] == tx {
+			delete(byNonce, tx.Nonce)
+		}
+		if len(byNonce) == 0 {
+			delete(p.senders, tx.From)
+		}
+	}
+	p.bytes -= tx.Size()
+}
+
+// demoteStale drops transactions with nonces below their sender's state nonce.
+func (p *Pool) demoteStale() {
+	for sender, byNonce := range p.senders {
+		nonce := p.state.GetAccount(sender).Nonce
+		for n, tx := range byNonce {
+			if n < nonce {
+				p.remove(tx.Hash())
+			}
+		}
+	}
+}
+
+// overLimit reports whether adding n transactions of size bytes would exceed the limits.
+func (p *Pool) overLimit(n, size int) bool {
+	if p.cfg.MaxTxs > 0 && len(p.all)+n > p.cfg.MaxTxs {
+		return true
+	}
+	return p.cfg.MaxBytes > 0 && p.bytes+size > p.cfg.MaxBytes
+}
+
+// evictionCandidate returns the cheapest transaction that is last in its
+// sender's nonce sequence, so eviction never opens a nonce gap. The last
+// transaction of incoming's sender is passed over when incoming would queue
+// after it, as evicting it would leave incoming behind a gap.
+func (p *Pool) evictionCandidate(incoming *blockchain.Transaction) *blockchain.Transaction {
+	senders := make([]string, 0, len(p.senders))
+	for s := range p.senders {
+		senders = append(senders, s)
+	}
+	sort.Strings(senders)
+	var victim *blockchain.Transaction
+	for _, s := range senders {
+		var last *blockchain.Transaction
+		for _, tx := range p.senders[s] {
+			if last == nil || tx.Nonce > last.Nonce {
+				last = tx
+			}
+		}
+		if s == incoming.From && incoming.Nonce > last.Nonce {
+			continue
+		}
+		if victim == nil || last.Fee < victim.Fee {
+			victim = last
+		}
+	}
+	return victim
+}
+
+// feeHeap orders per-sender transaction runs by the fee of their head transaction.
+type feeHeap [][]*blockchain.Transaction
+
+func (h feeHeap) Len() int { return len(h) }
+func (h feeHeap) Less(i, j int) bool {
+	if h[i][0].Fee != h[j][0].Fee {
+		return h[i][0].Fee > h[j][0].Fee
+	}
+	return h[i][0].Hash() < h[j][0].Hash()
+}
+func (h feeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *feeHeap) Push(x interface{}) { *h = append(*h, x.([]*blockchain.Transaction)) }
+func (h *feeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
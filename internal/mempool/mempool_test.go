@@ -0,0 +1,155 @@
+package mempool
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+)
+
+// testState is an account state backed by a map.
+type testState map[string]blockchain.Account
+
+// GetAccount returns the account at address.
+func (s testState) GetAccount(address string) blockchain.Account {
+	return s[address]
+}
+
+// testKeys signs with ed25519 keys by address.
+type testKeys map[string]ed25519.PrivateKey
+
+// newAccount generates a key and funds its address in state.
+func (k testKeys) newAccount(t *testing.T, state testState, balance uint64) string {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := crypto.PubkeyToAddress(pub)
+	k[addr] = priv
+	state[addr] = blockchain.Account{Balance: balance}
+	return addr
+}
+
+// PublicKey returns the public key of address.
+func (k testKeys) PublicKey(address string) ([]byte, error) {
+	return k[address].Public().(ed25519.PublicKey), nil
+}
+
+// Sign signs msg with the key of address.
+func (k testKeys) Sign(address string, msg []byte) ([]byte, error) {
+	return ed25519.Sign(k[address], msg), nil
+}
+
+// transfer returns a signed transaction from from.
+func (k testKeys) transfer(t *testing.T, from string, amount, fee, nonce uint64, payload []byte) *blockchain.Transaction {
+	t.Helper()
+	tx := blockchain.NewTransaction(from, "0000000000000000000000000000000000000001", amount, fee, nonce, payload)
+	if err := tx.Sign(k); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestFullPoolRejectionEvictsNothing(t *testing.T) {
+	state, keys := testState{}, testKeys{}
+	var pooled []*blockchain.Transaction
+	for _, fee := range []uint64{1, 50, 60} {
+		from := keys.newAccount(t, state, 1000)
+		pooled = append(pooled, keys.transfer(t, from, 1, fee, 0, nil))
+	}
+	size := pooled[0].Size()
+	p := New(Config{MaxBytes: 3 * size, PriceBump: 10}, state)
+	for _, tx := range pooled {
+		if err := p.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Fitting tx takes two evictions, but it only outbids the cheapest transaction
+	from := keys.newAccount(t, state, 1000)
+	big := keys.transfer(t, from, 1, 10, 0, make([]byte, size))
+	if err := p.Add(big); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("Add: %v, want ErrPoolFull", err)
+	}
+	if p.Len() != 3 || p.Size() != 3*size {
+		t.Fatalf("pool holds %d transactions of %d bytes after a rejection, want 3 of %d", p.Len(), p.Size(), 3*size)
+	}
+	for _, tx := range pooled {
+		if _, ok := p.Get(tx.Hash()); !ok {
+			t.Fatalf("transaction with fee %d evicted by a rejected one", tx.Fee)
+		}
+	}
+
+	// A replacement that does not fit restores the transaction it replaced
+	replacement := keys.transfer(t, pooled[0].From, 1, 40, 0, make([]byte, size))
+	if err := p.Add(replacement); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("Add replacement: %v, want ErrPoolFull", err)
+	}
+	if _, ok := p.Get(pooled[0].Hash()); !ok || p.Len() != 3 {
+		t.Fatal("rejected replacement removed the original")
+	}
+}
+
+func TestPendingCostLimitedByBalance(t *testing.T) {
+	state, keys := testState{}, testKeys{}
+	from := keys.newAccount(t, state, 100)
+	p := New(DefaultConfig, state)
+	if err := p.Add(keys.transfer(t, from, 50, 1, 0, nil)); err != nil {
+		t.Fatal(err)
+	}
+	// 51 + 51 is more than the balance, though each transaction alone is not
+	if err := p.Add(keys.transfer(t, from, 50, 1, 1, nil)); !errors.Is(err, blockchain.ErrInsufficientFunds) {
+		t.Fatalf("Add beyond the balance: %v", err)
+	}
+	// Replacing the first transaction frees its cost
+	if err := p.Add(keys.transfer(t, from, 40, 2, 0, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Add(keys.transfer(t, from, 50, 1, 1, nil)); err != nil {
+		t.Fatalf("Add within the balance: %v", err)
+	}
+	if err := p.Add(keys.transfer(t, from, 7, 1, 2, nil)); !errors.Is(err, blockchain.ErrInsufficientFunds) {
+		t.Fatalf("Add of a third transaction beyond the balance: %v", err)
+	}
+	if p.Len() != 2 {
+		t.Fatalf("pool holds %d transactions, want 2", p.Len())
+	}
+}
+
+func TestEvictionKeepsSendersNonceSequence(t *testing.T) {
+	state, keys := testState{}, testKeys{}
+	from := keys.newAccount(t, state, 1000)
+	other := keys.newAccount(t, state, 1000)
+	p := New(Config{MaxTxs: 2, PriceBump: 10}, state)
+	first := keys.transfer(t, from, 1, 1, 0, nil)
+	pricier := keys.transfer(t, other, 1, 5, 0, nil)
+	for _, tx := range []*blockchain.Transaction{first, pricier} {
+		if err := p.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The sender's nonce 0 is the cheapest, but evicting it would leave nonce 1
+	// queued behind a gap; the other sender's transaction goes instead
+	next := keys.transfer(t, from, 1, 10, 1, nil)
+	if err := p.Add(next); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Get(first.Hash()); !ok {
+		t.Fatal("eviction opened a nonce gap before the added transaction")
+	}
+	if _, ok := p.Get(pricier.Hash()); ok || p.Len() != 2 {
+		t.Fatalf("pool holds %d transactions, want the sender's two", p.Len())
+	}
+
+	// With nothing else to evict, the transaction is rejected
+	if err := p.Add(keys.transfer(t, from, 1, 20, 2, nil)); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("Add with only the sender's own transactions to evict: %v", err)
+	}
+	if p.Len() != 2 {
+		t.Fatalf("rejected transaction changed the pool to %d transactions", p.Len())
+	}
+}
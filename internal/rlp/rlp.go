@@ -0,0 +1,214 @@
+package rlp
+
+// rlp.go: Recursive Length Prefix encoding
+// Byte strings and lists of items with Ethereum's length prefixes. Decoding
+// only accepts the canonical (shortest) form of every item.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	// ErrShortBuffer is returned when an item's length runs past the end of the input.
+	ErrShortBuffer = errors.New("rlp: unexpected end of input")
+	// ErrNonCanonical is returned for items not in their shortest encoding.
+	ErrNonCanonical = errors.New("rlp: non-canonical encoding")
+	// ErrTrailing is returned when input remains after the top-level item.
+	ErrTrailing = errors.New("rlp: trailing bytes")
+	// ErrExpectedString is returned when a list is found where a string is expected.
+	ErrExpectedString = errors.New("rlp: expected string")
+	// ErrExpectedList is returned when a string is found where a list is expected.
+	ErrExpectedList = errors.New("rlp: expected list")
+)
+
+// EncodeBytes encodes a byte string.
+func EncodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+	return append(header(0x80, len(b)), b...)
+}
+
+// EncodeString encodes a string as a byte string.
+func EncodeString(s string) []byte {
+	return EncodeBytes([]byte(s))
+}
+
+// EncodeUint encodes an integer as its big-endian bytes without leading zeros;
+// zero is the empty string.
+func EncodeUint(v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	i := 0
+	for i < 8 && buf[i] == 0 {
+		i++
+	}
+	return EncodeBytes(buf[i:])
+}
+
+// EncodeBigInt encodes a non-negative big integer like EncodeUint.
+func EncodeBigInt(v *big.Int) []byte {
+	return EncodeBytes(v.Bytes())
+}
+
+// EncodeList encodes a list from already encoded items.
+func EncodeList(items ...[]byte) []byte {
+	size := 0
+	for _, it := range items {
+		size += len(it)
+	}
+	out := header(0xc0, size)
+	for _, it := range items {
+		out = append(out, it...)
+	}
+	return out
+}
+
+// header returns the prefix for a payload of size bytes; offset is 0x80 for
+// strings and 0xc0 for lists.
+func header(offset byte, size int) []byte {
+	if size <= 55 {
+		return []byte{offset + byte(size)}
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(size))
+	i := 0
+	for buf[i] == 0 {
+		i++
+	}
+	return append([]byte{offset + 55 + byte(8-i)}, buf[i:]...)
+}
+
+// Item is a decoded RLP item: either a byte string or a list.
+type Item struct {
+	IsList bool
+	Bytes  []byte // String content
+	List   []Item // List elements
+}
+
+// Decode parses exactly one item from b.
+func Decode(b []byte) (Item, error) {
+	it, rest, err := decodeItem(b)
+	if err != nil {
+		return Item{}, err
+	}
+	if len(rest) != 0 {
+		return Item{}, ErrTrailing
+	}
+	return it, nil
+}
+
+// decodeItem parses the first item of b and returns the remaining input.
+func decodeItem(b []byte) (Item, []byte, error) {
+	isList, content, rest, err := Split(b)
+	if err != nil {
+		return Item{}, nil, err
+	}
+	if !isList {
+		return Item{Bytes: content}, rest, nil
+	}
+	it := Item{IsList: true, List: []Item{}}
+	for len(content) > 0 {
+		var el Item
+		el, content, err = decodeItem(content)
+		if err != nil {
+			return Item{}, nil, err
+		}
+		it.List = append(it.List, el)
+	}
+	return it, rest, nil
+}
+
+// Split reads the prefix of the first item in b and returns whether it is a
+// list, its content and the input following it.
+func Split(b []byte) (isList bool, content, rest []byte, err error) {
+	if len(b) == 0 {
+		return false, nil, nil, ErrShortBuffer
+	}
+	p := b[0]
+	var offset, size int
+	switch {
+	case p < 0x80:
+		return false, b[:1], b[1:], nil
+	case p <= 0xb7:
+		offset, size = 1, int(p-0x80)
+		if size == 1 && len(b) > 1 && b[1] < 0x80 {
+			return false, nil, nil, fmt.Errorf("%w: single byte %#x with prefix", ErrNonCanonical, b[1])
+		}
+	case p < 0xc0:
+		offset, size, err = longSize(b, int(p-0xb7))
+	case p <= 0xf7:
+		isList, offset, size = true, 1, int(p-0xc0)
+	default:
+		isList = true
+		offset, size, err = longSize(b, int(p-0xf7))
+	}
+	if err != nil {
+		return false, nil, nil, err
+	}
+	if len(b)-offset < size {
+		return false, nil, nil, ErrShortBuffer
+	}
+	return isList, b[offset : offset+size], b[offset+size:], nil
+}
+
+// longSize reads an n-byte big-endian length following the prefix byte.
+func longSize(b []byte, n int) (offset, size int, err error) {
+	if len(b) < 1+n {
+		return 0, 0, ErrShortBuffer
+	}
+	if b[1] == 0 {
+		return 0, 0, fmt.Errorf("%w: leading zero in length", ErrNonCanonical)
+	}
+	var v uint64
+	for _, c := range b[1 : 1+n] {
+		v = v<<8 | uint64(c)
+	}
+	if v <= 55 {
+		return 0, 0, fmt.Errorf("%w: long form for length %d", ErrNonCanonical, v)
+	}
+	if v > uint64(len(b)) {
+		return 0, 0, ErrShortBuffer
+	}
+	return 1 + n, int(v), nil
+}
+
+// Uint64 interprets a string item as a canonical big-endian integer.
+func (it Item) Uint64() (uint64, error) {
+	if it.IsList {
+		return 0, ErrExpectedString
+	}
+	if len(it.Bytes) > 8 {
+		return 0, fmt.Errorf("rlp: integer of %d bytes overflows uint64", len(it.Bytes))
+	}
+	if len(it.Bytes) > 0 && it.Bytes[0] == 0 {
+		return 0, fmt.Errorf("%w: integer with leading zero", ErrNonCanonical)
+	}
+	var v uint64
+	for _, c := range it.Bytes {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// Text returns the content of a string item.
+func (it Item) Text() (string, error) {
+	if it.IsList {
+		return "", ErrExpectedString
+	}
+	return string(it.Bytes), nil
+}
+
+// Elems returns the elements of a list item, checking their number.
+func (it Item) Elems(n int) ([]Item, error) {
+	if !it.IsList {
+		return nil, ErrExpectedList
+	}
+	if n >= 0 && len(it.List) != n {
+		return nil, fmt.Errorf("rlp: list has %d elements, want %d", len(it.List), n)
+	}
+	return it.List, nil
+}
@@ -0,0 +1,135 @@
+package rlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// lorem is a 56-byte string, the shortest that takes a long-form prefix.
+const lorem = "Lorem ipsum dolor sit amet, consectetur adipisicing elit"
+
+// The published RLP test vectors.
+var vectors = []struct {
+	name string
+	enc  []byte
+	want string
+}{
+	{"empty string", EncodeString(""), "80"},
+	{"dog", EncodeString("dog"), "83646f67"},
+	{"byte 0x00", EncodeBytes([]byte{0x00}), "00"},
+	{"byte 0x0f", EncodeBytes([]byte{0x0f}), "0f"},
+	{"byte 0x7f", EncodeBytes([]byte{0x7f}), "7f"},
+	{"byte 0x80", EncodeBytes([]byte{0x80}), "8180"},
+	{"long string", EncodeString(lorem), "b838" + hex.EncodeToString([]byte(lorem))},
+	{"integer 0", EncodeUint(0), "80"},
+	{"integer 15", EncodeUint(15), "0f"},
+	{"integer 1024", EncodeUint(1024), "820400"},
+	{"integer 2^64-1", EncodeUint(^uint64(0)), "88ffffffffffffffff"},
+	{"big integer", EncodeBigInt(new(big.Int).Lsh(big.NewInt(1), 64)), "89010000000000000000"},
+	{"empty list", EncodeList(), "c0"},
+	{"cat and dog", EncodeList(EncodeString("cat"), EncodeString("dog")), "c88363617483646f67"},
+	// The set theoretical representation of three: [ [], [[]], [ [], [[]] ] ]
+	{"set of three", EncodeList(
+		EncodeList(),
+		EncodeList(EncodeList()),
+		EncodeList(EncodeList(), EncodeList(EncodeList())),
+	), "c7c0c1c0c3c0c1c0"},
+	{"long list", EncodeList(
+		EncodeString("aaa"), EncodeString("bbb"), EncodeString("ccc"), EncodeString("ddd"),
+		EncodeString("eee"), EncodeString("fff"), EncodeString("ggg"), EncodeString("hhh"),
+		EncodeString("iii"), EncodeString("jjj"), EncodeString("kkk"), EncodeString("lll"),
+		EncodeString("mmm"), EncodeString("nnn"), EncodeString("ooo"),
+	), "f83c" + "83616161" + "83626262" + "83636363" + "83646464" +
+		"83656565" + "83666666" + "83676767" + "83686868" + "83696969" + "836a6a6a" +
+		"836b6b6b" + "836c6c6c" + "836d6d6d" + "836e6e6e" + "836f6f6f"},
+}
+
+func TestEncodeVectors(t *testing.T) {
+	for _, v := range vectors {
+		if got := hex.EncodeToString(v.enc); got != v.want {
+			t.Errorf("%s: encoded %s, want %s", v.name, got, v.want)
+		}
+	}
+}
+
+func TestDecodeVectors(t *testing.T) {
+	for _, v := range vectors {
+		it, err := Decode(v.enc)
+		if err != nil {
+			t.Errorf("%s: %v", v.name, err)
+			continue
+		}
+		if got := reencode(it); !bytes.Equal(got, v.enc) {
+			t.Errorf("%s: decoded item re-encodes to %x", v.name, got)
+		}
+	}
+	it, err := Decode(EncodeUint(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := it.Uint64(); err != nil || n != 1024 {
+		t.Fatalf("Uint64 = %d, %v", n, err)
+	}
+	it, err = Decode(EncodeList(EncodeString("cat"), EncodeString("dog")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	elems, err := it.Elems(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := elems[1].Text(); s != "dog" {
+		t.Fatalf("second element %q, want dog", s)
+	}
+	if _, err := it.Elems(3); err == nil {
+		t.Fatal("Elems accepted the wrong element count")
+	}
+}
+
+// reencode encodes a decoded item again.
+func reencode(it Item) []byte {
+	if !it.IsList {
+		return EncodeBytes(it.Bytes)
+	}
+	items := make([][]byte, len(it.List))
+	for i, el := range it.List {
+		items[i] = reencode(el)
+	}
+	return EncodeList(items...)
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		enc  string
+		want error
+	}{
+		{"empty input", "", ErrShortBuffer},
+		{"short string", "83646f", ErrShortBuffer},
+		{"short list", "c88363617483646f", ErrShortBuffer},
+		{"single byte with a prefix", "8100", ErrNonCanonical},
+		{"long form of a short string", "b803646f67", ErrNonCanonical},
+		{"length with a leading zero", "b90038" + hex.EncodeToString([]byte(lorem)), ErrNonCanonical},
+		{"long form of a short list", "f803c0c0c0", ErrNonCanonical},
+		{"trailing bytes", "c000", ErrTrailing},
+	}
+	for _, tt := range tests {
+		enc, _ := hex.DecodeString(tt.enc)
+		if _, err := Decode(enc); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	it, _ := Decode([]byte{0x82, 0x00, 0x01})
+	if _, err := it.Uint64(); !errors.Is(err, ErrNonCanonical) {
+		t.Fatalf("integer with a leading zero: %v", err)
+	}
+	if _, err := (Item{IsList: true}).Uint64(); !errors.Is(err, ErrExpectedString) {
+		t.Fatalf("list read as an integer: %v", err)
+	}
+	if _, err := (Item{}).Elems(0); !errors.Is(err, ErrExpectedList) {
+		t.Fatalf("string read as a list: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package ssz
+
+// merkle.go: hash_tree_root Merkleization
+// Values are packed into 32-byte chunks and Merkleized with SHA-256 over a tree
+// padded with zero chunks to a power of two; lists mix in their length.
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Root is a 32-byte hash tree root.
+type Root [32]byte
+
+// maxDepth bounds the tree depths zero hashes are precomputed for.
+const maxDepth = 64
+
+// zeroHashes[i] is the root of a tree of depth i with only zero chunks.
+var zeroHashes [maxDepth + 1]Root
+
+func init() {
+	for i := 1; i <= maxDepth; i++ {
+		zeroHashes[i] = hashPair(zeroHashes[i-1], zeroHashes[i-1])
+	}
+}
+
+// hashPair returns SHA-256(a || b).
+func hashPair(a, b Root) Root {
+	var buf [64]byte
+	copy(buf[:32], a[:])
+	copy(buf[32:], b[:])
+	return sha256.Sum256(buf[:])
+}
+
+// Pack splits b into 32-byte chunks, zero-padding the last one.
+func Pack(b []byte) []Root {
+	chunks := make([]Root, (len(b)+31)/32)
+	for i := range chunks {
+		copy(chunks[i][:], b[i*32:])
+	}
+	return chunks
+}
+
+// Merkleize returns the root of chunks padded to the next power of two of limit
+// chunks (of len(chunks) if limit is 0).
+func Merkleize(chunks []Root, limit int) Root {
+	size := limit
+	if size == 0 || size < len(chunks) {
+		size = len(chunks)
+	}
+	depth := 0
+	for 1<<depth < size {
+		depth++
+	}
+	if len(chunks) == 0 {
+		return zeroHashes[depth]
+	}
+	layer := append([]Root{}, chunks...)
+	for d := 0; d < depth; d++ {
+		if len(layer)%2 == 1 {
+			layer = append(layer, zeroHashes[d])
+		}
+		next := make([]Root, len(layer)/2)
+		for i := range next {
+			next[i] = hashPair(layer[2*i], layer[2*i+1])
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+// MixInLength combines a list's root with its length.
+func MixInLength(root Root, length int) Root {
+	var l Root
+	binary.LittleEndian.PutUint64(l[:], uint64(length))
+	return hashPair(root, l)
+}
+
+// Uint64Root returns the hash tree root of a uint64.
+func Uint64Root(v uint64) Root {
+	var r Root
+	binary.LittleEndian.PutUint64(r[:], v)
+	return r
+}
+
+// BoolRoot returns the hash tree root of a boolean.
+func BoolRoot(v bool) Root {
+	var r Root
+	if v {
+		r[0] = 1
+	}
+	return r
+}
+
+// FixedRoot returns the hash tree root of a byte vector; empty input stands for
+// a vector of n zero bytes.
+func FixedRoot(b []byte, n int) Root {
+	if len(b) == 0 {
+		b = make([]byte, n)
+	}
+	return Merkleize(Pack(b), 0)
+}
+
+// ByteListRoot returns the hash tree root of a byte list with the given limit.
+func ByteListRoot(b []byte, limit int) Root {
+	return MixInLength(Merkleize(Pack(b), (limit+31)/32), len(b))
+}
+
+// ListRoot returns the hash tree root of a list of composite elements given
+// their roots and the list limit.
+func ListRoot(roots []Root, limit int) Root {
+	return MixInLength(Merkleize(roots, limit), len(roots))
+}
+
+// ContainerRoot returns the hash tree root of a container given its field roots.
+func ContainerRoot(fields ...Root) Root {
+	return Merkleize(fields, 0)
+}
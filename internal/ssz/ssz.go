@@ -0,0 +1,300 @@
+package ssz
+
+// ssz.go: Simple Serialize (SSZ) encoding
+// Containers are a fixed-size part followed by a variable-size part; variable
+// fields are referenced from the fixed part by 4-byte little-endian offsets.
+// Integers are little-endian.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// BytesPerLengthOffset is the size of an offset into the variable-size part.
+const BytesPerLengthOffset = 4
+
+var (
+	// ErrShortBuffer is returned when decoding runs past the end of the input.
+	ErrShortBuffer = errors.New("ssz: unexpected end of input")
+	// ErrBadOffset is returned for offsets that are out of range or decreasing.
+	ErrBadOffset = errors.New("ssz: bad offset")
+	// ErrTooLong is returned when a list exceeds its limit.
+	ErrTooLong = errors.New("ssz: list exceeds limit")
+	// ErrBadSize is returned for fixed-size values of the wrong length.
+	ErrBadSize = errors.New("ssz: wrong size")
+)
+
+// Encoder serializes the fields of one container in declaration order.
+type Encoder struct {
+	fixed    []byte
+	variable [][]byte
+	offsets  []int // Positions in fixed of the offset placeholders
+	err      error
+}
+
+// Uint64 appends a uint64 field.
+func (e *Encoder) Uint64(v uint64) {
+	e.fixed = binary.LittleEndian.AppendUint64(e.fixed, v)
+}
+
+// Bool appends a boolean field.
+func (e *Encoder) Bool(v bool) {
+	if v {
+		e.fixed = append(e.fixed, 1)
+	} else {
+		e.fixed = append(e.fixed, 0)
+	}
+}
+
+// Fixed appends a fixed-size byte vector of length n; an empty b is written as
+// n zero bytes.
+func (e *Encoder) Fixed(b []byte, n int) {
+	switch len(b) {
+	case n:
+		e.fixed = append(e.fixed, b...)
+	case 0:
+		e.fixed = append(e.fixed, make([]byte, n)...)
+	default:
+		e.fail(fmt.Errorf("%w: got %d bytes, want %d", ErrBadSize, len(b), n))
+	}
+}
+
+// Bytes32 appends a 32-byte vector such as a hash.
+func (e *Encoder) Bytes32(b []byte) {
+	e.Fixed(b, 32)
+}
+
+// Container appends an already encoded fixed-size container inline.
+func (e *Encoder) Container(b []byte) {
+	e.fixed = append(e.fixed, b...)
+}
+
+// Variable appends an already encoded variable-size value by offset.
+func (e *Encoder) Variable(b []byte) {
+	e.offsets = append(e.offsets, len(e.fixed))
+	e.fixed = append(e.fixed, make([]byte, BytesPerLengthOffset)...)
+	e.variable = append(e.variable, b)
+}
+
+// ByteList appends a variable-length byte list of at most limit bytes.
+func (e *Encoder) ByteList(b []byte, limit int) {
+	if len(b) > limit {
+		e.fail(fmt.Errorf("%w: %d bytes, limit %d", ErrTooLong, len(b), limit))
+	}
+	e.Variable(b)
+}
+
+// List appends a list of already encoded elements of at most limit entries.
+// Fixed-size elements are concatenated; variable-size ones are preceded by an
+// offset table.
+func (e *Encoder) List(elems [][]byte, limit int, variableSize bool) {
+	if len(elems) > limit {
+		e.fail(fmt.Errorf("%w: %d elements, limit %d", ErrTooLong, len(elems), limit))
+	}
+	e.Variable(EncodeList(elems, variableSize))
+}
+
+// Finish patches the offsets and returns the encoded container.
+func (e *Encoder) Finish() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	out := append([]byte{}, e.fixed...)
+	for i, v := range e.variable {
+		binary.LittleEndian.PutUint32(out[e.offsets[i]:], uint32(len(out)))
+		out = append(out, v...)
+	}
+	return out, nil
+}
+
+func (e *Encoder) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// EncodeList serializes list elements that are already encoded.
+func EncodeList(elems [][]byte, variableSize bool) []byte {
+	var out []byte
+	if variableSize {
+		offset := len(elems) * BytesPerLengthOffset
+		for _, el := range elems {
+			out = binary.LittleEndian.AppendUint32(out, uint32(offset))
+			offset += len(el)
+		}
+	}
+	for _, el := range elems {
+		out = append(out, el...)
+	}
+	return out
+}
+
+// Decoder reads the fields of one container in declaration order. Fixed-size
+// fields are returned immediately; variable-size fields are delivered to their
+// callbacks by Finish, once every offset is known.
+type Decoder struct {
+	buf  []byte
+	pos  int
+	vars []variableField
+	err  error
+}
+
+// variableField is a pending variable-size field.
+type variableField struct {
+	offset int
+	set    func([]byte) error
+}
+
+// NewDecoder creates a decoder over an encoded container.
+func NewDecoder(buf []byte) *Decoder {
+	return &Decoder{buf: buf}
+}
+
+// next returns the following n bytes of the fixed-size part.
+func (d *Decoder) next(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	if len(d.buf)-d.pos < n {
+		d.err = ErrShortBuffer
+		return make([]byte, n)
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+// Uint64 reads a uint64 field.
+func (d *Decoder) Uint64() uint64 {
+	return binary.LittleEndian.Uint64(d.next(8))
+}
+
+// Bool reads a boolean field.
+func (d *Decoder) Bool() bool {
+	b := d.next(1)[0]
+	if b > 1 && d.err == nil {
+		d.err = fmt.Errorf("%w: boolean %d", ErrBadSize, b)
+	}
+	return b == 1
+}
+
+// Fixed reads a byte vector of length n.
+func (d *Decoder) Fixed(n int) []byte {
+	return append([]byte{}, d.next(n)...)
+}
+
+// Bytes32 reads a 32-byte vector.
+func (d *Decoder) Bytes32() []byte {
+	return d.Fixed(32)
+}
+
+// Variable reads an offset and arranges for set to receive the field's bytes.
+func (d *Decoder) Variable(set func([]byte) error) {
+	offset := int(binary.LittleEndian.Uint32(d.next(BytesPerLengthOffset)))
+	d.vars = append(d.vars, variableField{offset: offset, set: set})
+}
+
+// ByteList reads a byte list of at most limit bytes into dst; an empty list
+// leaves dst nil.
+func (d *Decoder) ByteList(dst *[]byte, limit int) {
+	d.Variable(func(b []byte) error {
+		if len(b) > limit {
+			return fmt.Errorf("%w: %d bytes, limit %d", ErrTooLong, len(b), limit)
+		}
+		*dst = nil
+		if len(b) > 0 {
+			*dst = append([]byte{}, b...)
+		}
+		return nil
+	})
+}
+
+// List reads a list of at most limit elements, passing each encoded element to
+// set. elemSize is the size of a fixed-size element, or 0 for variable-size ones.
+func (d *Decoder) List(limit, elemSize int, set func(i int, b []byte) error) {
+	d.Variable(func(b []byte) error {
+		elems, err := DecodeList(b, elemSize)
+		if err != nil {
+			return err
+		}
+		if len(elems) > limit {
+			return fmt.Errorf("%w: %d elements, limit %d", ErrTooLong, len(elems), limit)
+		}
+		for i, el := range elems {
+			if err := set(i, el); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Finish checks the offsets, delivers the variable-size fields and reports the
+// first error.
+func (d *Decoder) Finish() error {
+	if d.err != nil {
+		return d.err
+	}
+	if len(d.vars) == 0 {
+		if d.pos != len(d.buf) {
+			return fmt.Errorf("%w: %d trailing bytes", ErrBadOffset, len(d.buf)-d.pos)
+		}
+		return nil
+	}
+	if d.vars[0].offset != d.pos {
+		return fmt.Errorf("%w: first offset %d, fixed part ends at %d", ErrBadOffset, d.vars[0].offset, d.pos)
+	}
+	for i, v := range d.vars {
+		end := len(d.buf)
+		if i+1 < len(d.vars) {
+			end = d.vars[i+1].offset
+		}
+		if v.offset > end || end > len(d.buf) {
+			return fmt.Errorf("%w: %d..%d of %d", ErrBadOffset, v.offset, end, len(d.buf))
+		}
+		if err := v.set(d.buf[v.offset:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeList splits an encoded list into its elements. elemSize is the size of
+// a fixed-size element, or 0 for variable-size elements behind an offset table.
+func DecodeList(b []byte, elemSize int) ([][]byte, error) {
+	if elemSize > 0 {
+		if len(b)%elemSize != 0 {
+			return nil, fmt.Errorf("%w: %d bytes is not a multiple of %d", ErrBadSize, len(b), elemSize)
+		}
+		elems := make([][]byte, 0, len(b)/elemSize)
+		for i := 0; i < len(b); i += elemSize {
+			elems = append(elems, b[i:i+elemSize])
+		}
+		return elems, nil
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+	if len(b) < BytesPerLengthOffset {
+		return nil, ErrShortBuffer
+	}
+	first := int(binary.LittleEndian.Uint32(b))
+	if first%BytesPerLengthOffset != 0 || first == 0 || first > len(b) {
+		return nil, fmt.Errorf("%w: first offset %d", ErrBadOffset, first)
+	}
+	n := first / BytesPerLengthOffset
+	elems := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		start := int(binary.LittleEndian.Uint32(b[i*BytesPerLengthOffset:]))
+		end := len(b)
+		if i+1 < n {
+			end = int(binary.LittleEndian.Uint32(b[(i+1)*BytesPerLengthOffset:]))
+		}
+		if start < first || start > end || end > len(b) {
+			return nil, fmt.Errorf("%w: element %d at %d..%d", ErrBadOffset, i, start, end)
+		}
+		elems[i] = b[start:end]
+	}
+	return elems, nil
+}
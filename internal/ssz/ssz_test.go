@@ -0,0 +1,192 @@
+package ssz
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// unhex decodes a hex test vector.
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// encode serializes a container with fields written by write.
+func encode(t *testing.T, write func(e *Encoder)) []byte {
+	t.Helper()
+	var e Encoder
+	write(&e)
+	out, err := e.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestEncodeVectors(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(e *Encoder)
+		want  string
+	}{
+		{"uint64", func(e *Encoder) { e.Uint64(0x0123456789abcdef) }, "efcdab8967452301"},
+		{"uint64 zero", func(e *Encoder) { e.Uint64(0) }, "0000000000000000"},
+		{"bool true", func(e *Encoder) { e.Bool(true) }, "01"},
+		{"bool false", func(e *Encoder) { e.Bool(false) }, "00"},
+		{"empty vector", func(e *Encoder) { e.Fixed(nil, 4) }, "00000000"},
+		{"vector", func(e *Encoder) { e.Fixed([]byte{1, 2, 3, 4}, 4) }, "01020304"},
+		// Container{a: uint64, b: List[byte, 8], c: uint64}: the offset of b
+		// is the size of the fixed part, 8+4+8 = 20
+		{"container", func(e *Encoder) {
+			e.Uint64(1)
+			e.ByteList([]byte("abc"), 8)
+			e.Uint64(2)
+		}, "0100000000000000" + "14000000" + "0200000000000000" + "616263"},
+		{"container with two lists", func(e *Encoder) {
+			e.ByteList([]byte{0xaa}, 8)
+			e.ByteList([]byte{0xbb, 0xcc}, 8)
+		}, "08000000" + "09000000" + "aa" + "bbcc"},
+		{"fixed-size list", func(e *Encoder) {
+			e.List([][]byte{{1, 0}, {2, 0}, {3, 0}}, 4, false)
+		}, "04000000" + "010002000300"},
+		// List[List[byte, 4], 4] of [[1, 2], [3]]: an offset table, then the elements
+		{"variable-size list", func(e *Encoder) {
+			e.List([][]byte{{1, 2}, {3}}, 4, true)
+		}, "04000000" + "08000000" + "0a000000" + "0102" + "03"},
+		{"empty list", func(e *Encoder) { e.List(nil, 4, true) }, "04000000"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(encode(t, tt.write)); got != tt.want {
+			t.Errorf("%s: encoded %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeLimits(t *testing.T) {
+	var e Encoder
+	e.ByteList([]byte("too long"), 4)
+	if _, err := e.Finish(); !errors.Is(err, ErrTooLong) {
+		t.Fatalf("byte list over its limit: %v", err)
+	}
+	e = Encoder{}
+	e.List([][]byte{{1}, {2}, {3}}, 2, false)
+	if _, err := e.Finish(); !errors.Is(err, ErrTooLong) {
+		t.Fatalf("list over its limit: %v", err)
+	}
+	e = Encoder{}
+	e.Fixed([]byte{1, 2, 3}, 4)
+	if _, err := e.Finish(); !errors.Is(err, ErrBadSize) {
+		t.Fatalf("vector of the wrong size: %v", err)
+	}
+}
+
+func TestDecodeContainer(t *testing.T) {
+	buf := unhex(t, "0100000000000000"+"14000000"+"0200000000000000"+"616263")
+	d := NewDecoder(buf)
+	var b []byte
+	a := d.Uint64()
+	d.ByteList(&b, 8)
+	c := d.Uint64()
+	if err := d.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	if a != 1 || c != 2 || string(b) != "abc" {
+		t.Fatalf("decoded a=%d b=%q c=%d", a, b, c)
+	}
+
+	d = NewDecoder(unhex(t, "04000000"+"08000000"+"0a000000"+"0102"+"03"))
+	var elems [][]byte
+	d.List(4, 0, func(_ int, el []byte) error {
+		elems = append(elems, append([]byte{}, el...))
+		return nil
+	})
+	if err := d.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	if len(elems) != 2 || !bytes.Equal(elems[0], []byte{1, 2}) || !bytes.Equal(elems[1], []byte{3}) {
+		t.Fatalf("decoded list %x", elems)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  string
+		read func(d *Decoder)
+		want error
+	}{
+		{"short uint64", "01020304", func(d *Decoder) { d.Uint64() }, ErrShortBuffer},
+		{"trailing bytes", "010000000000000000", func(d *Decoder) { d.Uint64() }, ErrBadOffset},
+		{"bool out of range", "02", func(d *Decoder) { d.Bool() }, ErrBadSize},
+		{"first offset inside the fixed part", "0100000000000000" + "08000000" + "aa",
+			func(d *Decoder) {
+				var b []byte
+				d.Uint64()
+				d.ByteList(&b, 8)
+			}, ErrBadOffset},
+		{"offset past the end", "08000000" + "20000000" + "aa",
+			func(d *Decoder) {
+				var a, b []byte
+				d.ByteList(&a, 8)
+				d.ByteList(&b, 8)
+			}, ErrBadOffset},
+		{"decreasing offsets", "08000000" + "07000000" + "aabb",
+			func(d *Decoder) {
+				var a, b []byte
+				d.ByteList(&a, 8)
+				d.ByteList(&b, 8)
+			}, ErrBadOffset},
+		{"byte list over its limit", "04000000" + "0102030405",
+			func(d *Decoder) {
+				var b []byte
+				d.ByteList(&b, 4)
+			}, ErrTooLong},
+		{"fixed-size list of a partial element", "04000000" + "010002",
+			func(d *Decoder) { d.List(4, 2, func(int, []byte) error { return nil }) }, ErrBadSize},
+		{"list over its limit", "04000000" + "010002000300",
+			func(d *Decoder) { d.List(2, 2, func(int, []byte) error { return nil }) }, ErrTooLong},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(unhex(t, tt.buf))
+		tt.read(d)
+		if err := d.Finish(); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestHashTreeRootVectors(t *testing.T) {
+	tests := []struct {
+		name string
+		root Root
+		want string
+	}{
+		{"uint64", Uint64Root(0x0123456789abcdef), "efcdab8967452301000000000000000000000000000000000000000000000000"},
+		{"bool", BoolRoot(true), "0100000000000000000000000000000000000000000000000000000000000000"},
+		// The zero hashes of depth 1 and 2
+		{"two zero chunks", Merkleize(nil, 2), "f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a92759fb4b"},
+		{"four zero chunks", Merkleize(nil, 4), "db56114e00fdd4c1f85c892bf35ac9a89289aaecb1ebd0a96cde606a748b5d71"},
+		{"one chunk", Merkleize([]Root{Uint64Root(7)}, 0), "0700000000000000000000000000000000000000000000000000000000000000"},
+		{"vector of 40 bytes", FixedRoot(unhex(t, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f2021222324252627"), 40),
+			"6032bb14a2dc38d055bb806a766a1082c6c56d2b2662c42eabce7c25a3cf157d"},
+		{"empty byte list", ByteListRoot(nil, 32), "f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a92759fb4b"},
+		{"byte list", ByteListRoot([]byte("abc"), 64), "c7c0ac71800bb78b78b0e0ec50dfc566bcc185af510119ec70c5b6afb89f9829"},
+		{"list", ListRoot([]Root{Uint64Root(1), Uint64Root(2)}, 4), "194bad49fedb07a784ee3d70ba18ca61b31e3fa35b16b7f7ae42973d1a67f779"},
+		// Three fields are padded with a zero chunk to a tree of four
+		{"container", ContainerRoot(Uint64Root(1), Uint64Root(2), Uint64Root(3)), "66c419026fee8793be7fd0011b9db46b98a79f9c9b640e25317865c358f442db"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(tt.root[:]); got != tt.want {
+			t.Errorf("%s: root %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if FixedRoot(nil, 32) != FixedRoot(make([]byte, 32), 32) {
+		t.Error("empty vector and zero vector have different roots")
+	}
+}
@@ -0,0 +1,396 @@
+package store
+
+// blockstore.go: Segmented append-only block file with a hash/height index
+// Blocks are appended as checksummed SSZ records to numbered segment files. The
+// index is rebuilt by scanning the segments on open; a torn record at the tail
+// of the last segment is cut off so the store resumes from the last good block,
+// while a bad record anywhere else fails the open.
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+var (
+	// ErrNotFound is returned for a block that is not in the store.
+	ErrNotFound = errors.New("block not found")
+	// ErrCorrupt is returned when a segment other than the last one fails its checks.
+	ErrCorrupt = errors.New("corrupt block segment")
+	// ErrClosed is returned when using a closed store.
+	ErrClosed = errors.New("block store closed")
+)
+
+// SyncMode controls when appended blocks are flushed to stable storage.
+type SyncMode int
+
+const (
+	// SyncAlways fsyncs the segment after every append.
+	SyncAlways SyncMode = iota
+	// SyncNone leaves flushing to the operating system and Sync/Close calls.
+	SyncNone
+)
+
+// Options configures a block store.
+type Options struct {
+	MaxSegmentSize int64 // Size after which a new segment is started
+	Sync           SyncMode
+}
+
+// DefaultOptions syncs every block and rolls segments at 64 MiB.
+var DefaultOptions = Options{MaxSegmentSize: 64 << 20, Sync: SyncAlways}
+
+// recordHeaderSize is the length and CRC-32C prefix of each record.
+const recordHeaderSize = 8
+
+// maxRecordSize bounds a record's declared length when scanning.
+const maxRecordSize = 1 << 30
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// location is where a block's record lives.
+type location struct {
+	segment int
+	offset  int64
+	size    int
+}
+
+// BlockStore is a persistent append-only store of blocks.
+type BlockStore struct {
+	mu       sync.RWMutex
+	dir      string
+	opts     Options
+	segments []*os.File // Open segment files, the last one is appended to
+	tailSize int64      // Size of the last segment
+	byHash   map[string]location
+	byHeight map[int][]string // Hashes of the stored blocks at each height
+	order    []string         // Hashes in append order
+	closed   bool
+}
+
+// Open opens or creates the block store in dir and rebuilds its index.
+func Open(dir string, opts Options) (*BlockStore, error) {
+	if opts.MaxSegmentSize <= 0 {
+		opts.MaxSegmentSize = DefaultOptions.MaxSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &BlockStore{
+		dir:      dir,
+		opts:     opts,
+		byHash:   make(map[string]location),
+		byHeight: make(map[int][]string),
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "segment-*.dat"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if name != segmentPath(dir, i) {
+			s.closeFiles()
+			return nil, fmt.Errorf("%w: unexpected segment %s", ErrCorrupt, name)
+		}
+		if err := s.loadSegment(i, i == len(names)-1); err != nil {
+			s.closeFiles()
+			return nil, err
+		}
+	}
+	if len(s.segments) == 0 {
+		if err := s.newSegment(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// segmentPath returns the file name of segment n.
+func segmentPath(dir string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("segment-%06d.dat", n))
+}
+
+// loadSegment indexes the records of segment n. A torn write at the end of the
+// last segment is truncated to the last good record; any other bad record is
+// reported as ErrCorrupt, so good blocks after it are never dropped.
+func (s *BlockStore) loadSegment(n int, last bool) error {
+	flag := os.O_RDONLY
+	if last {
+		flag = os.O_RDWR
+	}
+	f, err := os.OpenFile(segmentPath(s.dir, n), flag, 0o644)
+	if err != nil {
+		return err
+	}
+	s.segments = append(s.segments, f)
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	var off int64
+	for off < int64(len(data)) {
+		block, size, err := decodeRecord(data[off:])
+		if err != nil {
+			if !last || !tornTail(data[off:], size, err) {
+				return fmt.Errorf("%w: segment %d at offset %d: %v", ErrCorrupt, n, off, err)
+			}
+			// Torn write at the tail: drop it
+			if err := f.Truncate(off); err != nil {
+				return err
+			}
+			if err := f.Sync(); err != nil {
+				return err
+			}
+			break
+		}
+		s.index(block, location{segment: n, offset: off, size: size})
+		off += int64(size)
+	}
+	if last {
+		s.tailSize = off
+	}
+	return nil
+}
+
+// tornTail reports whether the bad record at the start of data, of the given
+// size (0 if unknown) and failing with err, is an interrupted append: one that
+// runs past the end of the data, is the last record, or is followed only by
+// the zeros a crash can leave in a file extended but not written.
+func tornTail(data []byte, size int, err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || size == len(data) {
+		return true
+	}
+	for _, c := range data {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeRecord parses the record at the start of data and returns the block
+// and the record's total size. A record running past the end of data fails
+// with io.ErrUnexpectedEOF; a record that fails its checksum or does not
+// decode still returns its size.
+func decodeRecord(data []byte) (*blockchain.Block, int, error) {
+	if len(data) < recordHeaderSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	n := binary.LittleEndian.Uint32(data)
+	if n > maxRecordSize {
+		return nil, 0, fmt.Errorf("record length %d exceeds %d", n, maxRecordSize)
+	}
+	if int64(n) > int64(len(data)-recordHeaderSize) {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	size := recordHeaderSize + int(n)
+	payload := data[recordHeaderSize:size]
+	if crc32.Checksum(payload, castagnoli) != binary.LittleEndian.Uint32(data[4:]) {
+		return nil, size, errors.New("checksum mismatch")
+	}
+	block := new(blockchain.Block)
+	if err := block.UnmarshalSSZ(payload); err != nil {
+		return nil, size, err
+	}
+	return block, size, nil
+}
+
+// index records a block's location; s.mu must be held for writing.
+func (s *BlockStore) index(b *blockchain.Block, loc location) {
+	s.byHash[b.Hash] = loc
+	s.byHeight[b.Index] = append(s.byHeight[b.Index], b.Hash)
+	s.order = append(s.order, b.Hash)
+}
+
+// newSegment starts a new segment file for appends.
+func (s *BlockStore) newSegment() error {
+	f, err := os.OpenFile(segmentPath(s.dir, len(s.segments)), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if n := len(s.segments); n > 0 {
+		// The previous tail is now read-only and complete
+		if err := s.segments[n-1].Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	s.segments = append(s.segments, f)
+	s.tailSize = 0
+	return syncDir(s.dir)
+}
+
+// Append stores a block. Blocks already in the store are ignored.
+func (s *BlockStore) Append(b *blockchain.Block) error {
+	payload, err := b.MarshalSSZ()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	if _, ok := s.byHash[b.Hash]; ok {
+		return nil
+	}
+	rec := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(rec, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(rec[4:], crc32.Checksum(payload, castagnoli))
+	rec = append(rec, payload...)
+
+	if s.tailSize > 0 && s.tailSize+int64(len(rec)) > s.opts.MaxSegmentSize {
+		if err := s.newSegment(); err != nil {
+			return err
+		}
+	}
+	seg := len(s.segments) - 1
+	f := s.segments[seg]
+	if _, err := f.WriteAt(rec, s.tailSize); err != nil {
+		// Cut off whatever part of the record made it to the file
+		f.Truncate(s.tailSize)
+		return err
+	}
+	if s.opts.Sync == SyncAlways {
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	s.index(b, location{segment: seg, offset: s.tailSize, size: len(rec)})
+	s.tailSize += int64(len(rec))
+	return nil
+}
+
+// Has reports whether the block with hash is stored.
+func (s *BlockStore) Has(hash string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.byHash[hash]
+	return ok
+}
+
+// Get reads the block with the given hash.
+func (s *BlockStore) Get(hash string) (*blockchain.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.get(hash)
+}
+
+// get reads a block; s.mu must be held.
+func (s *BlockStore) get(hash string) (*blockchain.Block, error) {
+	if s.closed {
+		return nil, ErrClosed
+	}
+	loc, ok := s.byHash[hash]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, hash)
+	}
+	data := make([]byte, loc.size)
+	if _, err := s.segments[loc.segment].ReadAt(data, loc.offset); err != nil {
+		return nil, err
+	}
+	block, _, err := decodeRecord(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: segment %d at offset %d: %v", ErrCorrupt, loc.segment, loc.offset, err)
+	}
+	return block, nil
+}
+
+// GetByHeight reads every stored block at height, in append order.
+func (s *BlockStore) GetByHeight(height int) ([]*blockchain.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var blocks []*blockchain.Block
+	for _, hash := range s.byHeight[height] {
+		b, err := s.get(hash)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
+
+// Dir returns the directory of the store.
+func (s *BlockStore) Dir() string {
+	return s.dir
+}
+
+// Len returns the number of stored blocks.
+func (s *BlockStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.order)
+}
+
+// Iterate calls fn with every stored block in append order, so parents come
+// before their children. It stops at the first error fn returns.
+func (s *BlockStore) Iterate(fn func(*blockchain.Block) error) error {
+	s.mu.RLock()
+	order := append([]string(nil), s.order...)
+	s.mu.RUnlock()
+	for _, hash := range order {
+		b, err := s.Get(hash)
+		if err != nil {
+			return err
+		}
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sync flushes the segment being appended to.
+func (s *BlockStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return s.segments[len(s.segments)-1].Sync()
+}
+
+// Close flushes and closes the store.
+func (s *BlockStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	err := s.segments[len(s.segments)-1].Sync()
+	if cerr := s.closeFiles(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// closeFiles closes every open segment.
+func (s *BlockStore) closeFiles() error {
+	var err error
+	for _, f := range s.segments {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// syncDir fsyncs a directory so newly created files survive a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
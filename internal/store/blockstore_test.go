@@ -0,0 +1,104 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+// storeBlocks appends a chain of n empty blocks to a new store in dir and
+// returns the offset of each record and the segment size.
+func storeBlocks(t *testing.T, dir string, n int) ([]int64, int64) {
+	t.Helper()
+	s, err := Open(dir, Options{Sync: SyncNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var parent *blockchain.Block
+	for i := 0; i < n; i++ {
+		b := blockchain.NewBlock(0, "", nil)
+		if parent != nil {
+			b = blockchain.NewBlock(i, parent.Hash, nil)
+			b.MMRRoot = parent.NextMMRRoot()
+		}
+		b.Timestamp = time.Date(2024, 1, 1, 0, 0, i, 0, time.UTC)
+		b.Seal()
+		if err := s.Append(b); err != nil {
+			t.Fatal(err)
+		}
+		parent = b
+	}
+	var offsets []int64
+	for _, hash := range s.order {
+		offsets = append(offsets, s.byHash[hash].offset)
+	}
+	return offsets, s.tailSize
+}
+
+// damage applies fn to the contents of the first segment in dir.
+func damage(t *testing.T, dir string, fn func(data []byte) []byte) {
+	t.Helper()
+	path := segmentPath(dir, 0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, fn(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenTruncatesTornTail(t *testing.T) {
+	for name, tc := range map[string]struct {
+		tear func(data []byte, offsets []int64) []byte
+		kept int
+	}{
+		"partial record": {func(data []byte, offsets []int64) []byte {
+			return append(data, data[offsets[1]:offsets[1]+20]...)
+		}, 3},
+		"zero filled": {func(data []byte, _ []int64) []byte {
+			return append(data, make([]byte, 512)...)
+		}, 3},
+		"last record checksum": {func(data []byte, _ []int64) []byte {
+			data[len(data)-1] ^= 0xff
+			return data
+		}, 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			offsets, _ := storeBlocks(t, dir, 3)
+			damage(t, dir, func(data []byte) []byte { return tc.tear(data, offsets) })
+			s, err := Open(dir, Options{Sync: SyncNone})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if s.Len() != tc.kept {
+				t.Fatalf("store holds %d blocks, want %d", s.Len(), tc.kept)
+			}
+			if fi, _ := os.Stat(segmentPath(dir, 0)); fi.Size() != s.tailSize {
+				t.Fatalf("segment is %d bytes after recovery, want %d", fi.Size(), s.tailSize)
+			}
+		})
+	}
+}
+
+func TestOpenRejectsCorruptionBeforeTail(t *testing.T) {
+	dir := t.TempDir()
+	offsets, size := storeBlocks(t, dir, 3)
+	// A flipped bit in the middle record's payload, with a good record after it
+	damage(t, dir, func(data []byte) []byte {
+		data[offsets[1]+recordHeaderSize+10] ^= 1
+		return data
+	})
+	if _, err := Open(dir, Options{Sync: SyncNone}); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Open: %v, want ErrCorrupt", err)
+	}
+	if fi, _ := os.Stat(segmentPath(dir, 0)); fi.Size() != size {
+		t.Fatalf("segment cut to %d bytes, want all %d kept", fi.Size(), size)
+	}
+}
@@ -0,0 +1,200 @@
+package store
+
+// checkpoints.go: Prune checkpoints and archived state history
+// Every node keeps the checkpoints it pruned history into. An archive node also
+// keeps the pruned entries, so it can prove a historical value to a pruned node,
+// which checks the proof against its own copy of the checkpoint.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+var (
+	// ErrNoCheckpoint is returned when no checkpoint covers a height.
+	ErrNoCheckpoint = errors.New("prune checkpoint not found")
+	// ErrNotArchived is returned when the entries of a checkpoint were not kept.
+	ErrNotArchived = errors.New("pruned state history not archived")
+	// ErrNoEntry is returned when the archived history holds no entry for a key.
+	ErrNoEntry = errors.New("no pruned state entry for key")
+)
+
+// checkpointIndexFile is the name of the index within the checkpoint directory.
+const checkpointIndexFile = "checkpoints.json"
+
+// CheckpointStore keeps prune checkpoints and, in archive mode, the entries
+// committed to by each.
+type CheckpointStore struct {
+	mu          sync.Mutex
+	dir         string
+	archive     bool
+	checkpoints []blockchain.PruneCheckpoint // Sorted by height
+}
+
+// OpenCheckpoints opens or creates the checkpoint store in dir. If archive is
+// set, pruned entries are kept alongside their checkpoints.
+func OpenCheckpoints(dir string, archive bool) (*CheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &CheckpointStore{dir: dir, archive: archive}
+	data, err := os.ReadFile(filepath.Join(dir, checkpointIndexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.checkpoints); err != nil {
+		return nil, fmt.Errorf("checkpoint index: %w", err)
+	}
+	sort.Slice(c.checkpoints, func(i, j int) bool { return c.checkpoints[i].ToHeight < c.checkpoints[j].ToHeight })
+	return c, nil
+}
+
+// Archive reports whether the store keeps pruned entries.
+func (c *CheckpointStore) Archive() bool {
+	return c.archive
+}
+
+// Add records a checkpoint and, in archive mode, the entries it commits to.
+// The entries must match the checkpoint root.
+func (c *CheckpointStore) Add(cp *blockchain.PruneCheckpoint, entries []blockchain.StateEntry) error {
+	if len(entries) != cp.Count {
+		return fmt.Errorf("%w: %d entries for checkpoint of %d", blockchain.ErrBadEntryProof, len(entries), cp.Count)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.archive {
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(c.entriesPath(cp.ToHeight), data, 0o644); err != nil {
+			return err
+		}
+	}
+	c.checkpoints = append(c.checkpoints, *cp)
+	return c.writeIndex()
+}
+
+// List returns every checkpoint, oldest first.
+func (c *CheckpointStore) List() []blockchain.PruneCheckpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]blockchain.PruneCheckpoint(nil), c.checkpoints...)
+}
+
+// Covering returns the checkpoint whose range includes height.
+func (c *CheckpointStore) Covering(height int) (blockchain.PruneCheckpoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cp := range c.checkpoints {
+		if cp.FromHeight <= height && height <= cp.ToHeight {
+			return cp, nil
+		}
+	}
+	return blockchain.PruneCheckpoint{}, fmt.Errorf("%w: height %d", ErrNoCheckpoint, height)
+}
+
+// Entries returns the archived entries of the checkpoint ending at toHeight.
+func (c *CheckpointStore) Entries(toHeight int) ([]blockchain.StateEntry, error) {
+	if !c.archive {
+		return nil, ErrNotArchived
+	}
+	data, err := os.ReadFile(c.entriesPath(toHeight))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: checkpoint %d", ErrNotArchived, toHeight)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []blockchain.StateEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("checkpoint %d entries: %w", toHeight, err)
+	}
+	return entries, nil
+}
+
+// ProveEntry finds the pruned entry for key overwritten at height and proves it
+// against the covering checkpoint. Only an archive store can serve proofs.
+func (c *CheckpointStore) ProveEntry(key string, height int) (*blockchain.EntryProof, error) {
+	cp, err := c.Covering(height)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := c.Entries(cp.ToHeight)
+	if err != nil {
+		return nil, err
+	}
+	// The first change of the key in that block holds its value before the block
+	for i, e := range entries {
+		if e.Height == height && e.Key == key {
+			return blockchain.ProveEntry(entries, i)
+		}
+	}
+	return nil, fmt.Errorf("%w: %q at height %d", ErrNoEntry, key, height)
+}
+
+// VerifyEntry checks an entry proof against the stored checkpoint covering it.
+func (c *CheckpointStore) VerifyEntry(p *blockchain.EntryProof) error {
+	cp, err := c.Covering(p.Entry.Height)
+	if err != nil {
+		return err
+	}
+	return cp.VerifyEntry(p)
+}
+
+// EntrySource serves proofs of pruned state entries, as an archive does.
+type EntrySource interface {
+	// ProveEntry proves the pruned entry for key overwritten at height.
+	ProveEntry(key string, height int) (*blockchain.EntryProof, error)
+}
+
+// FetchEntry asks src for the pruned entry for key overwritten at height and
+// checks its proof against the stored checkpoint, so a pruned node can trust
+// an entry served by an archive it does not trust.
+func (c *CheckpointStore) FetchEntry(src EntrySource, key string, height int) (*blockchain.StateEntry, error) {
+	p, err := src.ProveEntry(key, height)
+	if err != nil {
+		return nil, err
+	}
+	if p.Entry.Key != key || p.Entry.Height != height {
+		return nil, fmt.Errorf("%w: proof is for %q at height %d", blockchain.ErrBadEntryProof, p.Entry.Key, p.Entry.Height)
+	}
+	if err := c.VerifyEntry(p); err != nil {
+		return nil, err
+	}
+	return &p.Entry, nil
+}
+
+// Last returns the most recent checkpoint, or false if there is none.
+func (c *CheckpointStore) Last() (blockchain.PruneCheckpoint, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.checkpoints) == 0 {
+		return blockchain.PruneCheckpoint{}, false
+	}
+	return c.checkpoints[len(c.checkpoints)-1], true
+}
+
+// entriesPath returns the file holding the entries of the checkpoint ending at toHeight.
+func (c *CheckpointStore) entriesPath(toHeight int) string {
+	return filepath.Join(c.dir, fmt.Sprintf("entries-%d.json", toHeight))
+}
+
+// writeIndex persists the checkpoint list; c.mu must be held.
+func (c *CheckpointStore) writeIndex() error {
+	data, err := json.MarshalIndent(c.checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(c.dir, checkpointIndexFile), data, 0o644)
+}
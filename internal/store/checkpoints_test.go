@@ -0,0 +1,129 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+// historyState returns a state whose block h credits h to each of three
+// accounts, for blocks 0 to n-1.
+func historyState(t *testing.T, n int) *blockchain.StateDB {
+	t.Helper()
+	state := blockchain.NewStateDB(amf.NewForest(), amf.DefaultRebalanceConfig)
+	for h := 0; h < n; h++ {
+		state.Journal.BeginBlock(h)
+		for a := 0; a < 3; a++ {
+			addr := fmt.Sprintf("%040x", a)
+			acct := state.GetAccount(addr)
+			acct.Balance += uint64(h)
+			if err := state.SetAccount(addr, acct); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return state
+}
+
+// entrySource serves the proofs of an archive, altered by tamper.
+type entrySource struct {
+	archive *CheckpointStore
+	tamper  func(p *blockchain.EntryProof)
+}
+
+// ProveEntry returns the archive's proof, tampered with.
+func (s entrySource) ProveEntry(key string, height int) (*blockchain.EntryProof, error) {
+	p, err := s.archive.ProveEntry(key, height)
+	if err == nil && s.tamper != nil {
+		s.tamper(p)
+	}
+	return p, err
+}
+
+func TestFetchPrunedEntry(t *testing.T) {
+	archive, err := OpenCheckpoints(t.TempDir(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := OpenCheckpoints(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := historyState(t, 10)
+	for _, height := range []int{3, 7} {
+		cp, entries, err := state.Prune(height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := archive.Add(cp, entries); err != nil {
+			t.Fatal(err)
+		}
+		if err := pruned.Add(cp, entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := pruned.ProveEntry(blockchain.AccountKey(fmt.Sprintf("%040x", 1)), 5); !errors.Is(err, ErrNotArchived) {
+		t.Fatalf("pruned store served a proof: %v", err)
+	}
+
+	// Before block 5, account 1 held 0+1+2+3+4
+	key := blockchain.AccountKey(fmt.Sprintf("%040x", 1))
+	entry, err := pruned.FetchEntry(archive, key, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acct, err := entry.Account(); err != nil || acct.Balance != 10 {
+		t.Fatalf("fetched account %+v, %v; want balance 10", acct, err)
+	}
+
+	other, err := archive.ProveEntry(blockchain.AccountKey(fmt.Sprintf("%040x", 2)), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tamper := range map[string]func(p *blockchain.EntryProof){
+		"value":    func(p *blockchain.EntryProof) { p.Entry.Value = []byte(`{"Balance":1000,"Nonce":0}`) },
+		"index":    func(p *blockchain.EntryProof) { p.Index ^= 1 },
+		"past end": func(p *blockchain.EntryProof) { p.Index += 1 << 10 },
+		"other":    func(p *blockchain.EntryProof) { *p = *other },
+	} {
+		_, err := pruned.FetchEntry(entrySource{archive, tamper}, key, 5)
+		if !errors.Is(err, blockchain.ErrBadEntryProof) {
+			t.Errorf("%s tampered: %v", name, err)
+		}
+	}
+}
+
+func TestCheckpointsResumeAfterRestart(t *testing.T) {
+	checkpoints, err := OpenCheckpoints(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, entries, err := historyState(t, 10).Prune(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkpoints.Add(cp, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart replays the whole chain; the history up to the last
+	// checkpoint is dropped without a new checkpoint
+	state := historyState(t, 10)
+	last, ok := checkpoints.Last()
+	if !ok || last.ToHeight != 4 {
+		t.Fatalf("last checkpoint %+v, %v", last, ok)
+	}
+	if _, _, err := state.Prune(last.ToHeight); err != nil {
+		t.Fatal(err)
+	}
+	next, _, err := state.Prune(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.FromHeight != last.ToHeight+1 || next.Count != 3*3 {
+		t.Fatalf("checkpoint after restart covers %d..%d with %d entries, want 5..7 with 9", next.FromHeight, next.ToHeight, next.Count)
+	}
+}
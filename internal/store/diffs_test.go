@@ -0,0 +1,75 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+func TestRebuildSkipsAbandonedSnapshot(t *testing.T) {
+	snapshots, err := OpenSnapshots(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := OpenDiffs(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "0000000000000000000000000000000000000001"
+	stateAt := func(balance uint64) *blockchain.StateDB {
+		state := blockchain.NewStateDB(amf.NewForest(), amf.DefaultRebalanceConfig)
+		if err := state.SetAccount(addr, blockchain.Account{Balance: balance}); err != nil {
+			t.Fatal(err)
+		}
+		return state
+	}
+	block := func(height int, prev string, balance uint64) *blockchain.Block {
+		b := blockchain.NewBlock(height, prev, nil)
+		b.StateRoot = stateAt(balance).Root()
+		b.Seal()
+		return b
+	}
+
+	// Block 2 holds balance 2 on the canonical branch and 20 on the abandoned
+	// one, whose snapshot was taken last
+	b1 := block(1, "", 1)
+	canonical := []*blockchain.Block{nil, b1, block(2, b1.Hash, 2), nil}
+	canonical[3] = block(3, canonical[2].Hash, 3)
+	abandoned := block(2, b1.Hash, 20)
+	if _, err := snapshots.Save(canonical[2], stateAt(2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snapshots.Save(abandoned, stateAt(20)); err != nil {
+		t.Fatal(err)
+	}
+	diff := &blockchain.StateDiff{
+		Height:    3,
+		BlockHash: canonical[3].Hash,
+		StateRoot: canonical[3].StateRoot,
+		Changes:   []blockchain.AccountChange{{Address: addr, Old: &blockchain.Account{Balance: 2}, New: &blockchain.Account{Balance: 3}}},
+	}
+	if err := diffs.Save(diff); err != nil {
+		t.Fatal(err)
+	}
+	blockAt := func(height int) (*blockchain.Block, bool) {
+		if height < 1 || height >= len(canonical) {
+			return nil, false
+		}
+		return canonical[height], true
+	}
+
+	state, err := diffs.Rebuild(snapshots, blockAt, 3, amf.DefaultRebalanceConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := state.GetAccount(addr); got.Balance != 3 {
+		t.Fatalf("rebuilt balance %d, want 3", got.Balance)
+	}
+	// With no canonical snapshot at or below the height there is nothing to
+	// rebuild from
+	if _, err := diffs.Rebuild(snapshots, blockAt, 1, amf.DefaultRebalanceConfig); !errors.Is(err, ErrNoSnapshot) {
+		t.Fatalf("Rebuild below the snapshots: %v", err)
+	}
+}
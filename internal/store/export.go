@@ -0,0 +1,156 @@
+package store
+
+// export.go: Portable chain dumps
+// A dump holds the canonical chain from the first block on, either as
+// length-prefixed SSZ records or as JSON lines. Both end with a SHA-256
+// checksum over everything before it, so a truncated or altered dump is
+// rejected as a whole before any of its blocks is used.
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+// Dump formats.
+const (
+	FormatBinary = "binary" // Magic, then length-prefixed SSZ blocks
+	FormatJSON   = "json"   // One JSON object per line
+)
+
+// dumpMagic starts a binary dump.
+const dumpMagic = "CHAINDMP"
+
+// ErrBadDump is returned for a chain dump that is malformed or fails its checksum.
+var ErrBadDump = errors.New("invalid chain dump")
+
+// dumpLine is a line of a JSON dump: a block, or the trailer with the block
+// count and the checksum of the lines before it.
+type dumpLine struct {
+	Block    *blockchain.Block `json:"block,omitempty"`
+	Blocks   int               `json:"blocks,omitempty"`
+	Checksum string            `json:"checksum,omitempty"`
+}
+
+// WriteDump writes blocks to w in format. In a binary dump each block is
+// preceded by its little-endian uint32 length, and a zero length marks the
+// 32-byte checksum.
+func WriteDump(w io.Writer, blocks []*blockchain.Block, format string) error {
+	sum := sha256.New()
+	bw := bufio.NewWriter(w)
+	out := io.MultiWriter(bw, sum)
+	switch format {
+	case FormatBinary:
+		if _, err := io.WriteString(out, dumpMagic); err != nil {
+			return err
+		}
+		var prefix [4]byte
+		for _, b := range blocks {
+			payload, err := b.MarshalSSZ()
+			if err != nil {
+				return fmt.Errorf("block %d: %w", b.Index, err)
+			}
+			binary.LittleEndian.PutUint32(prefix[:], uint32(len(payload)))
+			if _, err := out.Write(append(prefix[:], payload...)); err != nil {
+				return err
+			}
+		}
+		binary.LittleEndian.PutUint32(prefix[:], 0)
+		if _, err := bw.Write(append(prefix[:], sum.Sum(nil)...)); err != nil {
+			return err
+		}
+	case FormatJSON:
+		enc := json.NewEncoder(out)
+		for _, b := range blocks {
+			if err := enc.Encode(dumpLine{Block: b}); err != nil {
+				return fmt.Errorf("block %d: %w", b.Index, err)
+			}
+		}
+		trailer := dumpLine{Blocks: len(blocks), Checksum: hex.EncodeToString(sum.Sum(nil))}
+		if err := json.NewEncoder(bw).Encode(trailer); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown dump format %q", format)
+	}
+	return bw.Flush()
+}
+
+// ReadDump reads a dump written by WriteDump in either format and checks its
+// checksum. The blocks are decoded but not validated.
+func ReadDump(r io.Reader) ([]*blockchain.Block, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte(dumpMagic)) {
+		return readBinaryDump(data)
+	}
+	return readJSONDump(data)
+}
+
+// readBinaryDump parses a binary dump.
+func readBinaryDump(data []byte) ([]*blockchain.Block, error) {
+	var blocks []*blockchain.Block
+	off := len(dumpMagic)
+	for {
+		if len(data)-off < 4 {
+			return nil, fmt.Errorf("%w: truncated after %d blocks", ErrBadDump, len(blocks))
+		}
+		n := int(binary.LittleEndian.Uint32(data[off:]))
+		if n == 0 {
+			break
+		}
+		if n > maxRecordSize || n > len(data)-off-4 {
+			return nil, fmt.Errorf("%w: truncated after %d blocks", ErrBadDump, len(blocks))
+		}
+		b := new(blockchain.Block)
+		if err := b.UnmarshalSSZ(data[off+4 : off+4+n]); err != nil {
+			return nil, fmt.Errorf("%w: record %d: %v", ErrBadDump, len(blocks), err)
+		}
+		blocks = append(blocks, b)
+		off += 4 + n
+	}
+	sum := sha256.Sum256(data[:off])
+	if rest := data[off+4:]; !bytes.Equal(rest, sum[:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrBadDump)
+	}
+	return blocks, nil
+}
+
+// readJSONDump parses a JSON-lines dump.
+func readJSONDump(data []byte) ([]*blockchain.Block, error) {
+	var blocks []*blockchain.Block
+	for off := 0; off < len(data); {
+		end := bytes.IndexByte(data[off:], '\n')
+		if end < 0 {
+			return nil, fmt.Errorf("%w: truncated after %d blocks", ErrBadDump, len(blocks))
+		}
+		var line dumpLine
+		if err := json.Unmarshal(data[off:off+end], &line); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrBadDump, len(blocks)+1, err)
+		}
+		if line.Block != nil {
+			blocks = append(blocks, line.Block)
+			off += end + 1
+			continue
+		}
+		sum := sha256.Sum256(data[:off])
+		if line.Checksum != hex.EncodeToString(sum[:]) || line.Blocks != len(blocks) {
+			return nil, fmt.Errorf("%w: checksum mismatch", ErrBadDump)
+		}
+		if off+end+1 != len(data) {
+			return nil, fmt.Errorf("%w: data after checksum", ErrBadDump)
+		}
+		return blocks, nil
+	}
+	return nil, fmt.Errorf("%w: missing checksum", ErrBadDump)
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+// dumpBlocks returns a linked chain of n blocks, each carrying a transaction.
+func dumpBlocks(n int) []*blockchain.Block {
+	var blocks []*blockchain.Block
+	var parent *blockchain.Block
+	for i := 0; i < n; i++ {
+		tx := blockchain.NewTransaction("0000000000000000000000000000000000000001", "0000000000000000000000000000000000000002", uint64(i), 1, uint64(i), []byte("dump"))
+		b := blockchain.NewBlock(0, "", []string{tx.Encode()})
+		if parent != nil {
+			b = blockchain.NewBlock(i, parent.Hash, []string{tx.Encode()})
+		}
+		b.Timestamp = time.Date(2024, 1, 1, 0, 0, i, 0, time.UTC)
+		b.Seal()
+		blocks = append(blocks, b)
+		parent = b
+	}
+	return blocks
+}
+
+// writeDump returns blocks dumped in format.
+func writeDump(t *testing.T, blocks []*blockchain.Block, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteDump(&buf, blocks, format); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	blocks := dumpBlocks(4)
+	for _, format := range []string{FormatBinary, FormatJSON} {
+		got, err := ReadDump(bytes.NewReader(writeDump(t, blocks, format)))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(got) != len(blocks) {
+			t.Fatalf("%s: read %d blocks, want %d", format, len(got), len(blocks))
+		}
+		for i, b := range got {
+			if b.Hash != blocks[i].Hash || len(b.Transactions) != 1 || b.Transactions[0] != blocks[i].Transactions[0] {
+				t.Fatalf("%s: block %d read back as %+v", format, i, b)
+			}
+			if err := b.Verify(); err != nil {
+				t.Fatalf("%s: block %d: %v", format, i, err)
+			}
+		}
+	}
+	if got, err := ReadDump(bytes.NewReader(writeDump(t, nil, FormatJSON))); err != nil || len(got) != 0 {
+		t.Fatalf("empty dump: %d blocks, %v", len(got), err)
+	}
+	if err := WriteDump(&bytes.Buffer{}, blocks, "xml"); err == nil {
+		t.Fatal("unknown format written")
+	}
+}
+
+func TestReadDumpRejectsDamage(t *testing.T) {
+	blocks := dumpBlocks(3)
+	binary, jsonDump := writeDump(t, blocks, FormatBinary), writeDump(t, blocks, FormatJSON)
+	// The second JSON line starts after the first newline
+	secondLine := bytes.IndexByte(jsonDump, '\n') + 1
+	for name, data := range map[string][]byte{
+		"binary inside a record":    binary[:len(dumpMagic)+20],
+		"binary before the trailer": binary[:len(binary)-36],
+		"binary inside the trailer": binary[:len(binary)-5],
+		"binary flipped byte":       flip(binary, len(binary)/2),
+		"binary flipped checksum":   flip(binary, len(binary)-1),
+		"json inside a record":      jsonDump[:secondLine+10],
+		"json before the trailer":   jsonDump[:bytes.LastIndexByte(jsonDump[:len(jsonDump)-1], '\n')+1],
+		"json flipped byte":         flip(jsonDump, secondLine+len(`{"block":{"index":`)),
+		"json data after trailer":   append(append([]byte(nil), jsonDump...), jsonDump[:secondLine]...),
+	} {
+		if _, err := ReadDump(bytes.NewReader(data)); !errors.Is(err, ErrBadDump) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// flip returns a copy of data with the byte at i changed.
+func flip(data []byte, i int) []byte {
+	out := append([]byte(nil), data...)
+	out[i] ^= 1
+	return out
+}
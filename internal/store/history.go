@@ -0,0 +1,101 @@
+package store
+
+// history.go: Historical state queries
+// Reads carry a block height: the state at that height is rebuilt from the
+// nearest snapshot and the stored diffs, checked against the canonical block's
+// state root, and the answer is proven against that root.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+var (
+	// ErrHeightPruned is returned for a height older than the retained history window.
+	ErrHeightPruned = errors.New("height outside retained state history")
+	// ErrUnknownHeight is returned for a height above the canonical head.
+	ErrUnknownHeight = errors.New("height above chain head")
+)
+
+// History answers state queries at past heights of a chain. With a zero Window
+// it is an archive and keeps every version; otherwise only the last Window
+// heights stay queryable and Prune deletes the diffs no longer needed for them.
+type History struct {
+	Chain     *blockchain.Blockchain
+	Diffs     *DiffStore
+	Snapshots *SnapshotStore
+	Config    amf.RebalanceConfig
+	Window    int
+}
+
+// Oldest returns the lowest queryable height.
+func (h *History) Oldest() int {
+	head := h.Chain.Head()
+	if head == nil {
+		return 0
+	}
+	return h.oldest(head.Index)
+}
+
+// oldest returns the lowest queryable height when the head is at height head.
+func (h *History) oldest(head int) int {
+	if h.Window <= 0 || head < h.Window {
+		return 0
+	}
+	return head - h.Window + 1
+}
+
+// StateAt returns the state as it was after the canonical block at height.
+func (h *History) StateAt(height int) (*blockchain.StateDB, *blockchain.Block, error) {
+	block, ok := h.Chain.BlockAt(height)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %d", ErrUnknownHeight, height)
+	}
+	if oldest := h.Oldest(); height < oldest {
+		return nil, nil, fmt.Errorf("%w: height %d, oldest kept %d", ErrHeightPruned, height, oldest)
+	}
+	state, err := h.Diffs.Rebuild(h.Snapshots, h.Chain.BlockAt, height, h.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+	if root := state.Root(); !bytes.Equal(root, block.StateRoot) {
+		return nil, nil, fmt.Errorf("%w: block %d: got %x, want %x", blockchain.ErrStateRootMismatch, height, root, block.StateRoot)
+	}
+	return state, block, nil
+}
+
+// AccountAt returns address's account at height with its proof against the
+// state root of the canonical block at that height.
+func (h *History) AccountAt(address string, height int) (*blockchain.AccountProof, *blockchain.Block, error) {
+	state, block, err := h.StateAt(height)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof, err := state.ProveAccount(address)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proof, block, nil
+}
+
+// Prune deletes the diffs that no queryable height needs once the head is at
+// height head: those at or below the latest snapshot before the window. An
+// archive keeps everything. Prune does not call into the chain, so it can run
+// from a chain listener.
+func (h *History) Prune(head int) error {
+	if h.Window <= 0 {
+		return nil
+	}
+	base, err := h.Snapshots.Latest(h.oldest(head))
+	if errors.Is(err, ErrNoSnapshot) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return h.Diffs.Prune(base.Height)
+}
@@ -0,0 +1,270 @@
+package store
+
+// snapshot.go: Content-addressed state snapshot store
+// Snapshot files are named by the full SHA-256 of their contents; an index
+// records the block height, block hash, state root and creation time of each
+// snapshot taken. Identical states share one file.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+var (
+	// ErrNoSnapshot is returned when no snapshot matches a lookup.
+	ErrNoSnapshot = errors.New("snapshot not found")
+	// ErrSnapshotHash is returned when a snapshot file does not match its content hash.
+	ErrSnapshotHash = errors.New("snapshot content hash mismatch")
+)
+
+// snapshotIndexFile is the name of the index within the snapshot directory.
+const snapshotIndexFile = "index.json"
+
+// SnapshotInfo describes one snapshot.
+type SnapshotInfo struct {
+	Hash      string    // Hex SHA-256 of the snapshot contents, also its file name
+	Height    int       // Index of the block the state is the result of
+	BlockHash string    // Hash of that block
+	StateRoot string    // Hex state root, checked on restore
+	Created   time.Time // When the snapshot was taken
+	Size      int64     // Size of the snapshot file in bytes
+}
+
+// RetentionPolicy decides which snapshots Prune keeps: the KeepLast most recent
+// heights plus every height that is a multiple of KeepEvery. Zero disables a
+// rule; the zero policy disables pruning and keeps every snapshot.
+type RetentionPolicy struct {
+	KeepLast  int
+	KeepEvery int
+}
+
+// DefaultRetention keeps the last 8 snapshots and one every 100 blocks.
+var DefaultRetention = RetentionPolicy{KeepLast: 8, KeepEvery: 100}
+
+// SnapshotStore keeps state snapshots in a directory.
+type SnapshotStore struct {
+	mu    sync.Mutex
+	dir   string
+	infos []SnapshotInfo // Sorted by height, then creation time
+}
+
+// OpenSnapshots opens or creates the snapshot store in dir.
+func OpenSnapshots(dir string) (*SnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &SnapshotStore{dir: dir}
+	data, err := os.ReadFile(filepath.Join(dir, snapshotIndexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.infos); err != nil {
+		return nil, fmt.Errorf("snapshot index: %w", err)
+	}
+	s.sort()
+	return s, nil
+}
+
+// Save snapshots state as the result of block and records it in the index.
+func (s *SnapshotStore) Save(block *blockchain.Block, state *blockchain.StateDB) (SnapshotInfo, error) {
+	data, err := state.EncodeSnapshot()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	sum := sha256.Sum256(data)
+	info := SnapshotInfo{
+		Hash:      hex.EncodeToString(sum[:]),
+		Height:    block.Index,
+		BlockHash: block.Hash,
+		StateRoot: hex.EncodeToString(state.Root()),
+		Created:   time.Now().UTC(),
+		Size:      int64(len(data)),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := s.path(info.Hash)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := writeFileAtomic(path, data, 0o644); err != nil {
+			return SnapshotInfo{}, err
+		}
+	}
+	s.infos = append(s.infos, info)
+	s.sort()
+	if err := s.writeIndex(); err != nil {
+		return SnapshotInfo{}, err
+	}
+	return info, nil
+}
+
+// Path returns the file the snapshot described by info is stored in.
+func (s *SnapshotStore) Path(info SnapshotInfo) string {
+	return s.path(info.Hash)
+}
+
+// List returns every snapshot, oldest height first.
+func (s *SnapshotStore) List() []SnapshotInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SnapshotInfo(nil), s.infos...)
+}
+
+// Latest returns the snapshot with the greatest height at or below height
+// (any height if height is negative).
+func (s *SnapshotStore) Latest(height int) (SnapshotInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.infos) - 1; i >= 0; i-- {
+		if height < 0 || s.infos[i].Height <= height {
+			return s.infos[i], nil
+		}
+	}
+	return SnapshotInfo{}, ErrNoSnapshot
+}
+
+// LatestCanonical returns the snapshot with the greatest height at or below
+// height that was taken at the canonical block of its height, as returned by
+// blockAt. Snapshots of blocks a reorg abandoned are skipped.
+func (s *SnapshotStore) LatestCanonical(height int, blockAt func(height int) (*blockchain.Block, bool)) (SnapshotInfo, error) {
+	infos := s.List()
+	for i := len(infos) - 1; i >= 0; i-- {
+		if infos[i].Height > height {
+			continue
+		}
+		if b, ok := blockAt(infos[i].Height); ok && b.Hash == infos[i].BlockHash {
+			return infos[i], nil
+		}
+	}
+	return SnapshotInfo{}, ErrNoSnapshot
+}
+
+// Restore reads the snapshot described by info and rebuilds its state,
+// checking both the content hash and the state root.
+func (s *SnapshotStore) Restore(info SnapshotInfo, cfg amf.RebalanceConfig) (*blockchain.StateDB, error) {
+	data, err := os.ReadFile(s.path(info.Hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoSnapshot, info.Hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != info.Hash {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotHash, info.Hash)
+	}
+	root, err := hex.DecodeString(info.StateRoot)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: bad state root: %w", info.Hash, err)
+	}
+	return blockchain.RestoreSnapshot(data, root, cfg)
+}
+
+// Prune drops the snapshots the policy does not keep and deletes files no
+// remaining snapshot refers to. It returns the dropped snapshots.
+func (s *SnapshotStore) Prune(policy RetentionPolicy) ([]SnapshotInfo, error) {
+	if policy == (RetentionPolicy{}) {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Most recent distinct heights
+	recent := make(map[int]bool)
+	for i := len(s.infos) - 1; i >= 0 && len(recent) < policy.KeepLast; i-- {
+		recent[s.infos[i].Height] = true
+	}
+	var kept, dropped []SnapshotInfo
+	for _, info := range s.infos {
+		if recent[info.Height] || (policy.KeepEvery > 0 && info.Height%policy.KeepEvery == 0) {
+			kept = append(kept, info)
+		} else {
+			dropped = append(dropped, info)
+		}
+	}
+	if len(dropped) == 0 {
+		return nil, nil
+	}
+	s.infos = kept
+	if err := s.writeIndex(); err != nil {
+		return nil, err
+	}
+	// Delete files only after the index no longer refers to them
+	used := make(map[string]bool)
+	for _, info := range kept {
+		used[info.Hash] = true
+	}
+	for _, info := range dropped {
+		if !used[info.Hash] {
+			if err := os.Remove(s.path(info.Hash)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return dropped, err
+			}
+			used[info.Hash] = true
+		}
+	}
+	return dropped, nil
+}
+
+// path returns the file holding the snapshot with the given content hash.
+func (s *SnapshotStore) path(hash string) string {
+	return filepath.Join(s.dir, hash+".snap")
+}
+
+// sort orders the index by height, then creation time; s.mu must be held.
+func (s *SnapshotStore) sort() {
+	sort.SliceStable(s.infos, func(i, j int) bool {
+		if s.infos[i].Height != s.infos[j].Height {
+			return s.infos[i].Height < s.infos[j].Height
+		}
+		return s.infos[i].Created.Before(s.infos[j].Created)
+	})
+}
+
+// writeIndex persists the index; s.mu must be held.
+func (s *SnapshotStore) writeIndex() error {
+	data, err := json.MarshalIndent(s.infos, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, snapshotIndexFile), data, 0o644)
+}
+
+// writeFileAtomic replaces path with data via a synced temporary file and rename.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bilal2134/Blockchain_A3/internal/amf"
+	"github.com/bilal2134/Blockchain_A3/internal/blockchain"
+)
+
+// saveSnapshots saves a snapshot of a distinct state at each height from 1 to n.
+func saveSnapshots(t *testing.T, n int) (*SnapshotStore, []SnapshotInfo) {
+	t.Helper()
+	snapshots, err := OpenSnapshots(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var infos []SnapshotInfo
+	for h := 1; h <= n; h++ {
+		state := blockchain.NewStateDB(amf.NewForest(), amf.DefaultRebalanceConfig)
+		if err := state.SetAccount("0000000000000000000000000000000000000001", blockchain.Account{Balance: uint64(h)}); err != nil {
+			t.Fatal(err)
+		}
+		b := blockchain.NewBlock(h, "", nil)
+		b.StateRoot = state.Root()
+		b.Seal()
+		info, err := snapshots.Save(b, state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		infos = append(infos, info)
+	}
+	return snapshots, infos
+}
+
+// heights returns the heights of infos.
+func heights(infos []SnapshotInfo) []int {
+	var out []int
+	for _, info := range infos {
+		out = append(out, info.Height)
+	}
+	return out
+}
+
+func TestPruneRetention(t *testing.T) {
+	for _, c := range []struct {
+		policy RetentionPolicy
+		kept   []int
+	}{
+		{RetentionPolicy{KeepLast: 3, KeepEvery: 5}, []int{5, 10, 11, 12}},
+		{RetentionPolicy{KeepLast: 2}, []int{11, 12}},
+		{RetentionPolicy{KeepEvery: 4}, []int{4, 8, 12}},
+		{RetentionPolicy{}, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}},
+	} {
+		snapshots, infos := saveSnapshots(t, 12)
+		dropped, err := snapshots.Prune(c.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept := snapshots.List()
+		if got := heights(kept); len(got) != len(c.kept) || len(dropped)+len(kept) != len(infos) {
+			t.Fatalf("%+v kept heights %v, want %v", c.policy, got, c.kept)
+		}
+		for i, h := range heights(kept) {
+			if h != c.kept[i] {
+				t.Fatalf("%+v kept heights %v, want %v", c.policy, heights(kept), c.kept)
+			}
+		}
+		for _, info := range dropped {
+			if _, err := os.Stat(snapshots.Path(info)); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%+v left the file of dropped snapshot %d: %v", c.policy, info.Height, err)
+			}
+		}
+		// The index on disk agrees
+		reopened, err := OpenSnapshots(snapshots.dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := heights(reopened.List()); len(got) != len(c.kept) {
+			t.Fatalf("%+v: reopened store holds heights %v", c.policy, got)
+		}
+	}
+}
+
+func TestRestoreChecksStateRoot(t *testing.T) {
+	snapshots, infos := saveSnapshots(t, 2)
+	state, err := snapshots.Restore(infos[0], amf.DefaultRebalanceConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acct := state.GetAccount("0000000000000000000000000000000000000001"); acct.Balance != 1 {
+		t.Fatalf("restored balance %d, want 1", acct.Balance)
+	}
+	wrong := infos[0]
+	wrong.StateRoot = infos[1].StateRoot
+	if _, err := snapshots.Restore(wrong, amf.DefaultRebalanceConfig); !errors.Is(err, blockchain.ErrStateRootMismatch) {
+		t.Fatalf("snapshot under another state root: %v", err)
+	}
+	if err := os.WriteFile(snapshots.Path(infos[1]), []byte("altered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snapshots.Restore(infos[1], amf.DefaultRebalanceConfig); !errors.Is(err, ErrSnapshotHash) {
+		t.Fatalf("altered snapshot file: %v", err)
+	}
+}
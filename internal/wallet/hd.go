@@ -0,0 +1,117 @@
+package wallet
+
+// hd.go: SLIP-10 hierarchical deterministic key derivation for ed25519
+// Derives hardened child keys from a seed with HMAC-SHA512.
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// HardenedOffset is added to a child index to select hardened derivation.
+	HardenedOffset uint32 = 0x80000000
+	// DefaultBasePath is the account path prefix; the account index is appended as a hardened level.
+	DefaultBasePath = "m/44'/9000'"
+	masterSecret    = "ed25519 seed"
+)
+
+// ErrNonHardened is returned for path levels that are not hardened; ed25519
+// under SLIP-10 only supports hardened derivation.
+var ErrNonHardened = errors.New("ed25519 derivation requires hardened indices")
+
+// ExtendedKey is a SLIP-10 node: a private key together with its chain code.
+type ExtendedKey struct {
+	Key       []byte // 32-byte ed25519 seed
+	ChainCode []byte
+	Depth     int
+	Index     uint32
+}
+
+// NewMasterKey derives the master node from a seed.
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("seed length must be between 16 and 64 bytes, got %d", len(seed))
+	}
+	mac := hmac.New(sha512.New, []byte(masterSecret))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	return &ExtendedKey{Key: sum[:32], ChainCode: sum[32:]}, nil
+}
+
+// Child derives the hardened child at index (HardenedOffset is added if missing).
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if index < HardenedOffset {
+		index += HardenedOffset
+	}
+	// I = HMAC-SHA512(chain code, 0x00 || key || ser32(index))
+	data := make([]byte, 0, 1+32+4)
+	data = append(data, 0x00)
+	data = append(data, k.Key...)
+	data = binary.BigEndian.AppendUint32(data, index)
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	return &ExtendedKey{
+		Key:       sum[:32],
+		ChainCode: sum[32:],
+		Depth:     k.Depth + 1,
+		Index:     index,
+	}, nil
+}
+
+// Derive walks a path such as "m/44'/9000'/0'" from this node.
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	indices, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	node := k
+	for _, idx := range indices {
+		if node, err = node.Child(idx); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// PrivateKey returns the ed25519 private key for this node.
+func (k *ExtendedKey) PrivateKey() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(k.Key)
+}
+
+// PublicKey returns the ed25519 public key for this node.
+func (k *ExtendedKey) PublicKey() ed25519.PublicKey {
+	return k.PrivateKey().Public().(ed25519.PublicKey)
+}
+
+// ParsePath parses a derivation path into hardened child indices.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start with m", path)
+	}
+	indices := make([]uint32, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		if !strings.HasSuffix(p, "'") && !strings.HasSuffix(p, "h") {
+			return nil, fmt.Errorf("%w: %q", ErrNonHardened, p)
+		}
+		n, err := strconv.ParseUint(p[:len(p)-1], 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path level %q: %w", p, err)
+		}
+		indices = append(indices, uint32(n)+HardenedOffset)
+	}
+	return indices, nil
+}
+
+// AccountPath returns the derivation path of the account at index.
+func AccountPath(index uint32) string {
+	return fmt.Sprintf("%s/%d'", DefaultBasePath, index)
+}
@@ -0,0 +1,58 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// mustHex decodes a hex test vector.
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// SLIP-10 ed25519 test vector 1.
+func TestDeriveSLIP10Vectors(t *testing.T) {
+	master, err := NewMasterKey(mustHex(t, "000102030405060708090a0b0c0d0e0f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []struct {
+		path, key, chainCode string
+	}{
+		{"m", "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7", "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb"},
+		{"m/0'", "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3", "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69"},
+		{"m/0'/1'", "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2", "a320425f77d1b5c2505a6b1b27382b37368ee640e3557c315416801243552f14"},
+		{"m/0'/1'/2'", "92a5b23c0b8a99e37d07df3fb9966917f5d06e02ddbd909c7e184371463e9fc9", "2e69929e00b5ab250f49c3fb1c12f252de4fed2c1db88387094a0f8c4c9ccd6c"},
+		{"m/0'/1'/2'/2'", "30d1dc7e5fc04c31219ab25a27ae00b50f6fd66622f6e9c913253d6511d1e662", "8f6d87f93d750e0efccda017d662a1b31a266e4a6f5993b15f5c1f07f74dd5cc"},
+		{"m/0'/1'/2'/2'/1000000000'", "8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793", "68789923a0cac2cd5a29172a475fe9e0fb14cd6adb5ad98a3fa70333e7afa230"},
+	} {
+		node, err := master.Derive(v.path)
+		if err != nil {
+			t.Fatalf("%s: %v", v.path, err)
+		}
+		if got := hex.EncodeToString(node.Key); got != v.key {
+			t.Errorf("%s: key %s, want %s", v.path, got, v.key)
+		}
+		if got := hex.EncodeToString(node.ChainCode); got != v.chainCode {
+			t.Errorf("%s: chain code %s, want %s", v.path, got, v.chainCode)
+		}
+	}
+	if got := hex.EncodeToString(master.PublicKey()); got != "a4b2856bfec510abab89753fac1ac0e1112364e7d250545963f135f2a33188ed" {
+		t.Errorf("master public key %s", got)
+	}
+}
+
+func TestParsePathRejectsNonHardened(t *testing.T) {
+	if _, err := ParsePath("m/44'/0"); !errors.Is(err, ErrNonHardened) {
+		t.Fatalf("non-hardened level: %v", err)
+	}
+	if _, err := ParsePath("44'/0'"); err == nil {
+		t.Fatal("path without m accepted")
+	}
+}
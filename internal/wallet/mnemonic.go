@@ -0,0 +1,128 @@
+package wallet
+
+// mnemonic.go: BIP-39 mnemonic backup phrases
+// Encodes entropy plus a SHA-256 checksum as 11-bit word indices and stretches
+// phrases into seeds with PBKDF2-HMAC-SHA512.
+//
+// The embedded word list is the BIP-39 English list, so phrases work with other
+// BIP-39 tools, and no two words share their first four letters.
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/bilal2134/Blockchain_A3/internal/crypto"
+)
+
+//go:embed wordlist.txt
+var rawWordList string
+
+var (
+	wordList  = strings.Fields(rawWordList)
+	wordIndex = func() map[string]int {
+		m := make(map[string]int, len(wordList))
+		for i, w := range wordList {
+			m[w] = i
+		}
+		return m
+	}()
+)
+
+var (
+	// ErrInvalidEntropy is returned for entropy that is not 128-256 bits in 32-bit steps.
+	ErrInvalidEntropy = errors.New("entropy must be 128-256 bits and a multiple of 32")
+	// ErrInvalidMnemonic is returned for phrases with unknown words or a wrong word count.
+	ErrInvalidMnemonic = errors.New("invalid mnemonic")
+	// ErrChecksum is returned when a phrase's checksum bits do not match its entropy.
+	ErrChecksum = errors.New("mnemonic checksum mismatch")
+)
+
+const seedIterations = 2048
+
+// NewMnemonic returns a fresh phrase encoding bits of random entropy.
+func NewMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrInvalidEntropy
+	}
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic encodes entropy and its checksum as a word phrase.
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", ErrInvalidEntropy
+	}
+	csBits := bits / 32
+	sum := sha256.Sum256(entropy)
+	// value = entropy || first csBits bits of the checksum
+	value := new(big.Int).SetBytes(entropy)
+	value.Lsh(value, uint(csBits))
+	value.Or(value, big.NewInt(int64(sum[0]>>(8-csBits))))
+
+	n := (bits + csBits) / 11
+	words := make([]string, n)
+	mask := big.NewInt(2047)
+	idx := new(big.Int)
+	for i := n - 1; i >= 0; i-- {
+		idx.And(value, mask)
+		words[i] = wordList[idx.Int64()]
+		value.Rsh(value, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy decodes a phrase and verifies its checksum.
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	n := len(words)
+	if n < 12 || n > 24 || n%3 != 0 {
+		return nil, fmt.Errorf("%w: %d words", ErrInvalidMnemonic, n)
+	}
+	value := new(big.Int)
+	for _, w := range words {
+		i, ok := wordIndex[strings.ToLower(w)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown word %q", ErrInvalidMnemonic, w)
+		}
+		value.Lsh(value, 11)
+		value.Or(value, big.NewInt(int64(i)))
+	}
+	total := n * 11
+	csBits := total / 33
+	entBits := total - csBits
+	checksum := new(big.Int).And(value, big.NewInt(int64(1)<<csBits-1))
+	value.Rsh(value, uint(csBits))
+	entropy := value.FillBytes(make([]byte, entBits/8))
+	sum := sha256.Sum256(entropy)
+	if int64(sum[0]>>(8-csBits)) != checksum.Int64() {
+		return nil, ErrChecksum
+	}
+	return entropy, nil
+}
+
+// ValidateMnemonic reports whether a phrase decodes with a valid checksum.
+func ValidateMnemonic(mnemonic string) bool {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err == nil
+}
+
+// MnemonicToSeed validates a phrase and stretches it, with an optional
+// passphrase, into a 64-byte seed.
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := MnemonicToEntropy(mnemonic); err != nil {
+		return nil, err
+	}
+	normalized := strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	return crypto.PBKDF2([]byte(normalized), []byte("mnemonic"+passphrase), seedIterations, 64, sha512.New), nil
+}
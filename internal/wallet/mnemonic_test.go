@@ -0,0 +1,110 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// BIP-39 English test vectors, seeded with the passphrase "TREZOR".
+var mnemonicVectors = []struct {
+	entropy, mnemonic, seed string
+}{
+	{
+		"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+		"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		"80808080808080808080808080808080",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+	},
+	{
+		"ffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+	},
+	{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		strings.Repeat("abandon ", 23) + "art",
+		"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+	},
+}
+
+func TestMnemonicVectors(t *testing.T) {
+	for _, v := range mnemonicVectors {
+		entropy := mustHex(t, v.entropy)
+		mnemonic, err := EntropyToMnemonic(entropy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mnemonic != v.mnemonic {
+			t.Errorf("%s: mnemonic %q, want %q", v.entropy, mnemonic, v.mnemonic)
+		}
+		back, err := MnemonicToEntropy(v.mnemonic)
+		if err != nil || !bytes.Equal(back, entropy) {
+			t.Errorf("%q: entropy %x, %v", v.mnemonic, back, err)
+		}
+		seed, err := MnemonicToSeed(v.mnemonic, "TREZOR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(seed); got != v.seed {
+			t.Errorf("%q: seed %s, want %s", v.mnemonic, got, v.seed)
+		}
+	}
+}
+
+func TestMnemonicRoundTrip(t *testing.T) {
+	for _, bits := range []int{128, 160, 192, 224, 256} {
+		mnemonic, err := NewMnemonic(bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(strings.Fields(mnemonic)); n != bits/32*3 {
+			t.Fatalf("%d bits gave %d words", bits, n)
+		}
+		entropy, err := MnemonicToEntropy(mnemonic)
+		if err != nil || len(entropy) != bits/8 {
+			t.Fatalf("%d bits: entropy %x, %v", bits, entropy, err)
+		}
+		again, err := EntropyToMnemonic(entropy)
+		if err != nil || again != mnemonic {
+			t.Fatalf("re-encoded %q as %q, %v", mnemonic, again, err)
+		}
+	}
+	if _, err := NewMnemonic(100); !errors.Is(err, ErrInvalidEntropy) {
+		t.Fatalf("100 bits: %v", err)
+	}
+}
+
+func TestMnemonicRejectsBadPhrases(t *testing.T) {
+	for name, c := range map[string]struct {
+		mnemonic string
+		want     error
+	}{
+		"checksum":   {strings.Repeat("abandon ", 11) + "abandon", ErrChecksum},
+		"last word":  {"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo", ErrChecksum},
+		"unknown":    {strings.Repeat("abandon ", 11) + "blockchain", ErrInvalidMnemonic},
+		"word count": {strings.Repeat("abandon ", 10) + "about", ErrInvalidMnemonic},
+		"empty":      {"", ErrInvalidMnemonic},
+	} {
+		if _, err := MnemonicToEntropy(c.mnemonic); !errors.Is(err, c.want) {
+			t.Errorf("%s: %v, want %v", name, err, c.want)
+		}
+		if ValidateMnemonic(c.mnemonic) {
+			t.Errorf("%s: phrase validated", name)
+		}
+		if _, err := MnemonicToSeed(c.mnemonic, ""); err == nil {
+			t.Errorf("%s: seed derived", name)
+		}
+	}
+}
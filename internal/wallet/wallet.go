@@ -0,0 +1,161 @@
+package wallet
+
+// wallet.go: Wallet accounts backed by the encrypted keystore
+// Loads ed25519 account keys at startup and signs on their behalf.
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bilal2134/Blockchain_A3/internal/keystore"
+)
+
+// ErrUnknownAccount is returned for addresses the wallet has not loaded.
+var ErrUnknownAccount = errors.New("unknown wallet account")
+
+// Wallet holds the set of unlocked signing accounts.
+type Wallet struct {
+	ks       *keystore.KeyStore
+	mu       sync.RWMutex
+	accounts map[string]struct{}
+}
+
+// New creates an empty wallet over the given keystore.
+func New(ks *keystore.KeyStore) *Wallet {
+	return &Wallet{
+		ks:       ks,
+		accounts: make(map[string]struct{}),
+	}
+}
+
+// Load unlocks every ed25519 key in the keystore that opens with passphrase
+// and returns the addresses that were loaded.
+func (w *Wallet) Load(passphrase string) ([]string, error) {
+	infos, err := w.ks.List()
+	if err != nil {
+		return nil, err
+	}
+	var loaded []string
+	for _, info := range infos {
+		if info.Type != keystore.KeyEd25519 {
+			continue
+		}
+		if err := w.ks.Unlock(info.Address, passphrase); err != nil {
+			if errors.Is(err, keystore.ErrDecrypt) {
+				continue
+			}
+			return loaded, err
+		}
+		w.add(info.Address)
+		loaded = append(loaded, info.Address)
+	}
+	return loaded, nil
+}
+
+// NewAccount creates, stores and unlocks a fresh account.
+func (w *Wallet) NewAccount(passphrase string) (string, error) {
+	address, err := w.ks.NewKey(keystore.KeyEd25519, passphrase)
+	if err != nil {
+		return "", err
+	}
+	if err := w.ks.Unlock(address, passphrase); err != nil {
+		return "", err
+	}
+	w.add(address)
+	return address, nil
+}
+
+// Restore derives the first count accounts from a mnemonic phrase, stores any
+// keys missing from the keystore under passphrase and loads all of them.
+func (w *Wallet) Restore(mnemonic, mnemonicPassphrase, passphrase string, count int) ([]string, error) {
+	seed, err := MnemonicToSeed(mnemonic, mnemonicPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		node, err := master.Derive(AccountPath(uint32(i)))
+		if err != nil {
+			return accounts, err
+		}
+		address, err := w.ks.Import(keystore.KeyEd25519, node.Key, passphrase)
+		if errors.Is(err, keystore.ErrExists) {
+			key, kerr := keystore.NewKey(keystore.KeyEd25519, node.Key)
+			if kerr != nil {
+				return accounts, kerr
+			}
+			address, err = key.Address, nil
+		}
+		if err != nil {
+			return accounts, err
+		}
+		if err := w.ks.Unlock(address, passphrase); err != nil {
+			return accounts, fmt.Errorf("unlock restored account %s: %w", address, err)
+		}
+		w.add(address)
+		accounts = append(accounts, address)
+	}
+	return accounts, nil
+}
+
+// Accounts returns the loaded account addresses in sorted order.
+func (w *Wallet) Accounts() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	out := make([]string, 0, len(w.accounts))
+	for addr := range w.accounts {
+		out = append(out, addr)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// PublicKey returns the public key of a loaded account.
+func (w *Wallet) PublicKey(address string) ([]byte, error) {
+	key, err := w.key(address)
+	if err != nil {
+		return nil, err
+	}
+	return key.PublicKey()
+}
+
+// Sign signs msg with the key of a loaded account.
+func (w *Wallet) Sign(address string, msg []byte) ([]byte, error) {
+	key, err := w.key(address)
+	if err != nil {
+		return nil, err
+	}
+	return key.Sign(msg)
+}
+
+// Close locks every account the wallet loaded.
+func (w *Wallet) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for addr := range w.accounts {
+		w.ks.Lock(addr)
+	}
+	w.accounts = make(map[string]struct{})
+}
+
+func (w *Wallet) add(address string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.accounts[address] = struct{}{}
+}
+
+func (w *Wallet) key(address string) (*keystore.Key, error) {
+	w.mu.RLock()
+	_, ok := w.accounts[address]
+	w.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownAccount
+	}
+	return w.ks.Key(address)
+}
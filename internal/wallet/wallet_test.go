@@ -0,0 +1,74 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bilal2134/Blockchain_A3/internal/keystore"
+)
+
+func TestRestoreDerivesEveryAccount(t *testing.T) {
+	const (
+		mnemonic = "legal winner thank year wave sausage worth useful legal winner thank yellow"
+		count    = 3
+	)
+	ks, err := keystore.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.SetIterations(1)
+	seed, err := MnemonicToSeed(mnemonic, "extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(ks)
+	accounts, err := w.Restore(mnemonic, "extra", "pass", count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != count {
+		t.Fatalf("restored %d accounts, want %d", len(accounts), count)
+	}
+	for i, address := range accounts {
+		node, err := master.Derive(AccountPath(uint32(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := w.PublicKey(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(pub, node.PublicKey()) {
+			t.Errorf("account %d: public key %x, want %x", i, pub, node.PublicKey())
+		}
+	}
+
+	// Restoring again, as on another start, finds the stored keys
+	again, err := New(ks).Restore(mnemonic, "extra", "pass", count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range accounts {
+		if again[i] != accounts[i] {
+			t.Fatalf("second restore gave %v, want %v", again, accounts)
+		}
+	}
+	infos, err := ks.List()
+	if err != nil || len(infos) != count {
+		t.Fatalf("keystore holds %d keys, %v; want %d", len(infos), err, count)
+	}
+
+	// Another mnemonic passphrase is another wallet
+	other, err := New(ks).Restore(mnemonic, "", "pass", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other[0] == accounts[0] {
+		t.Fatal("mnemonic passphrase did not change the derived account")
+	}
+}